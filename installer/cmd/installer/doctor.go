@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/installer/internal/installer"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/detector"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/doctor"
+)
+
+// runDoctor checks what the installer set up, with the same checks as
+// "agent-payment-server doctor", and prints the report
+// Exits 1 when a check fails.
+func runDoctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the report as JSON")
+	flags.Parse(args)
+
+	binaryPath, configPath, err := installer.InstallPaths()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := doctor.Options{
+		ConfigPath: configPath,
+		Clients:    detector.New().DetectAll(),
+		Binary:     binaryPath,
+	}
+
+	// Same precedence as the server: config.json, then the environment for
+	// missing keys; AGENT_PAYMENT_API_URL overrides the URL
+	var config struct {
+		APIKey    string `json:"api_key"`
+		BudgetKey string `json:"budget_key"`
+		APIURL    string `json:"api_url"`
+	}
+	data, err := os.ReadFile(configPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		opts.ConfigErr = err
+	default:
+		if err := json.Unmarshal(data, &config); err != nil {
+			opts.ConfigErr = fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+
+	for _, setting := range []struct {
+		name, env string
+		value     *string
+		override  bool
+	}{
+		{"api_url", "AGENT_PAYMENT_API_URL", &config.APIURL, true},
+		{"api_key", "AGENT_PAYMENT_API_KEY", &config.APIKey, false},
+		{"budget_key", "AGENT_PAYMENT_BUDGET_KEY", &config.BudgetKey, false},
+	} {
+		source := "config.json"
+		if env := os.Getenv(setting.env); env != "" && (setting.override || *setting.value == "") {
+			*setting.value, source = env, setting.env
+		}
+		switch {
+		case *setting.value != "":
+		case setting.name == "api_url":
+			*setting.value, source = installer.DefaultAPIURL, "default"
+		default:
+			source = "nowhere: run the installer or set " + setting.env
+		}
+		opts.Settings = append(opts.Settings, doctor.Setting{Name: setting.name, Value: *setting.value, Source: source})
+	}
+	opts.APIURL, opts.APIKey, opts.BudgetKey = config.APIURL, config.APIKey, config.BudgetKey
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report := doctor.Run(ctx, opts)
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write the report: %v\n", err)
+		os.Exit(1)
+	}
+	if !report.OK {
+		os.Exit(1)
+	}
+}
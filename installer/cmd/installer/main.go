@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
 	"time"
@@ -12,6 +13,12 @@ import (
 )
 
 func main() {
+	// "installer doctor" checks an existing install instead of running the UI
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		runDoctor(os.Args[2:])
+		return
+	}
+
 	port := "8765"
 
 	// Create server
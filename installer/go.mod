@@ -1,5 +1,9 @@
 module github.com/Apoth3osis-ai/agent-payment-mcp/installer
 
-go 1.21
+go 1.23.0
 
-require ()
+require github.com/Apoth3osis-ai/agent-payment-mcp/pkg v0.0.0
+
+require github.com/tmaxmax/go-sse v0.11.0 // indirect
+
+replace github.com/Apoth3osis-ai/agent-payment-mcp/pkg => ../pkg
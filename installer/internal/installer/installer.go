@@ -27,6 +27,9 @@ const (
 	binaryName = "agent-payment-server"
 )
 
+// DefaultAPIURL is the API the installer points the server at
+const DefaultAPIURL = "https://api.agentpmt.com"
+
 type InstallRequest struct {
 	APIKey        string   `json:"apiKey"`
 	BudgetKey     string   `json:"budgetKey"`
@@ -84,9 +87,7 @@ func (inst *Installer) Install(req InstallRequest) InstallProgress {
 	progress.Message = "Creating configuration file..."
 	progress.Progress = 40
 
-	apiURL := "https://api.agentpmt.com"
-
-	err = inst.createConfig(filepath.Dir(binaryPath), req.APIKey, req.BudgetKey, apiURL)
+	err = inst.createConfig(filepath.Dir(binaryPath), req.APIKey, req.BudgetKey, DefaultAPIURL)
 	if err != nil {
 		progress.Error = fmt.Sprintf("Failed to create config: %v", err)
 		return progress
@@ -176,6 +177,21 @@ func (inst *Installer) setupBinary() (string, error) {
 	return binaryPath, nil
 }
 
+// InstallPaths returns where Install puts the server binary and config.json
+func InstallPaths() (binaryPath, configPath string, err error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", err
+	}
+
+	dir := filepath.Join(home, installDir)
+	binaryFilename := binaryName
+	if runtime.GOOS == "windows" {
+		binaryFilename += ".exe"
+	}
+	return filepath.Join(dir, binaryFilename), filepath.Join(dir, "config.json"), nil
+}
+
 func (inst *Installer) createConfig(dir, apiKey, budgetKey, apiURL string) error {
 	config := map[string]string{
 		"api_key":    apiKey,
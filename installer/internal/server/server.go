@@ -7,8 +7,8 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/Apoth3osis-ai/agent-payment-mcp/installer/internal/detector"
 	"github.com/Apoth3osis-ai/agent-payment-mcp/installer/internal/installer"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/detector"
 )
 
 //go:embed all:web
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/mcp"
+)
+
+// The operator commands build the same server agents talk to, so names,
+// schemas, caps and approval rules match what MCP clients get.
+
+// operatorFlags are the flags every operator command takes
+type operatorFlags struct {
+	*settings
+	verbose *bool
+}
+
+// newOperatorFlags creates a flag set for an operator command
+func newOperatorFlags(name string) (*flag.FlagSet, *operatorFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	return fs, &operatorFlags{
+		settings: addSettings(fs),
+		verbose:  fs.Bool("v", false, "Log what the server does to stderr"),
+	}
+}
+
+// parseInterspersed parses args allowing flags after positional arguments,
+// e.g. "call weather --args '{}'", and returns the positional ones
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return positional
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// openServer fetches the catalog and registers it the way serve does
+// A readOnly server leaves the name registry and catalog cache untouched.
+// Cancelling ctx abandons the catalog fetch.
+func (f *operatorFlags) openServer(ctx context.Context, readOnly bool) *mcp.Server {
+	if !*f.verbose {
+		log.SetOutput(io.Discard)
+	}
+	cfg := f.serverConfig()
+	cfg.ReadOnly = readOnly
+	server, err := mcp.NewServer(ctx, cfg)
+	if err != nil {
+		exitf("%v", err)
+	}
+	if server.Stale() {
+		fmt.Fprintln(os.Stderr, "Warning: the API is unreachable; showing the cached catalog, which may be out of date")
+	}
+	return server
+}
+
+// runListTools prints the tools agents see, as a table or JSON
+func runListTools(args []string) {
+	fs, f := newOperatorFlags("list-tools")
+	asJSON := fs.Bool("json", false, "Print JSON instead of a table")
+	parseInterspersed(fs, args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	tools := f.openServer(ctx, true).Tools()
+	if *asJSON {
+		printJSON(tools)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPRODUCT ID\tPRICE\tTITLE")
+	for _, tool := range tools {
+		price := tool.Price
+		if price == "" {
+			price = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tool.Name, tool.ProductID, price, tool.Title)
+	}
+	w.Flush()
+	fmt.Printf("\n%d tools\n", len(tools))
+}
+
+// runDescribe prints one tool as tools/list sends it, sanitized schema included
+func runDescribe(args []string) {
+	fs, f := newOperatorFlags("describe")
+	names := parseInterspersed(fs, args)
+	if len(names) != 1 {
+		exitf("describe takes one tool name, product ID or display name")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	tool, ok := f.openServer(ctx, true).Tool(names[0])
+	if !ok {
+		exitf("unknown tool %q (see list-tools)", names[0])
+	}
+	printJSON(tool)
+}
+
+// runCall buys one call of a tool and prints its result
+// Exits 1 when the tool reports an error.
+func runCall(args []string) {
+	fs, f := newOperatorFlags("call")
+	argsJSON := fs.String("args", "{}", "Tool arguments as a JSON object")
+	approve := fs.Bool("yes", false, "Approve the purchase if the approval rules require it")
+	asJSON := fs.Bool("json", false, "Print the whole tools/call result as JSON")
+	names := parseInterspersed(fs, args)
+	if len(names) != 1 {
+		exitf("call takes one tool name, e.g. call weather --args '{\"city\": \"Paris\"}'")
+	}
+
+	var toolArgs map[string]interface{}
+	if err := json.Unmarshal([]byte(*argsJSON), &toolArgs); err != nil {
+		exitf("--args must be a JSON object: %v", err)
+	}
+
+	// Ctrl-C cancels the purchase like a client's notifications/cancelled
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := f.openServer(ctx, false).CallTool(ctx, names[0], toolArgs, *approve)
+	if err != nil {
+		exitf("%v", err)
+	}
+
+	if *asJSON {
+		printJSON(result)
+	} else {
+		printContent(result)
+	}
+	if isError, _ := result["isError"].(bool); isError {
+		os.Exit(1)
+	}
+}
+
+// printContent prints a result's text blocks, and a line for each other block
+func printContent(result map[string]interface{}) {
+	blocks, _ := result["content"].([]interface{})
+	for _, b := range blocks {
+		block, _ := b.(map[string]interface{})
+		switch block["type"] {
+		case "text":
+			fmt.Println(block["text"])
+		case "resource_link":
+			fmt.Printf("[%s: %v]\n", block["type"], block["uri"])
+		default:
+			fmt.Printf("[%s: %v]\n", block["type"], block["mimeType"])
+		}
+	}
+}
+
+// runVersion prints the server, client library and Go versions
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	fs.Parse(args)
+
+	fmt.Printf("agent-payment-server %s\n", mcp.Version)
+	fmt.Printf("agentpmt client %s\n", agentpmt.Version)
+	fmt.Printf("MCP protocol %s\n", strings.Join(mcp.SupportedVersions(), ", "))
+	fmt.Printf("%s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		exitf("failed to encode output: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/detector"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/doctor"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/mcp"
+)
+
+// runDoctor checks the setup end to end and prints the report
+// Exits 1 when a check fails.
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	profileName := fs.String("profile", "", "Config profile (API environment) to check")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	fs.Parse(args)
+
+	// The sanitizer logs what it fixes; the report says it instead
+	log.SetOutput(io.Discard)
+
+	opts := doctor.Options{
+		SchemaWarnings: mcp.SchemaWarnings,
+		Clients:        detector.New().DetectAll(),
+	}
+	if exePath, err := os.Executable(); err == nil {
+		opts.Binary = exePath
+	}
+
+	api, err := loadAPISettings(*profileName)
+	opts.ConfigPath = api.configPath
+	switch {
+	case err != nil:
+		opts.ConfigErr = fmt.Errorf("invalid profile, the server won't start: %w", err)
+	case api.configErr != nil:
+		opts.ConfigErr = fmt.Errorf("can't be loaded, so it is ignored: %w", api.configErr)
+	}
+
+	profile := api.profile
+	opts.APIURL, opts.APIKey, opts.BudgetKey = profile.APIURL, profile.APIKey, profile.BudgetKey
+	if profile.Name != "" {
+		opts.Settings = append(opts.Settings, doctor.Setting{Name: "profile", Value: profile.Name, Source: api.sources["profile"]})
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"api_url", profile.APIURL},
+		{"api_key", profile.APIKey},
+		{"budget_key", profile.BudgetKey},
+	} {
+		source, ok := api.sources[setting.name]
+		if !ok {
+			source = "nowhere: set it in config.json or the environment"
+		}
+		opts.Settings = append(opts.Settings, doctor.Setting{Name: setting.name, Value: setting.value, Source: source})
+	}
+
+	if !profile.TLS.IsZero() {
+		tlsConfig, err := profile.TLS.ClientConfig()
+		if err != nil && opts.ConfigErr == nil {
+			opts.ConfigErr = fmt.Errorf("invalid TLS settings: %w", err)
+		}
+		if err == nil {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = tlsConfig
+			opts.Transport = transport
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report := doctor.Run(ctx, opts)
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		exitf("failed to write the report: %v", err)
+	}
+	if !report.OK {
+		os.Exit(1)
+	}
+}
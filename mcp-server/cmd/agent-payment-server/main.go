@@ -2,13 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/cache"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/naming"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/toolset"
 	"github.com/agentpmt/agent-payment-mcp-server/internal/config"
 	"github.com/agentpmt/agent-payment-mcp-server/internal/mcp"
 )
@@ -17,28 +29,132 @@ func main() {
 	// Configure logging
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
-	var apiKey, budgetKey string
+	// Without a subcommand (or with only flags) the server runs, as it always has
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
 
-	// Try to load from config.json first (for .mcpb package installations)
-	exePath, err := os.Executable()
-	if err == nil {
-		configPath := filepath.Join(filepath.Dir(exePath), "config.json")
-		if _, err := os.Stat(configPath); err == nil {
-			cfg, err := config.Load(configPath)
-			if err == nil {
-				apiKey = cfg.APIKey
-				budgetKey = cfg.BudgetKey
-				log.Printf("Loaded configuration from %s", configPath)
-			}
-		}
+	switch command {
+	case "serve":
+		runServe(args)
+	case "list-tools":
+		runListTools(args)
+	case "describe":
+		runDescribe(args)
+	case "call":
+		runCall(args)
+	case "version":
+		runVersion(args)
+	case "doctor":
+		runDoctor(args)
+	case "help":
+		usage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage(os.Stderr)
+		os.Exit(2)
 	}
+}
 
-	// Fall back to environment variables if config.json not found
-	if apiKey == "" {
-		apiKey = os.Getenv("AGENT_PAYMENT_API_KEY")
+// usage lists the subcommands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: agent-payment-server [command] [flags]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  serve              Run the MCP server (default)")
+	fmt.Fprintln(w, "  list-tools         List the tools agents see, with their product IDs")
+	fmt.Fprintln(w, "  describe <tool>    Show a tool's sanitized schema, annotations and price")
+	fmt.Fprintln(w, "  call <tool>        Buy one call of a tool (--args JSON)")
+	fmt.Fprintln(w, "  doctor             Check config, keys, API access, the catalog and MCP client configs")
+	fmt.Fprintln(w, "  version            Print version information")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Run 'agent-payment-server <command> -h' for a command's flags.")
+}
+
+// settings are the flags that decide what the server exposes and how it buys,
+// shared by serve and the operator commands so both see the same catalog
+type settings struct {
+	maxSessionSpend *float64
+	maxHourlySpend  *float64
+	maxDailySpend   *float64
+	approveAll      *bool
+	approveAbove    *float64
+	approveTools    *string
+	normalizeArgs   *bool
+	outputDir       *string
+	allowTools      *string
+	denyTools       *string
+	toolSet         *string
+	overridesFile   *string
+	promptsDir      *string
+	profileName     *string
+}
+
+// addSettings defines the shared flags on fs
+func addSettings(fs *flag.FlagSet) *settings {
+	return &settings{
+		maxSessionSpend: fs.Float64("max-session-spend", 0, "Local spend cap per MCP session (0 = none)"),
+		maxHourlySpend:  fs.Float64("max-hourly-spend", 0, "Local spend cap per rolling hour (0 = none)"),
+		maxDailySpend:   fs.Float64("max-daily-spend", 0, "Local spend cap per rolling 24 hours (0 = none)"),
+		approveAll:      fs.Bool("approve-all", false, "Ask the user to approve every purchase"),
+		approveAbove:    fs.Float64("approve-above", 0, "Ask the user to approve purchases expected to cost more than this (0 = never)"),
+		approveTools:    fs.String("approve-tools", "", "Comma-separated tool names that always need approval"),
+		normalizeArgs:   fs.Bool("normalize-args", false, "Coerce argument types, fill schema defaults and drop unknown properties before validating"),
+		outputDir:       fs.String("output-dir", "", "Where outputs too large to send inline are written (default: user cache dir)"),
+		allowTools:      fs.String("allow-tools", "", "Comma-separated product IDs, tool names or globs to expose (default: all)"),
+		denyTools:       fs.String("deny-tools", "", "Comma-separated product IDs, tool names or globs to hide"),
+		toolSet:         fs.String("toolset", "", "Named tool set from config.json to expose"),
+		overridesFile:   fs.String("overrides", "", "Tool title, annotation and cost overrides (default: tool-overrides.json next to the binary)"),
+		promptsDir:      fs.String("prompts", "", "Directory of prompt templates (default: prompts/ next to the binary)"),
+		profileName:     fs.String("profile", "", "Config profile (API environment) to use, e.g. staging"),
 	}
-	if budgetKey == "" {
-		budgetKey = os.Getenv("AGENT_PAYMENT_BUDGET_KEY")
+}
+
+// serverConfig resolves config.json, environment variables and flags into
+// the server's configuration, exiting on invalid settings
+func (f *settings) serverConfig() mcp.Config {
+	api, err := loadAPISettings(*f.profileName)
+	if err != nil {
+		exitf("Invalid profile: %v", err)
+	}
+	profile := api.profile
+	apiKey, budgetKey := profile.APIKey, profile.BudgetKey
+
+	var limits ledger.Limits
+	var rules approval.Rules
+	var configOutputDir string
+	var configNormalize bool
+	var toolRules toolset.Rules
+	var toolSets map[string]toolset.Rules
+	var configToolSet string
+	var configOverrides string
+	var configPrompts string
+
+	if cfg := api.fileConfig; cfg != nil {
+		limits = cfg.SpendLimits
+		rules = cfg.Approval
+		configOutputDir = cfg.OutputDir
+		configNormalize = cfg.NormalizeArguments
+		toolRules = cfg.Tools
+		toolSets = cfg.ToolSets
+		configToolSet = cfg.ToolSet
+		configOverrides = cfg.OverridesFile
+		configPrompts = cfg.PromptsDir
+		log.Printf("Loaded configuration from %s", api.configPath)
+	}
+
+	var tlsConfig *tls.Config
+	if !profile.TLS.IsZero() {
+		tlsConfig, err = profile.TLS.ClientConfig()
+		if err != nil {
+			exitf("Invalid TLS settings: %v", err)
+		}
+	}
+	if profile.Name != "" {
+		log.Printf("Using profile %s: %s", profile.Name, profile.APIURL)
+	} else {
+		log.Printf("Using API %s", profile.APIURL)
 	}
 
 	if apiKey == "" || budgetKey == "" {
@@ -53,22 +169,277 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create server
-	server, err := mcp.NewServer(mcp.Config{
-		APIKey:    apiKey,
-		BudgetKey: budgetKey,
-	})
+	// Flags override spend caps from config.json
+	if *f.maxSessionSpend > 0 {
+		limits.Session = *f.maxSessionSpend
+	}
+	if *f.maxHourlySpend > 0 {
+		limits.Hour = *f.maxHourlySpend
+	}
+	if *f.maxDailySpend > 0 {
+		limits.Day = *f.maxDailySpend
+	}
+
+	// Flags add to the approval rules from config.json
+	if *f.approveAll {
+		rules.Always = true
+	}
+	if *f.approveAbove > 0 {
+		rules.Above = *f.approveAbove
+	}
+	for _, name := range strings.Split(*f.approveTools, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			rules.Tools = append(rules.Tools, name)
+		}
+	}
+	if rules.Enabled() {
+		log.Printf("Purchase approval: always=%v above=%.2f tools=%v", rules.Always, rules.Above, rules.Tools)
+	}
+
+	if *f.outputDir == "" {
+		*f.outputDir = configOutputDir
+	}
+
+	// Environment variables, then flags, override the tool selection from config.json
+	if v := os.Getenv("AGENT_PAYMENT_ALLOW_TOOLS"); v != "" {
+		toolRules.Allow = toolset.ParseList(v)
+	}
+	if v := os.Getenv("AGENT_PAYMENT_DENY_TOOLS"); v != "" {
+		toolRules.Deny = toolset.ParseList(v)
+	}
+	if v := os.Getenv("AGENT_PAYMENT_TOOLSET"); v != "" {
+		configToolSet = v
+	}
+	if *f.allowTools != "" {
+		toolRules.Allow = toolset.ParseList(*f.allowTools)
+	}
+	if *f.denyTools != "" {
+		toolRules.Deny = toolset.ParseList(*f.denyTools)
+	}
+	if *f.toolSet == "" {
+		*f.toolSet = configToolSet
+	}
+	toolFilter, err := toolset.New(toolRules, toolSets, *f.toolSet)
 	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
+		exitf("Invalid tool selection: %v", err)
+	}
+	if toolFilter != nil {
+		log.Printf("Exposing tools: %s", toolFilter)
+	}
+
+	if *f.overridesFile == "" {
+		*f.overridesFile = configOverrides
+	}
+	if *f.overridesFile == "" {
+		*f.overridesFile = annotation.DefaultPath()
+	}
+	overrides, err := annotation.Load(*f.overridesFile)
+	if err != nil {
+		exitf("Invalid tool overrides: %v", err)
+	}
+	if len(overrides) > 0 {
+		log.Printf("Loaded %d tool overrides from %s", len(overrides), *f.overridesFile)
 	}
 
+	if *f.promptsDir == "" {
+		*f.promptsDir = configPrompts
+	}
+	if *f.promptsDir == "" {
+		*f.promptsDir = prompt.DefaultDir()
+	}
+	prompts, err := prompt.LoadDir(*f.promptsDir)
+	if err != nil {
+		exitf("Invalid prompt templates: %v", err)
+	}
+	if prompts.Len() > 0 {
+		log.Printf("Loaded %d prompt templates from %s", prompts.Len(), *f.promptsDir)
+	}
+
+	spendLedger, err := ledger.New(limits, profile.StatePath(ledger.DefaultPath()))
+	if err != nil {
+		exitf("Failed to open spend ledger: %v", err)
+	}
+	if limits.Enabled() {
+		log.Printf("Local spend caps: session=%.2f hour=%.2f day=%.2f per-tool=%d (0 = none)",
+			limits.Session, limits.Hour, limits.Day, len(limits.PerTool))
+	}
+
+	return mcp.Config{
+		APIKey:             apiKey,
+		BudgetKey:          budgetKey,
+		APIURL:             profile.APIURL,
+		TLS:                tlsConfig,
+		Profile:            profile.Name,
+		CachePath:          profile.StatePath(cache.DefaultPath()),
+		NamesPath:          profile.StatePath(naming.DefaultPath()),
+		Tools:              toolFilter,
+		Overrides:          overrides,
+		Prompts:            prompts,
+		Ledger:             spendLedger,
+		Approval:           rules,
+		OutputDir:          *f.outputDir,
+		NormalizeArguments: *f.normalizeArgs || configNormalize,
+	}
+}
+
+// apiSettings is the API environment in effect, and where each setting came from
+type apiSettings struct {
+	configPath string         // config.json next to the binary
+	fileConfig *config.Config // nil when config.json is missing or invalid
+	configErr  error          // Why config.json couldn't be loaded (nil when it is missing)
+	profile    config.Profile // URL, keys and TLS settings, after environment fallbacks
+	sources    map[string]string
+}
+
+// loadAPISettings loads config.json and applies the profile and environment
+// variables, in the order the server uses them:
+//   - profile: --profile, then AGENT_PAYMENT_PROFILE, then "profile" in config.json
+//   - api_url: the profile when chosen with --profile, else AGENT_PAYMENT_API_URL,
+//     then the profile, then config.json
+//   - keys: the profile, then config.json, then AGENT_PAYMENT_API_KEY / AGENT_PAYMENT_BUDGET_KEY
+//
+// A config.json that can't be loaded is ignored (and reported by doctor).
+// On error the settings found so far are still returned, for doctor.
+func loadAPISettings(profileName string) (*apiSettings, error) {
+	api := &apiSettings{
+		profile: config.Profile{APIURL: config.DefaultAPIURL},
+		sources: map[string]string{"api_url": "default"},
+	}
+
+	// Try to load from config.json first (for .mcpb package installations)
+	exePath, err := os.Executable()
+	if err == nil {
+		api.configPath = filepath.Join(filepath.Dir(exePath), "config.json")
+		if _, err := os.Stat(api.configPath); err == nil {
+			api.fileConfig, api.configErr = config.Load(api.configPath)
+		}
+	}
+
+	// The profile picks the API environment: URL, keys and TLS settings
+	switch {
+	case profileName != "":
+		api.sources["profile"] = "--profile"
+	case os.Getenv("AGENT_PAYMENT_PROFILE") != "":
+		profileName = os.Getenv("AGENT_PAYMENT_PROFILE")
+		api.sources["profile"] = "AGENT_PAYMENT_PROFILE"
+	case api.fileConfig != nil && api.fileConfig.Profile != "":
+		api.sources["profile"] = "config.json"
+	}
+	if cfg := api.fileConfig; cfg != nil {
+		profile, err := cfg.ResolveProfile(profileName)
+		if err != nil {
+			return api, err
+		}
+		api.profile = profile
+		override := cfg.Profiles[api.profile.Name]
+		for setting, fromProfile := range map[string]bool{
+			"api_url":    override.APIURL != "",
+			"api_key":    override.APIKey != "",
+			"budget_key": override.BudgetKey != "",
+		} {
+			if fromProfile {
+				api.sources[setting] = "profile " + api.profile.Name
+			} else {
+				api.sources[setting] = "config.json"
+			}
+		}
+	} else if profileName != "" {
+		return api, fmt.Errorf("%q selected but no config.json was loaded", profileName)
+	}
+
+	// An explicit --profile picks the environment, so a leftover URL variable
+	// can't send one profile's keys to another deployment
+	switch v := os.Getenv("AGENT_PAYMENT_API_URL"); {
+	case v == "":
+	case api.sources["profile"] == "--profile":
+		if v != api.profile.APIURL {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring AGENT_PAYMENT_API_URL=%s: --profile %s uses %s\n", v, api.profile.Name, api.profile.APIURL)
+		}
+	default:
+		api.profile.APIURL = v
+		api.sources["api_url"] = "AGENT_PAYMENT_API_URL"
+	}
+
+	// Fall back to environment variables if config.json not found
+	if v := os.Getenv("AGENT_PAYMENT_API_KEY"); api.profile.APIKey == "" && v != "" {
+		api.profile.APIKey = v
+		api.sources["api_key"] = "AGENT_PAYMENT_API_KEY"
+	}
+	if v := os.Getenv("AGENT_PAYMENT_BUDGET_KEY"); api.profile.BudgetKey == "" && v != "" {
+		api.profile.BudgetKey = v
+		api.sources["budget_key"] = "AGENT_PAYMENT_BUDGET_KEY"
+	}
+	if api.profile.APIKey == "" {
+		delete(api.sources, "api_key")
+	}
+	if api.profile.BudgetKey == "" {
+		delete(api.sources, "budget_key")
+	}
+
+	return api, nil
+}
+
+// exitf reports a fatal error on stderr, where it shows even when logging is off
+func exitf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// runServe runs the MCP server until it is interrupted
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	transport := fs.String("transport", mcp.TransportStdio, "MCP transport: stdio or http")
+	listenAddr := fs.String("listen", mcp.DefaultListenAddr, "Listen address for the http transport")
+	httpToken := fs.String("http-token", "", "Bearer token http clients must send, required beyond loopback (default $AGENT_PAYMENT_HTTP_TOKEN)")
+	allowedOrigins := fs.String("allowed-origins", "", "Comma-separated browser origins allowed besides localhost")
+	refreshInterval := fs.Duration("refresh-interval", 15*time.Minute, "How often to re-fetch the tool catalog (0 disables)")
+	maxConcurrency := fs.Int("max-concurrency", mcp.DefaultMaxConcurrency, "Maximum number of requests handled concurrently")
+	f := addSettings(fs)
+	fs.Parse(args)
+
+	cfg := f.serverConfig()
+	cfg.Transport = *transport
+	cfg.ListenAddr = *listenAddr
+	cfg.HTTPToken = *httpToken
+	if cfg.HTTPToken == "" {
+		cfg.HTTPToken = os.Getenv("AGENT_PAYMENT_HTTP_TOKEN")
+	}
+	for _, origin := range strings.Split(*allowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+		}
+	}
+	cfg.MaxConcurrency = *maxConcurrency
+	cfg.RefreshInterval = *refreshInterval
+
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Setup signal handling for graceful shutdown
+	// Setup signal handling for graceful shutdown, including during the
+	// startup catalog fetch
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		log.Printf("Received signal %v, shutting down gracefully...", sig)
+		cancel()
+	}()
+
+	// Create server
+	server, err := mcp.NewServer(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+
+	// SIGHUP re-fetches the tool catalog without restarting
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			server.TriggerRefresh()
+		}
+	}()
 
 	// Run server in goroutine
 	errChan := make(chan error, 1)
@@ -78,9 +449,7 @@ func main() {
 
 	// Wait for shutdown signal or error
 	select {
-	case sig := <-sigChan:
-		log.Printf("Received signal %v, shutting down gracefully...", sig)
-		cancel()
+	case <-ctx.Done():
 	case err := <-errChan:
 		if err != nil {
 			log.Fatalf("Server error: %v", err)
@@ -2,4 +2,11 @@ module github.com/agentpmt/agent-payment-mcp-server
 
 go 1.23.0
 
-require github.com/modelcontextprotocol/go-sdk v0.1.0
+require (
+	github.com/Apoth3osis-ai/agent-payment-mcp/pkg v0.0.0
+	github.com/modelcontextprotocol/go-sdk v0.1.0
+)
+
+require github.com/tmaxmax/go-sse v0.11.0 // indirect
+
+replace github.com/Apoth3osis-ai/agent-payment-mcp/pkg => ../pkg
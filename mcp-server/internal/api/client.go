@@ -1,60 +1,82 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
 )
 
+const BaseURL = agentpmt.DefaultBaseURL
+
+// Catalog paging limits
 const (
-	BaseURL          = "https://api.agentpmt.com"
-	FetchEndpoint    = "/products/fetch"
-	PurchaseEndpoint = "/products/purchase"
+	DefaultPageSize = agentpmt.DefaultPageSize
+	// MaxCatalogPages guards against a misbehaving API reporting has_next_page forever,
+	// when it doesn't report how many tools there are
+	MaxCatalogPages = agentpmt.MaxPages
 )
 
 // Client handles API communication with Agent Payment API
+// It adapts the public agentpmt client to the types the MCP server uses.
 type Client struct {
-	apiKey    string
-	budgetKey string
-	baseURL   string
-	client    *http.Client
+	api *agentpmt.Client
 }
 
-// NewClient creates a new API client
-func NewClient(apiKey, budgetKey string) *Client {
+// NewClient creates a new API client; opts override its defaults
+func NewClient(apiKey, budgetKey string, opts ...agentpmt.Option) *Client {
+	opts = append([]agentpmt.Option{agentpmt.WithTimeout(30 * time.Second)}, opts...)
 	return &Client{
-		apiKey:    apiKey,
-		budgetKey: budgetKey,
-		baseURL:   BaseURL,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		api: agentpmt.New(apiKey, budgetKey, opts...),
 	}
 }
 
 // ToolDefinition represents a tool from the API
 type ToolDefinition struct {
-	Type     string       `json:"type"`
-	Function FunctionDef  `json:"function"`
+	Type     string      `json:"type"`
+	Function FunctionDef `json:"function"`
+	Pricing  *Pricing    `json:"x-pricing,omitempty"` // Catalog price, when published
+
+	// Annotations are the tool's MCP annotations, when the catalog publishes them
+	Annotations *annotation.Hints `json:"x-annotations,omitempty"`
+
+	// Examples are sample calls, turned into a usage prompt per tool
+	Examples []prompt.Example `json:"x-examples,omitempty"`
+
+	// OutputSchema describes the tool's output, when the catalog publishes one
+	OutputSchema json.RawMessage `json:"x-output-schema,omitempty"`
 }
 
+// Pricing is the catalog's advertised price for a tool
+type Pricing = agentpmt.Pricing
+
 // FunctionDef represents the function definition
-type FunctionDef struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description"`
-	Parameters  json.RawMessage `json:"parameters"`
-}
+type FunctionDef = agentpmt.Function
+
+// PaginationDetails contains pagination metadata from /products/fetch
+type PaginationDetails = agentpmt.PaginationDetails
 
 // FetchToolsResponse represents the response from /products/fetch
 type FetchToolsResponse struct {
 	Success bool              `json:"success"`
+	Details PaginationDetails `json:"details"`
 	Tools   []ToolDefinition  `json:"tools"`
 	Error   string            `json:"error,omitempty"`
 }
 
+// Catalog is the full tool catalog assembled from every page of /products/fetch
+type Catalog struct {
+	Tools      []ToolDefinition
+	TotalTools int  // Total reported by the API (0 if it didn't say)
+	Pages      int  // Pages fetched
+	Truncated  bool // True if paging stopped at the page limit with pages left
+}
+
 // PurchaseRequest represents a tool execution request
 type PurchaseRequest struct {
 	ProductID  string                 `json:"product_id"`
@@ -63,18 +85,42 @@ type PurchaseRequest struct {
 
 // PurchaseResponse represents the response from /products/purchase
 type PurchaseResponse struct {
-	Success         bool                   `json:"success"`
-	Response        PurchaseResponseData   `json:"response"`
-	PurchaseResult  string                 `json:"purchase_result,omitempty"`
-	PurchaseDetails interface{}            `json:"purchase_details,omitempty"`
-	Error           string                 `json:"error,omitempty"`
+	Success         bool                 `json:"success"`
+	Response        PurchaseResponseData `json:"response"`
+	PurchaseResult  string               `json:"purchase_result,omitempty"`
+	PurchaseDetails interface{}          `json:"purchase_details,omitempty"`
+	Cost            *float64             `json:"cost,omitempty"`
+	Error           string               `json:"error,omitempty"`
+}
+
+// Amount reports what the purchase cost, if the API said so
+// Looks at the top-level "cost" field, then at common keys in purchase_details.
+func (r *PurchaseResponse) Amount() (float64, bool) {
+	if r.Cost != nil {
+		return *r.Cost, true
+	}
+	details, ok := r.PurchaseDetails.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	for _, key := range []string{"cost", "amount", "price", "charged", "total"} {
+		switch v := details[key].(type) {
+		case float64:
+			return v, true
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
 }
 
 // PurchaseResponseData contains the nested response data
 type PurchaseResponseData struct {
-	StatusCode int                    `json:"status_code"`
-	Data       PurchaseDataWrapper    `json:"data"`
-	Success    bool                   `json:"success"`
+	StatusCode int                 `json:"status_code"`
+	Data       PurchaseDataWrapper `json:"data"`
+	Success    bool                `json:"success"`
 }
 
 // PurchaseDataWrapper contains the output
@@ -84,90 +130,126 @@ type PurchaseDataWrapper struct {
 }
 
 // FetchTools retrieves available tools from the API
-func (c *Client) FetchTools(page, pageSize int) (*FetchToolsResponse, error) {
-	url := fmt.Sprintf("%s%s?page=%d&page_size=%d", c.baseURL, FetchEndpoint, page, pageSize)
-
-	req, err := http.NewRequest("GET", url, nil)
+// API failures wrap an agentpmt.Error, classified by status and body.
+func (c *Client) FetchTools(ctx context.Context, page, pageSize int) (*FetchToolsResponse, error) {
+	resp, err := c.api.ListTools(ctx, page, pageSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("X-Budget-Key", c.budgetKey)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch tools: %w", err)
-	}
-	defer resp.Body.Close()
+	return &FetchToolsResponse{
+		Success: resp.Success,
+		Details: resp.Details,
+		Tools:   ToolDefinitions(resp.Tools),
+		Error:   resp.Error,
+	}, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// FetchAllTools retrieves every page of the catalog, following has_next_page
+// Paging stops one page past the total the API reports (MaxCatalogPages when it
+// reports none), or if a page comes back empty while claiming more pages exist,
+// so a broken API can't loop forever.
+func (c *Client) FetchAllTools(ctx context.Context, pageSize int) (*Catalog, error) {
+	catalog, err := c.api.FetchCatalog(ctx, pageSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var toolsResp FetchToolsResponse
-	if err := json.Unmarshal(body, &toolsResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	if !toolsResp.Success {
-		return nil, fmt.Errorf("API error: %s", toolsResp.Error)
-	}
-
-	return &toolsResp, nil
+	return &Catalog{
+		Tools:      ToolDefinitions(catalog.Tools),
+		TotalTools: catalog.TotalTools,
+		Pages:      catalog.Pages,
+		Truncated:  catalog.Truncated,
+	}, nil
 }
 
 // ExecuteTool executes a tool via the purchase endpoint
-func (c *Client) ExecuteTool(productID string, parameters map[string]interface{}) (*PurchaseResponse, error) {
-	reqBody := PurchaseRequest{
-		ProductID:  productID,
-		Parameters: parameters,
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
+// The request is bound to ctx, so cancelling ctx aborts the upstream call.
+// Transient failures are retried under one Idempotency-Key, so the purchase
+// is charged at most once. Failures wrap an agentpmt.Error.
+func (c *Client) ExecuteTool(ctx context.Context, productID string, parameters map[string]interface{}) (*PurchaseResponse, error) {
+	jsonParams, err := json.Marshal(parameters)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s%s", c.baseURL, PurchaseEndpoint)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("X-Budget-Key", c.budgetKey)
-
-	resp, err := c.client.Do(req)
+	resp, err := c.api.Purchase(ctx, agentpmt.PurchaseRequest{
+		ProductID:  productID,
+		Parameters: jsonParams,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute tool: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	// The agentpmt decoder reads both the flat and nested shapes; the output
+	// is kept where the rest of the server expects it
+	purchase := &PurchaseResponse{
+		Success: resp.Success,
+		Response: PurchaseResponseData{
+			StatusCode: resp.StatusCode,
+			Success:    resp.Success,
+			Data: PurchaseDataWrapper{
+				Success: resp.Success,
+				Output:  decodeJSON(resp.Output),
+			},
+		},
+		PurchaseResult:  resp.PurchaseResult,
+		PurchaseDetails: decodeJSON(resp.PurchaseDetails),
+		Cost:            resp.Cost,
+		Error:           resp.Error,
 	}
+	return purchase, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// ToolDefinitions converts catalog entries to this package's tool type
+func ToolDefinitions(tools []agentpmt.Tool) []ToolDefinition {
+	defs := make([]ToolDefinition, 0, len(tools))
+	for _, tool := range tools {
+		examples := make([]prompt.Example, 0, len(tool.Examples))
+		for _, example := range tool.Examples {
+			examples = append(examples, prompt.Example(example))
+		}
+		if len(examples) == 0 {
+			examples = nil
+		}
+		defs = append(defs, ToolDefinition{
+			Type:         tool.Type,
+			Function:     tool.Function,
+			Pricing:      tool.Pricing,
+			Annotations:  (*annotation.Hints)(tool.Annotations),
+			Examples:     examples,
+			OutputSchema: tool.OutputSchema,
+		})
 	}
+	return defs
+}
 
-	var purchaseResp PurchaseResponse
-	if err := json.Unmarshal(body, &purchaseResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+// CatalogTools converts tools back to the catalog's wire format, e.g. for the
+// catalog cache
+func CatalogTools(defs []ToolDefinition) []agentpmt.Tool {
+	tools := make([]agentpmt.Tool, 0, len(defs))
+	for _, def := range defs {
+		var examples []agentpmt.Example
+		for _, example := range def.Examples {
+			examples = append(examples, agentpmt.Example(example))
+		}
+		tools = append(tools, agentpmt.Tool{
+			Type:         def.Type,
+			Function:     def.Function,
+			Pricing:      def.Pricing,
+			Annotations:  (*agentpmt.Annotations)(def.Annotations),
+			Examples:     examples,
+			OutputSchema: def.OutputSchema,
+		})
 	}
+	return tools
+}
 
-	if !purchaseResp.Success {
-		return nil, fmt.Errorf("tool execution failed: %s", purchaseResp.Error)
+// decodeJSON decodes raw JSON into generic values (nil if absent or invalid)
+func decodeJSON(raw json.RawMessage) interface{} {
+	var v interface{}
+	if len(raw) > 0 {
+		json.Unmarshal(raw, &v)
 	}
-
-	return &purchaseResp, nil
+	return v
 }
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+)
+
+// catalogServer serves totalPages pages of one tool each
+func catalogServer(t *testing.T, totalPages int, alwaysMore bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if r.Header.Get("X-API-Key") != "test-key" {
+			t.Error("Missing or incorrect X-API-Key header")
+		}
+
+		resp := FetchToolsResponse{
+			Success: true,
+			Details: PaginationDetails{
+				TotalTools:  totalPages,
+				TotalPages:  totalPages,
+				HasNextPage: alwaysMore || page < totalPages,
+			},
+		}
+		if page <= totalPages {
+			resp.Tools = []ToolDefinition{{
+				Type:     "function",
+				Function: FunctionDef{Name: "prod-" + strconv.Itoa(page), Description: "Tool"},
+			}}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestFetchAllToolsFollowsPages(t *testing.T) {
+	server := catalogServer(t, 3, false)
+	defer server.Close()
+
+	client := NewClient("test-key", "test-budget", agentpmt.WithBaseURL(server.URL))
+
+	catalog, err := client.FetchAllTools(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("FetchAllTools() failed: %v", err)
+	}
+	if len(catalog.Tools) != 3 {
+		t.Errorf("Expected 3 tools, got %d", len(catalog.Tools))
+	}
+	if catalog.Pages != 3 || catalog.TotalTools != 3 || catalog.Truncated {
+		t.Errorf("Unexpected catalog metadata: %+v", catalog)
+	}
+}
+
+func TestFetchAllToolsStopsWithContext(t *testing.T) {
+	server := catalogServer(t, 3, false)
+	defer server.Close()
+
+	client := NewClient("test-key", "test-budget", agentpmt.WithBaseURL(server.URL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.FetchAllTools(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestFetchAllToolsStopsOnEmptyPage(t *testing.T) {
+	// API claims more pages forever but runs out of tools after page 2
+	server := catalogServer(t, 2, true)
+	defer server.Close()
+
+	client := NewClient("test-key", "test-budget", agentpmt.WithBaseURL(server.URL))
+
+	catalog, err := client.FetchAllTools(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("FetchAllTools() failed: %v", err)
+	}
+	if catalog.Pages != 3 || len(catalog.Tools) != 2 {
+		t.Errorf("Expected to stop at the empty page 3 with 2 tools, got %d pages, %d tools", catalog.Pages, len(catalog.Tools))
+	}
+}
+
+func TestFetchAllToolsPageLimitFollowsTotal(t *testing.T) {
+	// More pages than MaxCatalogPages, but the API reports how many tools there are
+	server := catalogServer(t, MaxCatalogPages+10, false)
+	defer server.Close()
+
+	client := NewClient("test-key", "test-budget", agentpmt.WithBaseURL(server.URL))
+
+	catalog, err := client.FetchAllTools(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("FetchAllTools() failed: %v", err)
+	}
+	if catalog.Truncated {
+		t.Error("Expected the whole catalog, got it marked truncated")
+	}
+	if len(catalog.Tools) != MaxCatalogPages+10 {
+		t.Errorf("Expected %d tools, got %d", MaxCatalogPages+10, len(catalog.Tools))
+	}
+}
+
+func TestPurchaseResponseAmount(t *testing.T) {
+	tests := []struct {
+		body   string
+		amount float64
+		ok     bool
+	}{
+		{`{"success":true,"cost":0.25}`, 0.25, true},
+		{`{"success":true,"purchase_details":{"amount":"0.10"}}`, 0.10, true},
+		{`{"success":true,"purchase_details":{"price":0.5}}`, 0.5, true},
+		{`{"success":true,"purchase_result":"ok"}`, 0, false},
+	}
+
+	for _, tt := range tests {
+		var resp PurchaseResponse
+		if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+			t.Fatalf("Unmarshal(%s) failed: %v", tt.body, err)
+		}
+		amount, ok := resp.Amount()
+		if amount != tt.amount || ok != tt.ok {
+			t.Errorf("Amount() for %s = %v, %v; want %v, %v", tt.body, amount, ok, tt.amount, tt.ok)
+		}
+	}
+}
+
+func TestExecuteToolRetriesWithSameIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(agentpmt.IdempotencyHeader))
+		switch len(keys) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"success":true,"cost":0.1}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", "budget-key", agentpmt.WithBaseURL(server.URL),
+		agentpmt.WithRetry(agentpmt.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}))
+
+	if _, err := client.ExecuteTool(context.Background(), "prod-1", nil); err != nil {
+		t.Fatalf("ExecuteTool() error = %v", err)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Expected 3 attempts sharing one key, got %q", keys)
+	}
+
+	// A new purchase gets a new key, and client errors aren't retried
+	keys = nil
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(agentpmt.IdempotencyHeader))
+		w.WriteHeader(http.StatusPaymentRequired)
+	})
+	_, err := client.ExecuteTool(context.Background(), "prod-1", nil)
+	if !errors.Is(err, agentpmt.ErrBudgetExceeded) || len(keys) != 1 {
+		t.Errorf("Expected one attempt and a budget error for a 402, got %d (%v)", len(keys), err)
+	}
+}
+
+func TestExecuteToolReadsBothResponseShapes(t *testing.T) {
+	for _, body := range []string{
+		`{"success":true,"output":{"sky":"sunny"}}`,
+		`{"success":true,"response":{"status_code":200,"success":true,"data":{"success":true,"output":{"sky":"sunny"}}}}`,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+
+		client := NewClient("test-key", "budget-key", agentpmt.WithBaseURL(server.URL))
+		resp, err := client.ExecuteTool(context.Background(), "prod-1", nil)
+		server.Close()
+		if err != nil {
+			t.Fatalf("ExecuteTool() error = %v", err)
+		}
+		output, _ := resp.Response.Data.Output.(map[string]interface{})
+		if output["sky"] != "sunny" {
+			t.Errorf("Expected the output from %s, got %+v", body, resp.Response)
+		}
+	}
+}
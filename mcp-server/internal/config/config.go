@@ -1,10 +1,18 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/toolset"
 )
 
 // Config holds all configuration for the MCP server
@@ -13,6 +21,149 @@ type Config struct {
 	BudgetKey  string `json:"budget_key"`
 	APIURL     string `json:"api_url"`
 	Auth       string `json:"auth,omitempty"`
+
+	// TLS adjusts how the API's certificate is checked and which client certificate is sent
+	TLS TLS `json:"tls,omitempty"`
+
+	// Profiles are named API environments (e.g. prod, staging, local); Profile
+	// picks one (--profile and AGENT_PAYMENT_PROFILE override it)
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+	Profile  string             `json:"profile,omitempty"`
+
+	// SpendLimits are local caps enforced before every purchase
+	SpendLimits ledger.Limits `json:"spend_limits,omitempty"`
+
+	// Approval decides which purchases the user must approve first
+	Approval approval.Rules `json:"approval,omitempty"`
+
+	// OutputDir is where outputs too large to send inline are written
+	OutputDir string `json:"output_dir,omitempty"`
+
+	// NormalizeArguments coerces, fills and trims arguments to fit the tool schema
+	NormalizeArguments bool `json:"normalize_arguments,omitempty"`
+
+	// Tools includes or excludes catalog tools by product ID, name or glob
+	Tools toolset.Rules `json:"tools,omitempty"`
+
+	// ToolSets are named tool selections; ToolSet picks one (--toolset overrides it)
+	ToolSets map[string]toolset.Rules `json:"toolsets,omitempty"`
+	ToolSet  string                   `json:"toolset,omitempty"`
+
+	// OverridesFile holds local titles, annotations and costs (default: tool-overrides.json)
+	OverridesFile string `json:"overrides_file,omitempty"`
+
+	// PromptsDir holds the team's prompt templates (default: prompts/ next to config.json)
+	PromptsDir string `json:"prompts_dir,omitempty"`
+}
+
+// DefaultAPIURL is the production AgentPMT API
+const DefaultAPIURL = "https://api.agentpmt.com"
+
+// Profile is one API environment
+// Fields left empty are taken from the top level of config.json.
+type Profile struct {
+	APIURL    string `json:"api_url,omitempty"`
+	APIKey    string `json:"api_key,omitempty"`
+	BudgetKey string `json:"budget_key,omitempty"`
+	TLS       TLS    `json:"tls,omitempty"`
+
+	// Name is the profile's name in config.json ("" for the top-level settings)
+	Name string `json:"-"`
+}
+
+// TLS holds certificate settings for talking to the API
+type TLS struct {
+	CAFile             string `json:"ca_file,omitempty"`              // PEM bundle trusted in addition to the system roots
+	CertFile           string `json:"cert_file,omitempty"`            // Client certificate, for mutual TLS
+	KeyFile            string `json:"key_file,omitempty"`             // Client certificate's private key
+	ServerName         string `json:"server_name,omitempty"`          // Name expected on the server certificate
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // Accept any certificate (local testing only)
+}
+
+// IsZero reports whether t leaves Go's default TLS settings alone
+func (t TLS) IsZero() bool {
+	return t == TLS{}
+}
+
+// ClientConfig builds the tls.Config described by t
+func (t TLS) ClientConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca_file %s", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// ResolveProfile returns the settings of the named profile, or of cfg.Profile
+// when name is empty, filled in from the top-level settings
+// With no profile selected the top-level settings are returned as they are.
+func (c *Config) ResolveProfile(name string) (Profile, error) {
+	if name == "" {
+		name = c.Profile
+	}
+
+	p := Profile{APIURL: c.APIURL, APIKey: c.APIKey, BudgetKey: c.BudgetKey, TLS: c.TLS}
+	if name != "" {
+		override, ok := c.Profiles[name]
+		if !ok {
+			return Profile{}, fmt.Errorf("unknown profile %q (configured: %s)", name, c.profileNames())
+		}
+		p.Name = name
+		if override.APIURL != "" {
+			p.APIURL = override.APIURL
+		}
+		if override.APIKey != "" {
+			p.APIKey = override.APIKey
+		}
+		if override.BudgetKey != "" {
+			p.BudgetKey = override.BudgetKey
+		}
+		if !override.TLS.IsZero() {
+			p.TLS = override.TLS
+		}
+	}
+
+	if p.APIURL == "" {
+		p.APIURL = DefaultAPIURL
+	}
+	return p, nil
+}
+
+// profileNames lists the configured profiles for error messages
+func (c *Config) profileNames() string {
+	if len(c.Profiles) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
 }
 
 // Load reads configuration from file
@@ -36,16 +187,28 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	// Validate required fields
-	if cfg.APIKey == "" {
+	// Validate required fields; with profiles, the keys may be set per profile
+	if cfg.APIKey == "" && len(cfg.Profiles) == 0 {
 		return nil, fmt.Errorf("api_key is required in config")
 	}
-	if cfg.BudgetKey == "" {
+	if cfg.BudgetKey == "" && len(cfg.Profiles) == 0 {
 		return nil, fmt.Errorf("budget_key is required in config")
 	}
 	if cfg.APIURL == "" {
-		cfg.APIURL = "https://api.agentpmt.com"
+		cfg.APIURL = DefaultAPIURL
 	}
 
 	return &cfg, nil
 }
+
+// StatePath returns path for this profile's local state (catalog cache, tool
+// names, spend ledger). Profiles pointing at another API than production get
+// their own files, e.g. tool-cache.staging.json, so test catalogs and spend
+// never mix with real ones.
+func (p Profile) StatePath(path string) string {
+	if p.Name == "" || p.APIURL == DefaultAPIURL || path == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + p.Name + ext
+}
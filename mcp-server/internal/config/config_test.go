@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestResolveProfile(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `{
+		"api_key": "prod-key",
+		"budget_key": "prod-budget",
+		"profile": "staging",
+		"profiles": {
+			"staging": {"api_url": "https://staging.example.com", "budget_key": "staging-budget"},
+			"local": {"api_url": "http://localhost:8000", "tls": {"insecure_skip_verify": true}}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// The profile named in config.json, filled in from the top level
+	p, err := cfg.ResolveProfile("")
+	if err != nil {
+		t.Fatalf("ResolveProfile() error = %v", err)
+	}
+	if p.Name != "staging" || p.APIURL != "https://staging.example.com" || p.APIKey != "prod-key" || p.BudgetKey != "staging-budget" {
+		t.Errorf("Unexpected staging profile %+v", p)
+	}
+
+	// An explicit name wins over config.json's choice
+	p, err = cfg.ResolveProfile("local")
+	if err != nil || p.APIURL != "http://localhost:8000" || !p.TLS.InsecureSkipVerify {
+		t.Errorf("Unexpected local profile %+v, %v", p, err)
+	}
+
+	if _, err := cfg.ResolveProfile("prod"); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+
+	// Without profiles the top-level settings apply, defaulting to production
+	cfg, err = Load(writeConfig(t, `{"api_key": "k", "budget_key": "b"}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	p, _ = cfg.ResolveProfile("")
+	if p.Name != "" || p.APIURL != DefaultAPIURL || p.APIKey != "k" {
+		t.Errorf("Unexpected default profile %+v", p)
+	}
+}
+
+func TestProfileStatePath(t *testing.T) {
+	tests := []struct {
+		profile Profile
+		want    string
+	}{
+		{Profile{APIURL: DefaultAPIURL}, "dir/tool-cache.json"},
+		{Profile{Name: "prod", APIURL: DefaultAPIURL}, "dir/tool-cache.json"},
+		{Profile{Name: "staging", APIURL: "https://staging.example.com"}, "dir/tool-cache.staging.json"},
+	}
+	for _, tt := range tests {
+		if got := tt.profile.StatePath("dir/tool-cache.json"); got != tt.want {
+			t.Errorf("StatePath() for %q = %s, want %s", tt.profile.Name, got, tt.want)
+		}
+	}
+}
+
+func TestTLSClientConfig(t *testing.T) {
+	cfg, err := TLS{ServerName: "api.internal", InsecureSkipVerify: true}.ClientConfig()
+	if err != nil || cfg.ServerName != "api.internal" || !cfg.InsecureSkipVerify {
+		t.Errorf("ClientConfig() = %+v, %v", cfg, err)
+	}
+
+	if _, err := (TLS{CAFile: writeConfig(t, "not a certificate")}).ClientConfig(); err == nil {
+		t.Error("Expected an error for a CA file without certificates")
+	}
+}
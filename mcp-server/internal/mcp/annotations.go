@@ -0,0 +1,25 @@
+package mcp
+
+import (
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+// annotate works out a tool's title, MCP annotations and cost from the
+// catalog and the local override file
+func (s *Server) annotate(toolDef api.ToolDefinition, mcpToolName, displayName string) annotation.Tool {
+	var cost *annotation.Cost
+	if p := toolDef.Pricing; p != nil {
+		cost = &annotation.Cost{Price: p.Cost, Currency: p.Currency, Unit: p.Unit}
+	}
+	override := s.overrides.Find(toolDef.Function.Name, mcpToolName)
+	return annotation.Resolve(displayName, toolDef.Annotations, cost, override)
+}
+
+// annotatedPrice returns the price a tool is annotated with, if any
+func annotatedPrice(a annotation.Tool) *price {
+	if a.Cost == nil {
+		return nil
+	}
+	return &price{Amount: a.Cost.Price, Currency: a.Cost.Currency, Unit: a.Cost.Unit, Source: a.CostSource}
+}
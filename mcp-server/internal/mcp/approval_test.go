@@ -0,0 +1,101 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+)
+
+func TestToolsCallAsksForApprovalViaElicitation(t *testing.T) {
+	server := newTestServer()
+	server.rawTools[0].InputSchema = json.RawMessage(`{"type":"object","properties":{"q":{"type":"string"}}}`)
+	server.workers = make(chan struct{}, 2)
+	server.approvalRules = approval.Rules{Always: true}
+	server.tokens = approval.NewTokens(0)
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.serveStream(context.Background(), inR, outW)
+		outW.Close()
+	}()
+
+	lines := bufio.NewScanner(outR)
+	readMessage := func() map[string]interface{} {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("Expected a message from the server: %v", lines.Err())
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+			t.Fatalf("Invalid message %s: %v", lines.Text(), err)
+		}
+		return msg
+	}
+
+	fmt.Fprintln(inW, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{"elicitation":{}},"clientInfo":{"name":"test-client"}}}`)
+	readMessage()
+
+	fmt.Fprintln(inW, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"test-tool","arguments":{"q":"x"}}}`)
+
+	elicit := readMessage()
+	if elicit["method"] != "elicitation/create" {
+		t.Fatalf("Expected elicitation/create, got %v", elicit)
+	}
+	message := elicit["params"].(map[string]interface{})["message"].(string)
+	if !strings.Contains(message, "test-tool") || !strings.Contains(message, `"q": "x"`) {
+		t.Errorf("Expected the prompt to show the tool and arguments, got %q", message)
+	}
+
+	fmt.Fprintf(inW, `{"jsonrpc":"2.0","id":%q,"result":{"action":"decline"}}`+"\n", elicit["id"])
+
+	resp := readMessage()
+	result := resp["result"].(map[string]interface{})
+	if resp["id"] != float64(2) || result["isError"] != true {
+		t.Fatalf("Expected an isError result for request 2, got %v", resp)
+	}
+	if text := fmt.Sprint(result["content"]); !strings.Contains(text, "declined") {
+		t.Errorf("Expected a declined message, got %s", text)
+	}
+
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Errorf("serveStream() failed: %v", err)
+	}
+}
+
+func TestApprovalFallsBackToConfirmationToken(t *testing.T) {
+	server := newTestServer()
+	server.approvalRules = approval.Rules{Tools: []string{"test-tool"}}
+	server.tokens = approval.NewTokens(0)
+	sess := newSession("test") // No elicitation capability
+
+	args := map[string]interface{}{"q": "x"}
+	refusal := server.checkApproval(context.Background(), sess, "test-tool", args)
+	if !strings.Contains(refusal, approval.TokenArgument) {
+		t.Fatalf("Expected a confirmation token in the refusal, got %q", refusal)
+	}
+
+	token := refusal[strings.Index(refusal, approval.TokenArgument)+len(approval.TokenArgument)+4:]
+	token = token[:strings.Index(token, `"`)]
+
+	args = map[string]interface{}{"q": "x", approval.TokenArgument: token}
+	if refusal := server.checkApproval(context.Background(), sess, "test-tool", args); refusal != "" {
+		t.Fatalf("Expected the token to approve the call, got %q", refusal)
+	}
+	if _, exists := args[approval.TokenArgument]; exists {
+		t.Error("Expected the token argument to be removed before the purchase")
+	}
+
+	// Tools not covered by the rules are never held up
+	if refusal := server.checkApproval(context.Background(), sess, "other-tool", map[string]interface{}{}); refusal != "" {
+		t.Errorf("Expected other tools to pass, got %q", refusal)
+	}
+}
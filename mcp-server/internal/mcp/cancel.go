@@ -0,0 +1,20 @@
+package mcp
+
+import (
+	"encoding/json"
+	"log"
+)
+
+// handleCancelled handles notifications/cancelled from the client
+func (s *Server) handleCancelled(sess *session, params json.RawMessage) {
+	var cancelParams struct {
+		RequestID interface{} `json:"requestId"`
+		Reason    string      `json:"reason,omitempty"`
+	}
+	if err := json.Unmarshal(params, &cancelParams); err != nil || cancelParams.RequestID == nil {
+		log.Printf("Ignoring malformed notifications/cancelled: %s", string(params))
+		return
+	}
+
+	sess.inflight.Abort(cancelParams.RequestID, cancelParams.Reason)
+}
@@ -0,0 +1,96 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/inflight"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+func TestCancelledNotificationAbortsRequest(t *testing.T) {
+	server := newTestServer()
+	sess := newSession("test")
+
+	ctx, call, done := sess.inflight.Start(context.Background(), float64(7), "tools/call")
+	defer done()
+
+	server.handleMessage(context.Background(), sess, JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "notifications/cancelled",
+		Params:  json.RawMessage(`{"requestId":7,"reason":"user aborted"}`),
+	})
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("Expected request context to be cancelled")
+	}
+	if !call.Cancelled() {
+		t.Error("Expected call to be marked cancelled")
+	}
+	if call.Phase() != inflight.Pending {
+		t.Errorf("Expected phase %s, got %s", inflight.Pending, call.Phase())
+	}
+}
+
+func TestDispatchSuppressesResponseForCancelledRequest(t *testing.T) {
+	server := newTestServer()
+	server.workers = make(chan struct{}) // No free slots: the request stays queued
+	sess := newSession("test")
+
+	result := make(chan *JSONRPCResponse, 1)
+	go func() {
+		result <- server.dispatch(context.Background(), sess, JSONRPCRequest{JSONRPC: "2.0", ID: "abc", Method: "ping"})
+	}()
+
+	// Wait for the request to be registered, then cancel it
+	for sess.inflight.Cancel("abc") == nil {
+		runtime.Gosched()
+	}
+
+	if resp := <-result; resp != nil {
+		t.Errorf("Expected no response for a cancelled request, got %+v", resp)
+	}
+}
+
+func TestServeStreamCancelRightBehindRequest(t *testing.T) {
+	var purchases atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		purchases.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"output":"done"}`))
+	}))
+	defer upstream.Close()
+
+	server := newTestServer()
+	server.apiClient = api.NewClient("api-key", "budget-key", agentpmt.WithBaseURL(upstream.URL))
+
+	// The cancellation is read before the request's goroutine gets going
+	var input strings.Builder
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&input, `{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"test-tool","arguments":{}}}`+"\n", i)
+		fmt.Fprintf(&input, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":%d}}`+"\n", i)
+	}
+
+	var out bytes.Buffer
+	if err := server.serveStream(context.Background(), strings.NewReader(input.String()), &out); err != nil {
+		t.Fatalf("serveStream() failed: %v", err)
+	}
+
+	if n := purchases.Load(); n != 0 {
+		t.Errorf("Expected no purchases, got %d", n)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no responses to cancelled requests, got %s", out.String())
+	}
+}
@@ -0,0 +1,508 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// HTTPEndpoint is the path the Streamable HTTP transport is served on
+	HTTPEndpoint = "/mcp"
+
+	// SessionHeader carries the MCP session ID on every request after initialize
+	SessionHeader = "Mcp-Session-Id"
+
+	// ProtocolVersionHeader carries the negotiated protocol version after initialize
+	ProtocolVersionHeader = "MCP-Protocol-Version"
+
+	// DefaultListenAddr keeps the HTTP transport on this machine unless told otherwise
+	DefaultListenAddr = "127.0.0.1:8080"
+
+	// maxHTTPSessions caps open sessions; the least recently used is evicted past it
+	maxHTTPSessions = 100
+
+	// sessionIdleTimeout ends sessions that sent nothing and hold no SSE stream
+	sessionIdleTimeout = 30 * time.Minute
+
+	// maxHTTPBodySize limits the size of a single POSTed JSON-RPC payload
+	maxHTTPBodySize = 4 * 1024 * 1024
+
+	// sseKeepAlive is how often an idle SSE stream receives a comment line
+	sseKeepAlive = 25 * time.Second
+)
+
+// httpSession tracks one MCP client connected over Streamable HTTP
+type httpSession struct {
+	*session
+	messages chan []byte  // Server-initiated messages for the SSE stream
+	streams  atomic.Int32 // Open SSE streams draining messages
+	lastSeen atomic.Int64 // Unix nanoseconds of the last request naming the session
+	mu       sync.Mutex
+	closed   bool
+}
+
+// touch records that the client used the session just now
+func (h *httpSession) touch() {
+	h.lastSeen.Store(time.Now().UnixNano())
+}
+
+// idle reports whether the session has gone unused since before cutoff
+// A session with an open SSE stream is never idle.
+func (h *httpSession) idle(cutoff time.Time) bool {
+	return h.streams.Load() == 0 && h.lastSeen.Load() < cutoff.UnixNano()
+}
+
+// send queues a server-initiated message for the session's SSE stream
+// Messages are dropped if no stream is draining the queue.
+func (h *httpSession) send(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return fmt.Errorf("session closed")
+	}
+	select {
+	case h.messages <- data:
+		return nil
+	default:
+		return fmt.Errorf("message queue full")
+	}
+}
+
+// close ends the session's SSE stream
+func (h *httpSession) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.messages)
+	}
+}
+
+// httpTransport serves the MCP Streamable HTTP transport
+// See https://modelcontextprotocol.io/specification/2025-06-18/basic/transports
+type httpTransport struct {
+	server      *Server
+	sessions    map[string]*httpSession
+	sessionsMux sync.RWMutex
+
+	maxSessions int
+	idleTimeout time.Duration
+}
+
+// newHTTPTransport creates the HTTP transport for a server
+func newHTTPTransport(s *Server) *httpTransport {
+	return &httpTransport{
+		server:      s,
+		sessions:    make(map[string]*httpSession),
+		maxSessions: maxHTTPSessions,
+		idleTimeout: sessionIdleTimeout,
+	}
+}
+
+// HTTPHandler returns an http.Handler serving MCP Streamable HTTP on HTTPEndpoint
+func (s *Server) HTTPHandler() http.Handler {
+	return newHTTPTransport(s).handler()
+}
+
+// handler mounts the transport on HTTPEndpoint
+func (t *httpTransport) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(HTTPEndpoint, t)
+	return mux
+}
+
+// HandleHTTPTransport serves MCP Streamable HTTP on addr until ctx is cancelled
+// Listening beyond loopback requires a bearer token, so the server's budget
+// can't be spent by anyone who can reach the port.
+func (s *Server) HandleHTTPTransport(ctx context.Context, addr string) error {
+	if s.httpToken == "" && !isLoopback(addr) {
+		return fmt.Errorf("refusing to serve HTTP on %s without a bearer token: set --http-token or AGENT_PAYMENT_HTTP_TOKEN, or listen on %s", addr, DefaultListenAddr)
+	}
+
+	t := newHTTPTransport(s)
+	go t.expireSessions(ctx)
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           t.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- httpServer.ListenAndServe()
+	}()
+
+	log.Printf("Serving MCP Streamable HTTP on %s%s", addr, HTTPEndpoint)
+
+	select {
+	case err := <-errChan:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("http server error: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return httpServer.Shutdown(shutdownCtx)
+	}
+}
+
+// ServeHTTP checks the caller, then routes requests by method as required by
+// the transport spec
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Browsers send Origin: a page on another site must not reach a local server
+	if origin := r.Header.Get("Origin"); origin != "" && !t.server.originAllowed(origin) {
+		log.Printf("Refusing HTTP request from origin %s", origin)
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if !t.server.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="agent-payment"`)
+		http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+	// Requests after initialize name the negotiated version; absent means 2025-03-26
+	if version := r.Header.Get(ProtocolVersionHeader); version != "" && !isSupportedVersion(version) {
+		http.Error(w, fmt.Sprintf("unsupported %s %q: supported versions are %s",
+			ProtocolVersionHeader, version, strings.Join(supportedVersions, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		t.handlePost(w, r)
+	case http.MethodGet:
+		t.handleGet(w, r)
+	case http.MethodDelete:
+		t.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePost handles one JSON-RPC message or a batch of them
+func (t *httpTransport) handlePost(w http.ResponseWriter, r *http.Request) {
+	// Clients must accept both response forms the spec allows
+	if accept := r.Header.Get("Accept"); !accepts(accept, "application/json") || !accepts(accept, "text/event-stream") {
+		http.Error(w, "Accept must include application/json and text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHTTPBodySize))
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, nil, -32700, "failed to read request body")
+		return
+	}
+
+	// Accept both a single message and a JSON-RPC batch
+	var requests []JSONRPCRequest
+	batch := len(body) > 0 && strings.HasPrefix(strings.TrimSpace(string(body)), "[")
+	if batch {
+		err = json.Unmarshal(body, &requests)
+	} else {
+		var req JSONRPCRequest
+		err = json.Unmarshal(body, &req)
+		requests = []JSONRPCRequest{req}
+	}
+	if err != nil || len(requests) == 0 {
+		writeHTTPError(w, http.StatusBadRequest, nil, -32700, "Parse error")
+		return
+	}
+
+	// initialize opens a new session; everything else must belong to one
+	isInit := len(requests) == 1 && requests[0].Method == "initialize"
+	var session *httpSession
+	if isInit {
+		session = t.createSession()
+		w.Header().Set(SessionHeader, session.id)
+	} else {
+		var status int
+		session, status = t.lookupSession(r)
+		if session == nil {
+			writeHTTPError(w, status, nil, -32600, "missing or unknown "+SessionHeader)
+			return
+		}
+	}
+
+	var responses []*JSONRPCResponse
+	for _, req := range requests {
+		// A client disconnect cancels its in-flight requests
+		if resp := t.server.dispatch(r.Context(), session.session, req); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+
+	// Notifications and responses only: acknowledge without a body
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	if batch {
+		err = encoder.Encode(responses)
+	} else {
+		err = encoder.Encode(responses[0])
+	}
+	if err != nil {
+		log.Printf("Error encoding HTTP response: %v", err)
+	}
+}
+
+// handleGet opens an SSE stream for server-initiated messages
+func (t *httpTransport) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r.Header.Get("Accept"), "text/event-stream") {
+		http.Error(w, "Accept must include text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	session, status := t.lookupSession(r)
+	if session == nil {
+		http.Error(w, "missing or unknown "+SessionHeader, status)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	session.streams.Add(1)
+	defer session.streams.Add(-1)
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-session.messages:
+			if !ok {
+				// Session was terminated
+				return
+			}
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// handleDelete terminates a session at the client's request
+func (t *httpTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	session, status := t.lookupSession(r)
+	if session == nil {
+		http.Error(w, "missing or unknown "+SessionHeader, status)
+		return
+	}
+
+	t.sessionsMux.Lock()
+	t.endSession(session)
+	t.sessionsMux.Unlock()
+
+	log.Printf("HTTP session %s terminated by client", session.id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// endSession forgets a session and closes its SSE stream; sessionsMux must be held
+func (t *httpTransport) endSession(session *httpSession) {
+	delete(t.sessions, session.id)
+	t.server.removeSession(session.session)
+	session.close()
+}
+
+// expireSessions ends idle sessions every minute until ctx is cancelled
+func (t *httpTransport) expireSessions(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.sessionsMux.Lock()
+			t.expireIdle()
+			t.sessionsMux.Unlock()
+		}
+	}
+}
+
+// expireIdle ends sessions idle for longer than idleTimeout; sessionsMux must be held
+func (t *httpTransport) expireIdle() {
+	cutoff := time.Now().Add(-t.idleTimeout)
+	for _, session := range t.sessions {
+		if session.idle(cutoff) {
+			t.endSession(session)
+			log.Printf("HTTP session %s expired after %s idle", session.id, t.idleTimeout)
+		}
+	}
+}
+
+// evictOldest ends the least recently used sessions until there is room for
+// one more; sessionsMux must be held
+func (t *httpTransport) evictOldest() {
+	if len(t.sessions) < t.maxSessions {
+		return
+	}
+	sessions := make([]*httpSession, 0, len(t.sessions))
+	for _, session := range t.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].lastSeen.Load() < sessions[j].lastSeen.Load()
+	})
+	for _, session := range sessions[:len(sessions)-t.maxSessions+1] {
+		t.endSession(session)
+		log.Printf("HTTP session %s evicted: %d sessions open", session.id, t.maxSessions)
+	}
+}
+
+// createSession registers a new session with a random ID
+func (t *httpTransport) createSession() *httpSession {
+	session := &httpSession{
+		session:  newSession(newSessionID()),
+		messages: make(chan []byte, 64),
+	}
+	session.notify = session.send
+	// Server-initiated requests need an SSE stream to reach the client
+	session.canRequest = func() bool { return session.streams.Load() > 0 }
+	session.touch()
+
+	t.sessionsMux.Lock()
+	t.expireIdle()
+	t.evictOldest()
+	t.sessions[session.id] = session
+	t.server.addSession(session.session)
+	t.sessionsMux.Unlock()
+
+	log.Printf("HTTP session %s started", session.id)
+	return session
+}
+
+// lookupSession finds the session named by the request header
+// Returns the HTTP status to report when the session is missing (400) or unknown (404)
+func (t *httpTransport) lookupSession(r *http.Request) (*httpSession, int) {
+	id := r.Header.Get(SessionHeader)
+	if id == "" {
+		return nil, http.StatusBadRequest
+	}
+
+	t.sessionsMux.RLock()
+	session, exists := t.sessions[id]
+	t.sessionsMux.RUnlock()
+
+	if !exists {
+		return nil, http.StatusNotFound
+	}
+	session.touch()
+	return session, http.StatusOK
+}
+
+// authorized reports whether the request carries the configured bearer token
+func (s *Server) authorized(r *http.Request) bool {
+	if s.httpToken == "" {
+		return true
+	}
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && subtle.ConstantTimeCompare([]byte(token), []byte(s.httpToken)) == 1
+}
+
+// originAllowed reports whether a browser origin may call the server: pages
+// served from this machine, and those listed in Config.AllowedOrigins
+func (s *Server) originAllowed(origin string) bool {
+	for _, allowed := range s.allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && isLoopbackHost(u.Hostname())
+}
+
+// isLoopback reports whether a listen address only accepts local connections
+// An empty host (":8080") listens on every interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && isLoopbackHost(host)
+}
+
+// isLoopbackHost reports whether a host name or IP is this machine
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// accepts reports whether an Accept header allows a media type, directly or by wildcard
+func accepts(header, mediaType string) bool {
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, part := range strings.Split(header, ",") {
+		accepted, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if accepted == mediaType || accepted == "*/*" || accepted == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// newSessionID returns a cryptographically random, visible-ASCII session ID
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// writeHTTPError writes a JSON-RPC error body with the given HTTP status
+func writeHTTPError(w http.ResponseWriter, status int, id interface{}, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: map[string]interface{}{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
@@ -0,0 +1,273 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+// newTestServer creates a server with an in-memory catalog and no API access
+func newTestServer() *Server {
+	return &Server{
+		tools:    make(map[string]*api.ToolDefinition),
+		nameToID: map[string]string{"test-tool": "prod-1"},
+		rawTools: []ToolWithRawSchema{
+			{Name: "test-tool", Description: "Test Tool — A test", InputSchema: json.RawMessage(`{"type":"object","properties":{}}`)},
+		},
+	}
+}
+
+func postJSON(t *testing.T, url, sessionID, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(SessionHeader, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	return resp
+}
+
+func TestHTTPInitializeCreatesSession(t *testing.T) {
+	ts := httptest.NewServer(newTestServer().HTTPHandler())
+	defer ts.Close()
+
+	resp := postJSON(t, ts.URL+HTTPEndpoint, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get(SessionHeader) == "" {
+		t.Fatal("Expected session ID header on initialize response")
+	}
+
+	var out JSONRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if out.Error != nil {
+		t.Fatalf("Unexpected error: %v", out.Error)
+	}
+}
+
+func TestHTTPToolsListRequiresSession(t *testing.T) {
+	ts := httptest.NewServer(newTestServer().HTTPHandler())
+	defer ts.Close()
+
+	resp := postJSON(t, ts.URL+HTTPEndpoint, "", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 without session, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, ts.URL+HTTPEndpoint, "bogus", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown session, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPSessionLifecycle(t *testing.T) {
+	ts := httptest.NewServer(newTestServer().HTTPHandler())
+	defer ts.Close()
+
+	resp := postJSON(t, ts.URL+HTTPEndpoint, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	resp.Body.Close()
+	sessionID := resp.Header.Get(SessionHeader)
+
+	// Notifications are acknowledged without a body
+	resp = postJSON(t, ts.URL+HTTPEndpoint, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 for notification, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, ts.URL+HTTPEndpoint, sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	var out struct {
+		Result struct {
+			Tools []ToolWithRawSchema `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("Failed to decode tools/list: %v", err)
+	}
+	resp.Body.Close()
+	// The catalog, followed by the quote meta-tool
+	if len(out.Result.Tools) != 2 || out.Result.Tools[0].Name != "test-tool" || out.Result.Tools[1].Name != QuoteToolName {
+		t.Errorf("Unexpected tools: %+v", out.Result.Tools)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+HTTPEndpoint, nil)
+	req.Header.Set(SessionHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected 204 on DELETE, got %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, ts.URL+HTTPEndpoint, sessionID, `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 after session termination, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPBatch(t *testing.T) {
+	ts := httptest.NewServer(newTestServer().HTTPHandler())
+	defer ts.Close()
+
+	resp := postJSON(t, ts.URL+HTTPEndpoint, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	resp.Body.Close()
+	sessionID := resp.Header.Get(SessionHeader)
+
+	resp = postJSON(t, ts.URL+HTTPEndpoint, sessionID, `[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":2,"method":"tools/list"}]`)
+	defer resp.Body.Close()
+
+	var out []JSONRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("Failed to decode batch response: %v", err)
+	}
+	if len(out) != 2 {
+		t.Errorf("Expected 2 responses for batch with one notification, got %d", len(out))
+	}
+}
+
+func TestHTTPChecksOriginAndToken(t *testing.T) {
+	server := newTestServer()
+	server.httpToken = "secret"
+	server.allowedOrigins = []string{"https://app.example.com"}
+	ts := httptest.NewServer(server.HTTPHandler())
+	defer ts.Close()
+
+	initialize := func(origin, token string) int {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+HTTPEndpoint,
+			strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, tc := range []struct {
+		origin, token string
+		want          int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"", "wrong", http.StatusUnauthorized},
+		{"", "secret", http.StatusOK},
+		{"http://localhost:5173", "secret", http.StatusOK},
+		{"https://app.example.com", "secret", http.StatusOK},
+		{"https://evil.example.com", "secret", http.StatusForbidden},
+		{"null", "secret", http.StatusForbidden},
+	} {
+		if got := initialize(tc.origin, tc.token); got != tc.want {
+			t.Errorf("origin %q, token %q: expected %d, got %d", tc.origin, tc.token, tc.want, got)
+		}
+	}
+}
+
+func TestHTTPChecksAcceptAndProtocolVersion(t *testing.T) {
+	ts := httptest.NewServer(newTestServer().HTTPHandler())
+	defer ts.Close()
+
+	resp := postJSON(t, ts.URL+HTTPEndpoint, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	resp.Body.Close()
+	sessionID := resp.Header.Get(SessionHeader)
+
+	post := func(accept, version string) int {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+HTTPEndpoint, strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		req.Header.Set(SessionHeader, sessionID)
+		if version != "" {
+			req.Header.Set(ProtocolVersionHeader, version)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post("application/json", ""); got != http.StatusNotAcceptable {
+		t.Errorf("Expected 406 without text/event-stream, got %d", got)
+	}
+	if got := post("*/*", "2025-06-18"); got != http.StatusOK {
+		t.Errorf("Expected 200 for */* and a supported version, got %d", got)
+	}
+	if got := post("application/json, text/event-stream", "1999-01-01"); got != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported protocol version, got %d", got)
+	}
+}
+
+func TestHTTPSessionsExpireAndAreCapped(t *testing.T) {
+	transport := newHTTPTransport(newTestServer())
+	transport.maxSessions = 2
+
+	first := transport.createSession()
+	first.lastSeen.Store(time.Now().Add(-time.Minute).UnixNano())
+	second := transport.createSession()
+	third := transport.createSession()
+
+	// The least recently used session makes room, and its stream is closed
+	if _, open := <-first.messages; open {
+		t.Error("Expected the evicted session's messages to be closed")
+	}
+	if len(transport.sessions) != 2 || transport.sessions[second.id] == nil || transport.sessions[third.id] == nil {
+		t.Fatalf("Expected the two newest sessions to remain, got %d", len(transport.sessions))
+	}
+
+	// Idle sessions expire, unless an SSE stream is open
+	third.streams.Add(1)
+	transport.idleTimeout = 0
+	transport.sessionsMux.Lock()
+	transport.expireIdle()
+	transport.sessionsMux.Unlock()
+	if len(transport.sessions) != 1 || transport.sessions[third.id] == nil {
+		t.Errorf("Expected only the streaming session to remain, got %d", len(transport.sessions))
+	}
+}
+
+func TestHTTPNeedsTokenBeyondLoopback(t *testing.T) {
+	err := newTestServer().HandleHTTPTransport(context.Background(), ":0")
+	if err == nil || !strings.Contains(err.Error(), "bearer token") {
+		t.Errorf("Expected a listener on every interface to need a token, got %v", err)
+	}
+
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true, "localhost:8080": true, "[::1]:8080": true,
+		":8080": false, "0.0.0.0:8080": false, "10.0.0.5:8080": false,
+	} {
+		if got := isLoopback(addr); got != want {
+			t.Errorf("isLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+)
+
+// ToolInfo is a listed tool as agents see it in tools/list, plus its product ID
+// It backs the operator CLI (list-tools, describe) so the terminal shows the
+// same names and sanitized schemas as MCP clients.
+type ToolInfo struct {
+	ToolWithRawSchema
+	ProductID string `json:"productId"`
+	Price     string `json:"price,omitempty"` // Expected price, from the catalog or overrides
+}
+
+// Tools returns the registered catalog tools in catalog order
+func (s *Server) Tools() []ToolInfo {
+	s.toolsMux.RLock()
+	defer s.toolsMux.RUnlock()
+
+	tools := make([]ToolInfo, 0, len(s.rawTools))
+	for _, raw := range s.rawTools {
+		info := ToolInfo{ToolWithRawSchema: raw, ProductID: s.nameToID[raw.Name]}
+		if raw.price != nil {
+			info.Price = formatPrice(raw.price)
+		}
+		tools = append(tools, info)
+	}
+	return tools
+}
+
+// Tool looks up a tool by any name tools/call accepts: its MCP name, display
+// name, former name or product ID
+func (s *Server) Tool(name string) (ToolInfo, bool) {
+	name = s.canonicalName(name)
+	for _, tool := range s.Tools() {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return ToolInfo{}, false
+}
+
+// CallTool runs a tools/call on behalf of the operator and returns its result
+// The call goes through the same validation, spend caps, approval and ledger
+// as an agent's. approved stands in for the user's approval when the rules
+// require it. A failed call is reported in the result (isError), not as err;
+// err is only set when the request itself is rejected.
+func (s *Server) CallTool(ctx context.Context, name string, args map[string]interface{}, approved bool) (map[string]interface{}, error) {
+	params, err := json.Marshal(map[string]interface{}{"name": name, "arguments": args})
+	if err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	sess := newSession("cli")
+	sess.client.Store("agent-payment-server cli")
+	sess.approved = approved
+
+	resp := s.handleToolsCall(ctx, sess, 1, params)
+	if resp.Error != nil {
+		if e, ok := resp.Error.(map[string]interface{}); ok {
+			return nil, fmt.Errorf("%v", e["message"])
+		}
+		return nil, fmt.Errorf("%v", resp.Error)
+	}
+
+	// Results are maps of content blocks; round-trip them to plain JSON values
+	var result map[string]interface{}
+	data, err := json.Marshal(resp.Result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+	return result, nil
+}
+
+// SchemaWarnings reports what the server changes in a catalog input schema
+// before agents see it, for doctor. No warnings means the schema is listed as
+// the API sent it.
+func SchemaWarnings(params json.RawMessage) []string {
+	if len(params) == 0 || string(params) == "null" {
+		return []string{"no input schema; an empty object schema is listed instead"}
+	}
+	var original interface{}
+	if err := json.Unmarshal(params, &original); err != nil {
+		return []string{fmt.Sprintf("input schema is not valid JSON: %v", err)}
+	}
+
+	var warnings []string
+	sanitized := schema.Sanitize(params)
+	if !sameJSON(params, sanitized) {
+		warnings = append(warnings, `fixed for JSON Schema 2020-12 (per-property "required", mistyped defaults or defaults outside the enum)`)
+	}
+	if !sameJSON(sanitized, fixSentenceCaseInSchema(sanitized)) {
+		warnings = append(warnings, "example text recased in descriptions")
+	}
+	return warnings
+}
+
+// sameJSON reports whether two JSON documents hold the same value
+func sameJSON(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,60 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+func TestOperatorToolLookup(t *testing.T) {
+	server := newTestServer()
+	server.tools["prod-1"] = &api.ToolDefinition{}
+	server.rawTools[0].price = &price{Amount: 0.05, Currency: "USD"}
+
+	tools := server.Tools()
+	if len(tools) != 1 || tools[0].Name != "test-tool" || tools[0].ProductID != "prod-1" || tools[0].Price != "0.05 USD" {
+		t.Fatalf("Tools() = %+v", tools)
+	}
+
+	// Product IDs resolve to the listed tool, like in tools/call
+	if tool, ok := server.Tool("prod-1"); !ok || tool.Name != "test-tool" {
+		t.Errorf("Tool(prod-1) = %+v, %v", tool, ok)
+	}
+	if _, ok := server.Tool("missing"); ok {
+		t.Error("Expected no tool for an unknown name")
+	}
+}
+
+func TestOperatorCallToolNeedsApproval(t *testing.T) {
+	purchases := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		purchases++
+		w.Write([]byte(`{"success":true,"output":"done"}`))
+	}))
+	defer upstream.Close()
+
+	server := newTestServer()
+	server.apiClient = api.NewClient("api-key", "budget-key", agentpmt.WithBaseURL(upstream.URL))
+	server.approvalRules = approval.Rules{Always: true}
+	server.tokens = approval.NewTokens(0)
+
+	result, err := server.CallTool(context.Background(), "test-tool", nil, false)
+	if err != nil || result["isError"] != true || purchases != 0 {
+		t.Fatalf("Expected an unapproved call to be refused, got %v, %v (%d purchases)", result, err, purchases)
+	}
+
+	result, err = server.CallTool(context.Background(), "test-tool", nil, true)
+	if err != nil || result["isError"] == true || purchases != 1 {
+		t.Fatalf("Expected the approved call to be bought, got %v, %v (%d purchases)", result, err, purchases)
+	}
+	text := result["content"].([]interface{})[0].(map[string]interface{})["text"]
+	if !strings.Contains(text.(string), "done") {
+		t.Errorf("Expected the tool output, got %v", result)
+	}
+}
@@ -0,0 +1,61 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/content"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+// catalogOutputSchema returns the tool's published output schema in MCP form
+func catalogOutputSchema(toolDef api.ToolDefinition) json.RawMessage {
+	return schema.OutputSchema(toolDef.OutputSchema)
+}
+
+// extractMedia pulls images, audio and files out of purchase output
+// Returns the output with those payloads replaced by short notes, plus their content blocks.
+func (s *Server) extractMedia(output interface{}) (interface{}, []content.Block) {
+	if text, ok := output.(string); ok {
+		return s.extractor.ExtractText(text)
+	}
+	return s.extractor.Extract(output)
+}
+
+// structureOutput converts purchase output into structuredContent and its text fallback
+// Text output is only structured when it is itself JSON.
+func structureOutput(output interface{}) (map[string]interface{}, string) {
+	if text, ok := output.(string); ok {
+		return schema.StructureText(text), text
+	}
+
+	outputJSON, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		outputJSON = []byte(fmt.Sprintf("%v", output))
+	}
+	return schema.Structure(output), string(outputJSON)
+}
+
+// conformingOutput returns structured output only if it fits the tool's
+// catalog output schema, since structuredContent must match the listed
+// outputSchema; output that doesn't fit is still sent as text
+func (s *Server) conformingOutput(productID string, structured map[string]interface{}) map[string]interface{} {
+	if structured == nil {
+		return nil
+	}
+
+	s.toolsMux.RLock()
+	def := s.tools[productID]
+	s.toolsMux.RUnlock()
+	if def == nil || len(def.OutputSchema) == 0 {
+		return structured
+	}
+
+	if errs := schema.ValidateOutput(catalogOutputSchema(*def), structured); len(errs) > 0 {
+		log.Printf("Warning: output of %s doesn't match its catalog output schema, sending it as text only: %v", productID, errs)
+		return nil
+	}
+	return structured
+}
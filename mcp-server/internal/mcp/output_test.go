@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/content"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+func TestStructureOutput(t *testing.T) {
+	structured, text := structureOutput(map[string]interface{}{"temp": 21.0})
+	if structured["temp"] != 21.0 || text != "{\n  \"temp\": 21\n}" {
+		t.Errorf("Unexpected structured output %v / %q", structured, text)
+	}
+
+	structured, text = structureOutput("plain answer")
+	if structured != nil || text != "plain answer" {
+		t.Errorf("Expected plain text to stay text, got %v / %q", structured, text)
+	}
+}
+
+func TestOutputSchemasComeOnlyFromTheCatalog(t *testing.T) {
+	server := newTestServer()
+
+	var notified int
+	sess := newSession("test")
+	sess.notify = func(interface{}) error {
+		notified++
+		return nil
+	}
+	server.addSession(sess)
+
+	// Results of a tool without a catalog schema are sent as they are
+	for _, output := range []map[string]interface{}{{"temp": 21.0}, {"temp": "warm"}} {
+		if structured := server.conformingOutput("prod-1", output); structured == nil {
+			t.Errorf("Expected %v to be sent as structured content", output)
+		}
+	}
+	if notified != 0 {
+		t.Errorf("Expected no list_changed for tool results, got %d", notified)
+	}
+
+	tools := server.handleToolsList(1).Result.(map[string]interface{})["tools"].([]ToolWithRawSchema)
+	if len(tools[0].OutputSchema) != 0 {
+		t.Errorf("Expected no output schema without one in the catalog, got %s", tools[0].OutputSchema)
+	}
+}
+
+func TestOutputNotMatchingTheCatalogSchemaIsTextOnly(t *testing.T) {
+	server := newTestServer()
+	catalog := json.RawMessage(`{"type":"object","properties":{"temp":{"type":"number"}}}`)
+	server.tools["prod-1"] = &api.ToolDefinition{OutputSchema: catalog}
+
+	if structured := server.conformingOutput("prod-1", map[string]interface{}{"temp": 21.0}); structured == nil {
+		t.Error("Expected output matching the catalog schema to be structured")
+	}
+	if structured := server.conformingOutput("prod-1", map[string]interface{}{"temp": "warm"}); structured != nil {
+		t.Errorf("Expected output that doesn't fit the catalog schema to be text only, got %v", structured)
+	}
+}
+
+func TestExtractMediaFromTextOutput(t *testing.T) {
+	server := newTestServer()
+	server.extractor = &content.Extractor{Dir: t.TempDir()}
+
+	png := base64.StdEncoding.EncodeToString(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...))
+	output, media := server.extractMedia(`{"image": "data:image/png;base64,` + png + `"}`)
+	if len(media) != 1 || media[0].Type != "image" {
+		t.Fatalf("Expected one image block, got %+v", media)
+	}
+
+	structured, text := structureOutput(output)
+	if strings.Contains(text, png) || structured["image"] == nil {
+		t.Errorf("Expected the text and structured copies to hold a note, got %q", text)
+	}
+}
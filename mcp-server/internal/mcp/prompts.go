@@ -0,0 +1,87 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+)
+
+// handlePromptsList returns the built-in, per-tool and team prompts
+func (s *Server) handlePromptsList(id interface{}) JSONRPCResponse {
+	s.toolsMux.RLock()
+	tools := promptTools(s.rawTools)
+	s.toolsMux.RUnlock()
+
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: map[string]interface{}{
+			"prompts": s.prompts.List(tools),
+		},
+	}
+}
+
+// handlePromptsGet renders a prompt with the client's arguments
+func (s *Server) handlePromptsGet(id interface{}, params json.RawMessage) JSONRPCResponse {
+	var getParams struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &getParams); err != nil || getParams.Name == "" {
+		return rpcErrorResponse(id, InvalidParams, "Invalid params: name is required")
+	}
+
+	s.toolsMux.RLock()
+	tools := promptTools(s.rawTools)
+	s.toolsMux.RUnlock()
+
+	result, err := s.prompts.Get(getParams.Name, getParams.Arguments, tools)
+	if errors.Is(err, prompt.ErrNotFound) {
+		return rpcErrorResponse(id, InvalidParams, fmt.Sprintf("Unknown prompt: %s", getParams.Name))
+	}
+	if err != nil {
+		return rpcErrorResponse(id, InvalidParams, fmt.Sprintf("Invalid arguments: %v", err))
+	}
+
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  result,
+	}
+}
+
+// promptsChanged reports whether a catalog change altered prompts/list
+func (s *Server) promptsChanged(previous, current []ToolWithRawSchema) bool {
+	before := s.prompts.List(promptTools(previous))
+	after := s.prompts.List(promptTools(current))
+	if len(before) != len(after) {
+		return true
+	}
+	for i := range before {
+		if before[i].Name != after[i].Name || before[i].Title != after[i].Title {
+			return true
+		}
+	}
+	return false
+}
+
+// promptTools describes listed tools for the prompt library
+func promptTools(tools []ToolWithRawSchema) []prompt.Tool {
+	out := make([]prompt.Tool, 0, len(tools))
+	for _, tool := range tools {
+		pt := prompt.Tool{
+			Name:        tool.Name,
+			Title:       tool.Title,
+			Description: tool.Description,
+			Examples:    tool.examples,
+		}
+		if tool.price != nil {
+			amount := tool.price.Amount
+			pt.Price, pt.Currency, pt.Unit = &amount, tool.price.Currency, tool.price.Unit
+		}
+		out = append(out, pt)
+	}
+	return out
+}
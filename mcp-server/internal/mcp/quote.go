@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+)
+
+// QuoteToolName is the meta-tool that quotes a tool call without buying it
+// Clients that can set _meta can use {"_meta": {"dryRun": true}} on tools/call instead.
+const QuoteToolName = "quote_tool"
+
+// quoteTool is listed in tools/list after the catalog
+var quoteTool = ToolWithRawSchema{
+	Name: QuoteToolName,
+	Description: "Quote Tool — Check what a tool call would cost and whether its arguments are valid, " +
+		"without calling the tool or being charged. Use it to plan multi-step work within a budget.",
+	InputSchema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"tool": {"type": "string", "description": "Name of the tool to quote"},
+			"arguments": {"type": "object", "description": "Arguments you would call the tool with"}
+		},
+		"required": ["tool"]
+	}`),
+}
+
+// price is what a purchase is expected to cost
+type price struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency,omitempty"`
+	Unit     string  `json:"unit,omitempty"`
+	Source   string  `json:"source"` // "catalog", "override" or "last-charge"
+}
+
+// quote describes a tool call without making it
+type quote struct {
+	Tool             string              `json:"tool"`
+	ProductID        string              `json:"productId"`
+	Valid            bool                `json:"valid"`
+	Errors           []schema.FieldError `json:"errors,omitempty"`
+	Price            *price              `json:"price"` // null when unknown
+	Budget           []budgetImpact      `json:"budget,omitempty"`
+	WouldExceed      string              `json:"wouldExceed,omitempty"` // Cap the call would be refused by
+	ApprovalRequired bool                `json:"approvalRequired"`
+	Charged          bool                `json:"charged"` // Always false: quotes never buy
+}
+
+// budgetImpact is one local spend cap before and after the quoted purchase
+type budgetImpact struct {
+	ledger.CapStatus
+	RemainingAfter *float64 `json:"remainingAfter"` // null when the price is unknown
+}
+
+// lookupPrice returns the expected price of tool: the catalog price (or its
+// local override) when known, otherwise the last amount the API charged for it
+func (s *Server) lookupPrice(tool string) *price {
+	s.toolsMux.RLock()
+	var annotated *price
+	for _, raw := range s.rawTools {
+		if raw.Name == tool {
+			annotated = raw.price
+		}
+	}
+	s.toolsMux.RUnlock()
+
+	if annotated != nil {
+		return annotated
+	}
+	if s.ledger != nil {
+		if amount, ok := s.ledger.LastCost(tool); ok {
+			return &price{Amount: amount, Source: "last-charge"}
+		}
+	}
+	return nil
+}
+
+// expectedCost is what a purchase of tool is held against the spend caps at:
+// its lookupPrice, or -1 when unknown (the ledger assumes its default cost)
+func (s *Server) expectedCost(tool string) float64 {
+	if p := s.lookupPrice(tool); p != nil {
+		return p.Amount
+	}
+	return -1
+}
+
+// inputSchema returns the sanitized input schema for a tool name
+func (s *Server) inputSchema(tool string) json.RawMessage {
+	s.toolsMux.RLock()
+	defer s.toolsMux.RUnlock()
+
+	for _, raw := range s.rawTools {
+		if raw.Name == tool {
+			return raw.InputSchema
+		}
+	}
+	// Display names map to the same product; sanitize its schema the same way
+	if def := s.tools[s.nameToID[tool]]; def != nil {
+		return fixSentenceCaseInSchema(schema.Sanitize(def.Function.Parameters))
+	}
+	return nil
+}
+
+// canonicalName returns the listed name of the tool a name refers to
+// Display names, former names (aliases) and product IDs of listed tools
+// resolve to the tool's current MCP name so validation, caps and approval
+// all see one name per tool.
+func (s *Server) canonicalName(name string) string {
+	s.toolsMux.RLock()
+	defer s.toolsMux.RUnlock()
+
+	productID, exists := s.nameToID[name]
+	if !exists {
+		if s.tools[name] == nil {
+			return name
+		}
+		productID = name
+	}
+	for _, raw := range s.rawTools {
+		if raw.Name == name {
+			return name
+		}
+	}
+	for _, raw := range s.rawTools {
+		if s.nameToID[raw.Name] == productID {
+			return raw.Name
+		}
+	}
+	return name
+}
+
+// quoteCall validates a call and works out its price and budget impact
+func (s *Server) quoteCall(sess *session, tool string, args map[string]interface{}) (*quote, error) {
+	tool = s.canonicalName(tool)
+	s.toolsMux.RLock()
+	productID, exists := s.nameToID[tool]
+	s.toolsMux.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("unknown tool %q", tool)
+	}
+
+	args = s.normalizeArguments(tool, args)
+
+	q := &quote{
+		Tool:      tool,
+		ProductID: productID,
+		Errors:    s.argumentErrors(tool, args),
+		Price:     s.lookupPrice(tool),
+	}
+	q.Valid = len(q.Errors) == 0
+
+	cost, costKnown := 0.0, q.Price != nil
+	if costKnown {
+		cost = q.Price.Amount
+	}
+	q.ApprovalRequired, _ = s.approvalRules.Required(tool, cost, costKnown)
+
+	if s.ledger != nil {
+		var capErr *ledger.CapError
+		if err := s.ledger.Check(sess.id, tool, s.expectedCost(tool)); errors.As(err, &capErr) {
+			q.WouldExceed = capErr.Cap
+		}
+
+		for _, status := range s.ledger.Status(sess.id, tool) {
+			impact := budgetImpact{CapStatus: status}
+			if costKnown {
+				after := status.Remaining() - cost
+				impact.RemainingAfter = &after
+				if after < 0 && q.WouldExceed == "" {
+					q.WouldExceed = status.Cap
+				}
+			}
+			q.Budget = append(q.Budget, impact)
+		}
+	}
+
+	return q, nil
+}
+
+// handleQuote answers a dry-run tools/call or a quote_tool call
+func (s *Server) handleQuote(sess *session, id interface{}, tool string, args map[string]interface{}) JSONRPCResponse {
+	q, err := s.quoteCall(sess, tool, args)
+	if err != nil {
+		return toolError(id, fmt.Sprintf("Quote failed: %v", err))
+	}
+
+	quoteJSON, _ := json.MarshalIndent(q, "", "  ")
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: map[string]interface{}{
+			"content": []map[string]interface{}{
+				{
+					"type": "text",
+					"text": fmt.Sprintf("Quote (nothing was charged):\n%s", quoteJSON),
+				},
+			},
+		},
+	}
+}
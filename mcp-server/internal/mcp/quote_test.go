@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+// newQuoteTestServer has one priced tool with a required argument
+func newQuoteTestServer() *Server {
+	server := newTestServer()
+	server.tools["prod-1"] = &api.ToolDefinition{
+		Type:     "function",
+		Function: api.FunctionDef{Name: "prod-1", Description: "Test Tool — A test"},
+		Pricing:  &api.Pricing{Cost: 0.25, Currency: "USD"},
+	}
+	server.rawTools[0].InputSchema = json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`)
+	server.rawTools[0].price = annotatedPrice(server.annotate(*server.tools["prod-1"], "test-tool", "Test Tool"))
+	return server
+}
+
+// decodeQuote extracts the quote from a tool result
+func decodeQuote(t *testing.T, resp JSONRPCResponse) quote {
+	t.Helper()
+	result := resp.Result.(map[string]interface{})
+	if result["isError"] == true {
+		t.Fatalf("Expected a quote, got error %+v", result)
+	}
+	text := result["content"].([]map[string]interface{})[0]["text"].(string)
+
+	var q quote
+	if err := json.Unmarshal([]byte(text[strings.Index(text, "{"):]), &q); err != nil {
+		t.Fatalf("Invalid quote %q: %v", text, err)
+	}
+	return q
+}
+
+func TestDryRunQuotesWithoutBuying(t *testing.T) {
+	server := newQuoteTestServer() // No API client: a dry run must never reach it
+	spend, _ := ledger.New(ledger.Limits{Day: 1.00}, "")
+	spend.Record(ledger.Entry{Tool: "test-tool"}, 0.90)
+	server.ledger = spend
+
+	resp := server.handleToolsCall(context.Background(), newSession("test"), 1,
+		json.RawMessage(`{"name":"test-tool","arguments":{"city":"Oslo"},"_meta":{"dryRun":true}}`))
+	q := decodeQuote(t, resp)
+
+	if !q.Valid || q.ProductID != "prod-1" || q.Charged {
+		t.Errorf("Unexpected quote: %+v", q)
+	}
+	if q.Price == nil || q.Price.Amount != 0.25 || q.Price.Source != "catalog" {
+		t.Errorf("Expected the catalog price, got %+v", q.Price)
+	}
+	if q.WouldExceed != "daily" {
+		t.Errorf("Expected the purchase to exceed the daily cap, got %q", q.WouldExceed)
+	}
+	if len(q.Budget) != 1 || q.Budget[0].RemainingAfter == nil {
+		t.Fatalf("Expected the daily cap impact, got %+v", q.Budget)
+	}
+}
+
+func TestQuoteToolReportsInvalidArguments(t *testing.T) {
+	server := newQuoteTestServer()
+
+	resp := server.handleToolsCall(context.Background(), newSession("test"), 1,
+		json.RawMessage(`{"name":"quote_tool","arguments":{"tool":"test-tool","arguments":{}}}`))
+	q := decodeQuote(t, resp)
+
+	if q.Valid || len(q.Errors) != 1 || q.Errors[0].Field != "city" {
+		t.Errorf("Expected a missing city error, got %+v", q)
+	}
+}
+
+func TestQuoteUnknownTool(t *testing.T) {
+	server := newQuoteTestServer()
+
+	resp := server.handleToolsCall(context.Background(), newSession("test"), 1,
+		json.RawMessage(`{"name":"quote_tool","arguments":{"tool":"missing"}}`))
+	if result := resp.Result.(map[string]interface{}); result["isError"] != true {
+		t.Errorf("Expected an error for an unknown tool, got %+v", result)
+	}
+}
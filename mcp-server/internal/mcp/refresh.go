@@ -0,0 +1,275 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/cache"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+// toolsDiff describes how a refreshed catalog differs from the live one
+type toolsDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Empty reports whether the catalogs are identical
+func (d toolsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String summarizes the diff for logs
+func (d toolsDiff) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// diffTools compares two catalogs by MCP tool name
+func diffTools(previous, current []ToolWithRawSchema) toolsDiff {
+	old := make(map[string]ToolWithRawSchema, len(previous))
+	for _, tool := range previous {
+		old[tool.Name] = tool
+	}
+
+	var diff toolsDiff
+	seen := make(map[string]bool, len(current))
+	for _, tool := range current {
+		seen[tool.Name] = true
+		before, exists := old[tool.Name]
+		switch {
+		case !exists:
+			diff.Added = append(diff.Added, tool.Name)
+		case before.Description != tool.Description || !bytes.Equal(before.InputSchema, tool.InputSchema) ||
+			!bytes.Equal(before.OutputSchema, tool.OutputSchema) || before.Title != tool.Title ||
+			!reflect.DeepEqual(before.Annotations, tool.Annotations) || !reflect.DeepEqual(before.Meta, tool.Meta):
+			diff.Changed = append(diff.Changed, tool.Name)
+		}
+	}
+	for name := range old {
+		if !seen[name] {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// RefreshTools re-fetches the catalog, swaps it in and notifies connected
+// clients with notifications/tools/list_changed if anything changed.
+func (s *Server) RefreshTools(ctx context.Context) (toolsDiff, error) {
+	s.refreshMux.Lock()
+	defer s.refreshMux.Unlock()
+
+	catalog, err := s.apiClient.FetchAllTools(ctx, api.DefaultPageSize)
+	if err != nil {
+		return toolsDiff{}, fmt.Errorf("failed to fetch tools: %w", err)
+	}
+	warnTruncated(catalog)
+
+	// An empty catalog is likelier an API or budget hiccup than every product
+	// being withdrawn at once: keep listing what clients already have
+	if len(catalog.Tools) == 0 {
+		s.toolsMux.RLock()
+		listed := len(s.rawTools)
+		s.toolsMux.RUnlock()
+		if listed > 0 {
+			return toolsDiff{}, fmt.Errorf("the API returned an empty catalog; keeping the %d tools already listed", listed)
+		}
+	}
+
+	set := s.buildToolSet(catalog.Tools)
+
+	s.toolsMux.RLock()
+	diff := diffTools(s.rawTools, set.rawTools)
+	s.toolsMux.RUnlock()
+
+	wasStale := s.stale.Swap(false)
+	if wasStale {
+		log.Println("API reachable again; catalog is no longer stale")
+	}
+
+	if diff.Empty() {
+		if wasStale {
+			s.saveCache(set)
+		}
+		return diff, nil
+	}
+
+	previous := s.installToolSet(set)
+	s.saveCache(set)
+	log.Printf("Tool catalog refreshed: %s", diff)
+	s.notifyAll("notifications/tools/list_changed", nil)
+	if s.promptsChanged(previous, set.rawTools) {
+		s.notifyAll("notifications/prompts/list_changed", nil)
+	}
+
+	return diff, nil
+}
+
+// warnTruncated logs that paging stopped before the whole catalog was fetched
+func warnTruncated(catalog *api.Catalog) {
+	switch {
+	case !catalog.Truncated:
+	case catalog.TotalTools > 0:
+		log.Printf("Warning: catalog truncated at the page limit: fetched %d of the %d tools the API reports", len(catalog.Tools), catalog.TotalTools)
+	default:
+		log.Printf("Warning: catalog truncated at the page limit after %d tools; some tools may be missing", len(catalog.Tools))
+	}
+}
+
+// loadCache installs the on-disk catalog and marks the server stale
+func (s *Server) loadCache() error {
+	if s.cachePath == "" {
+		return fmt.Errorf("catalog cache disabled")
+	}
+
+	f, err := cache.Load(s.cachePath, s.fingerprint)
+	if err != nil {
+		return err
+	}
+
+	set := s.buildToolSet(api.ToolDefinitions(f.Tools))
+	// Keep names that only exist in the cached map (e.g. aliases) resolvable
+	for name, productID := range f.NameToID {
+		if _, exists := set.nameToID[name]; !exists {
+			set.nameToID[name] = productID
+		}
+	}
+	s.installToolSet(set)
+	s.stale.Store(true)
+
+	log.Printf("Loaded %d tools from cache %s (saved %s)", len(set.rawTools), s.cachePath, f.SavedAt.Format(time.RFC3339))
+	return nil
+}
+
+// saveCache writes set as the last good catalog
+func (s *Server) saveCache(set *toolSet) {
+	if s.cachePath == "" || s.readOnly {
+		return
+	}
+
+	err := cache.Save(s.cachePath, &cache.File{
+		Fingerprint: s.fingerprint,
+		Tools:       api.CatalogTools(set.defs),
+		NameToID:    set.nameToID,
+	})
+	if err != nil {
+		log.Printf("Warning: failed to write catalog cache: %v", err)
+	}
+}
+
+// Stale reports whether the server is serving a cached catalog
+func (s *Server) Stale() bool {
+	return s.stale.Load()
+}
+
+// staleRetryInterval is how often a stale server retries fetching the catalog
+const staleRetryInterval = 30 * time.Second
+
+// RefreshMethod re-fetches the catalog on request and answers with what changed
+// It works where SIGHUP can't be sent, such as Windows.
+const RefreshMethod = "tools/refresh"
+
+// handleToolsRefresh handles RefreshMethod
+func (s *Server) handleToolsRefresh(ctx context.Context, id interface{}) JSONRPCResponse {
+	log.Println("Refreshing tool catalog on request...")
+	diff, err := s.RefreshTools(ctx)
+	if err != nil {
+		return rpcErrorResponse(id, -32603, fmt.Sprintf("Catalog refresh failed: %v", err))
+	}
+	return JSONRPCResponse{JSONRPC: "2.0", ID: id, Result: diff}
+}
+
+// TriggerRefresh asks the background refresher to re-fetch the catalog now
+func (s *Server) TriggerRefresh() {
+	select {
+	case s.refreshCh <- struct{}{}:
+	default:
+		// A refresh is already pending
+	}
+}
+
+// runRefresher refreshes the catalog every refreshInterval and on TriggerRefresh
+func (s *Server) runRefresher(ctx context.Context) {
+	var tick <-chan time.Time
+	if s.refreshInterval > 0 {
+		ticker := time.NewTicker(s.refreshInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+		log.Printf("Refreshing tool catalog every %s", s.refreshInterval)
+	}
+
+	// While stale, retry the API more often than the regular interval
+	retry := time.NewTimer(staleRetryInterval)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-s.refreshCh:
+			log.Println("Refreshing tool catalog on request...")
+		case <-retry.C:
+			retry.Reset(staleRetryInterval)
+			if !s.Stale() {
+				continue
+			}
+			log.Println("Retrying catalog fetch (serving cached catalog)...")
+		}
+
+		if _, err := s.RefreshTools(ctx); err != nil {
+			log.Printf("Warning: catalog refresh failed: %v", err)
+		}
+	}
+}
+
+// JSONRPCNotification represents a server-initiated JSON-RPC 2.0 notification
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// addSession registers a connected client for notifications
+func (s *Server) addSession(sess *session) {
+	s.sessionsMux.Lock()
+	defer s.sessionsMux.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*session)
+	}
+	s.sessions[sess.id] = sess
+}
+
+// removeSession unregisters a client
+func (s *Server) removeSession(sess *session) {
+	s.sessionsMux.Lock()
+	defer s.sessionsMux.Unlock()
+	delete(s.sessions, sess.id)
+}
+
+// notifyAll sends a notification to every connected client
+func (s *Server) notifyAll(method string, params interface{}) {
+	msg := JSONRPCNotification{JSONRPC: "2.0", Method: method, Params: params}
+
+	s.sessionsMux.RLock()
+	defer s.sessionsMux.RUnlock()
+
+	for _, sess := range s.sessions {
+		if sess.notify == nil {
+			continue
+		}
+		if err := sess.notify(msg); err != nil {
+			log.Printf("Failed to notify session %s: %v", sess.id, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/cache"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/naming"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+func TestDiffTools(t *testing.T) {
+	previous := []ToolWithRawSchema{
+		{Name: "kept", Description: "Kept", InputSchema: json.RawMessage(`{}`)},
+		{Name: "edited", Description: "Old", InputSchema: json.RawMessage(`{}`)},
+		{Name: "gone", Description: "Gone", InputSchema: json.RawMessage(`{}`)},
+	}
+	current := []ToolWithRawSchema{
+		{Name: "kept", Description: "Kept", InputSchema: json.RawMessage(`{}`)},
+		{Name: "edited", Description: "New", InputSchema: json.RawMessage(`{}`)},
+		{Name: "new", Description: "New", InputSchema: json.RawMessage(`{}`)},
+	}
+
+	diff := diffTools(previous, current)
+	want := toolsDiff{Added: []string{"new"}, Removed: []string{"gone"}, Changed: []string{"edited"}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diffTools() = %+v, want %+v", diff, want)
+	}
+	if diffTools(current, current).Empty() != true {
+		t.Error("Expected identical catalogs to produce an empty diff")
+	}
+}
+
+func TestInstallToolSetSwapsCatalog(t *testing.T) {
+	server := newTestServer()
+
+	set := server.buildToolSet([]api.ToolDefinition{{
+		Type: "function",
+		Function: api.FunctionDef{
+			Name:        "prod-2",
+			Description: "Weather Check — Looks up the weather",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string","required":true}}}`),
+		},
+	}})
+	previous := server.installToolSet(set)
+
+	if len(previous) != 1 || previous[0].Name != "test-tool" {
+		t.Errorf("Expected previous catalog to be returned, got %+v", previous)
+	}
+	if server.nameToID["weather-check"] != "prod-2" {
+		t.Errorf("Expected weather-check to map to prod-2, got %q", server.nameToID["weather-check"])
+	}
+	if _, exists := server.nameToID["test-tool"]; exists {
+		t.Error("Expected removed tool to be unmapped")
+	}
+}
+
+func TestNotifyAllReachesSessions(t *testing.T) {
+	server := newTestServer()
+
+	var got []JSONRPCNotification
+	sess := newSession("test")
+	sess.notify = func(msg interface{}) error {
+		got = append(got, msg.(JSONRPCNotification))
+		return nil
+	}
+	server.addSession(sess)
+
+	server.notifyAll("notifications/tools/list_changed", nil)
+	server.removeSession(sess)
+	server.notifyAll("notifications/tools/list_changed", nil)
+
+	if len(got) != 1 || got[0].Method != "notifications/tools/list_changed" {
+		t.Errorf("Expected exactly one list_changed notification, got %+v", got)
+	}
+}
+
+func TestLoadCacheMarksServerStale(t *testing.T) {
+	writer := newTestServer()
+	writer.cachePath = filepath.Join(t.TempDir(), cache.FileName)
+	writer.fingerprint = cache.Fingerprint("api-key", "budget-key")
+
+	set := writer.buildToolSet([]api.ToolDefinition{{
+		Type:     "function",
+		Function: api.FunctionDef{Name: "prod-9", Description: "Cached Tool — From disk"},
+	}})
+	writer.saveCache(set)
+
+	reader := newTestServer()
+	reader.cachePath = writer.cachePath
+	reader.fingerprint = writer.fingerprint
+	if err := reader.loadCache(); err != nil {
+		t.Fatalf("loadCache() failed: %v", err)
+	}
+
+	if !reader.Stale() {
+		t.Error("Expected server to be stale after loading from cache")
+	}
+	if reader.nameToID["cached-tool"] != "prod-9" {
+		t.Errorf("Expected cached-tool to map to prod-9, got %q", reader.nameToID["cached-tool"])
+	}
+}
+
+func TestRefreshMethodKeepsToolsOnEmptyCatalog(t *testing.T) {
+	catalog := `{"success":true,"tools":[
+		{"type":"function","function":{"name":"prod-1","description":"Test Tool — A test"}},
+		{"type":"function","function":{"name":"prod-2","description":"Other Tool — Another"}}]}`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(catalog))
+	}))
+	defer upstream.Close()
+
+	server := newTestServer()
+	server.apiClient = api.NewClient("api-key", "budget-key", agentpmt.WithBaseURL(upstream.URL))
+
+	resp := server.handleMessage(context.Background(), newSession("test"), JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: RefreshMethod})
+	if diff, ok := resp.Result.(toolsDiff); !ok || len(diff.Added) != 1 || diff.Added[0] != "other-tool" {
+		t.Fatalf("Expected %s to report other-tool added, got %+v", RefreshMethod, resp)
+	}
+
+	// An empty catalog is refused rather than emptying the tool list
+	catalog = `{"success":true,"tools":[]}`
+	resp = server.handleMessage(context.Background(), newSession("test"), JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: RefreshMethod})
+	if resp.Error == nil {
+		t.Fatalf("Expected an empty catalog to fail the refresh, got %+v", resp)
+	}
+	if len(server.rawTools) != 2 {
+		t.Errorf("Expected both tools to stay listed, got %+v", server.rawTools)
+	}
+}
+
+func TestReadOnlyServerWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	server := newTestServer()
+	server.readOnly = true
+	server.cachePath = filepath.Join(dir, cache.FileName)
+	server.names, _ = naming.Open(filepath.Join(dir, naming.FileName))
+
+	set := server.buildToolSet([]api.ToolDefinition{{
+		Type:     "function",
+		Function: api.FunctionDef{Name: "prod-9", Description: "Cached Tool — From disk"},
+	}})
+	server.saveCache(set)
+
+	if set.nameToID["cached-tool"] != "prod-9" {
+		t.Errorf("Expected names to be assigned in memory, got %v", set.nameToID)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected a read-only server to write nothing, found %v", entries)
+	}
+}
+
+func TestBuildToolSetNamesCollidingTools(t *testing.T) {
+	server := newTestServer()
+
+	def := func(id string) api.ToolDefinition {
+		return api.ToolDefinition{
+			Type:     "function",
+			Function: api.FunctionDef{Name: id, Description: "Weather Check — Looks up the weather"},
+		}
+	}
+	set := server.buildToolSet([]api.ToolDefinition{def("prod-2"), def("prod-1")})
+
+	if set.nameToID["weather-check"] != "prod-1" || set.nameToID["weather-check-prod-2"] != "prod-2" {
+		t.Errorf("Expected both tools to get distinct names, got %v", set.nameToID)
+	}
+	if len(set.rawTools) != 2 || set.rawTools[0].Name != "weather-check-prod-2" {
+		t.Errorf("Expected both tools listed in catalog order, got %+v", set.rawTools)
+	}
+}
+
+func TestCanonicalNameResolvesAliases(t *testing.T) {
+	server := newTestServer()
+	server.nameToID["Test Tool"] = "prod-1"
+	server.nameToID["old-test-tool"] = "prod-1"
+
+	for _, name := range []string{"test-tool", "Test Tool", "old-test-tool"} {
+		if got := server.canonicalName(name); got != "test-tool" {
+			t.Errorf("canonicalName(%q) = %q, want test-tool", name, got)
+		}
+	}
+	if got := server.canonicalName("unknown"); got != "unknown" {
+		t.Errorf("canonicalName(unknown) = %q", got)
+	}
+}
+
+func TestBuildToolSetAnnotatesTools(t *testing.T) {
+	server := newTestServer()
+	server.overrides = annotation.Overrides{
+		"prod-2": {Annotations: &annotation.Hints{DestructiveHint: new(bool)}, Cost: &annotation.Cost{Price: 0.10, Unit: "lookup"}},
+	}
+	readOnly := true
+
+	set := server.buildToolSet([]api.ToolDefinition{
+		{
+			Type:        "function",
+			Function:    api.FunctionDef{Name: "prod-1", Description: "Smart Math — Evaluates expressions"},
+			Pricing:     &api.Pricing{Cost: 0.01, Currency: "USD", Unit: "call"},
+			Annotations: &annotation.Hints{ReadOnlyHint: &readOnly},
+		},
+		{Type: "function", Function: api.FunctionDef{Name: "prod-2", Description: "Weather Check — Looks up the weather"}},
+	})
+
+	math, weather := set.rawTools[0], set.rawTools[1]
+	if math.Title != "Smart Math" || math.Annotations == nil || !*math.Annotations.ReadOnlyHint {
+		t.Errorf("Expected the title and catalog annotations, got %+v", math)
+	}
+	if cost, _ := math.Meta[annotation.CostMetaKey].(*annotation.Cost); cost == nil || cost.Price != 0.01 || cost.Unit != "call" {
+		t.Errorf("Expected the catalog cost in _meta, got %+v", math.Meta)
+	}
+	if weather.Annotations == nil || weather.Annotations.DestructiveHint == nil || *weather.Annotations.DestructiveHint {
+		t.Errorf("Expected the overridden annotation, got %+v", weather.Annotations)
+	}
+	if weather.price == nil || weather.price.Amount != 0.10 || weather.price.Source != "override" {
+		t.Errorf("Expected the override price, got %+v", weather.price)
+	}
+}
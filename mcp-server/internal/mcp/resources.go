@@ -0,0 +1,117 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/resource"
+)
+
+// ResourceNotFound is the MCP error code for resources/read of an unknown URI
+const ResourceNotFound = -32002
+
+// handleResourcesRead returns the contents of a budget, purchase or tool resource
+func (s *Server) handleResourcesRead(sess *session, id interface{}, params json.RawMessage) JSONRPCResponse {
+	var readParams struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &readParams); err != nil || readParams.URI == "" {
+		return rpcErrorResponse(id, InvalidParams, "Invalid params: uri is required")
+	}
+
+	contents, err := s.resourceReader().Read(readParams.URI, sess.id)
+	if errors.Is(err, resource.ErrNotFound) {
+		return rpcErrorResponse(id, ResourceNotFound, fmt.Sprintf("Resource not found: %s", readParams.URI))
+	}
+	if err != nil {
+		return rpcErrorResponse(id, -32603, err.Error())
+	}
+
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: map[string]interface{}{
+			"contents": []resource.Contents{contents},
+		},
+	}
+}
+
+// handleResourcesSubscribe records (or drops) a session's interest in a resource
+func (s *Server) handleResourcesSubscribe(sess *session, id interface{}, params json.RawMessage, subscribe bool) JSONRPCResponse {
+	var subParams struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &subParams); err != nil || subParams.URI == "" {
+		return rpcErrorResponse(id, InvalidParams, "Invalid params: uri is required")
+	}
+
+	if subscribe {
+		if _, err := s.resourceReader().Read(subParams.URI, sess.id); errors.Is(err, resource.ErrNotFound) {
+			return rpcErrorResponse(id, ResourceNotFound, fmt.Sprintf("Resource not found: %s", subParams.URI))
+		}
+		sess.subscriptions.Subscribe(subParams.URI)
+	} else {
+		sess.subscriptions.Unsubscribe(subParams.URI)
+	}
+
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  map[string]interface{}{},
+	}
+}
+
+// resourceReader serves resources from the ledger and the tool list
+func (s *Server) resourceReader() resource.Reader {
+	return resource.Reader{Ledger: s.ledger, Tool: s.listedTool}
+}
+
+// listedTool returns the tools/list entry for a tool name, alias or product ID
+func (s *Server) listedTool(name string) (interface{}, bool) {
+	name = s.canonicalName(name)
+
+	s.toolsMux.RLock()
+	defer s.toolsMux.RUnlock()
+	for _, tool := range s.rawTools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return nil, false
+}
+
+// notifyPurchase tells subscribed clients that the budget and purchase list changed
+func (s *Server) notifyPurchase() {
+	s.sessionsMux.RLock()
+	defer s.sessionsMux.RUnlock()
+
+	for _, uri := range resource.UpdatedByPurchase {
+		msg := JSONRPCNotification{
+			JSONRPC: "2.0",
+			Method:  "notifications/resources/updated",
+			Params:  map[string]interface{}{"uri": uri},
+		}
+		for _, sess := range s.sessions {
+			if sess.notify == nil || !sess.subscriptions.Has(uri) {
+				continue
+			}
+			if err := sess.notify(msg); err != nil {
+				log.Printf("Failed to notify session %s: %v", sess.id, err)
+			}
+		}
+	}
+}
+
+// rpcErrorResponse returns a JSON-RPC error response
+func rpcErrorResponse(id interface{}, code int, message string) JSONRPCResponse {
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: map[string]interface{}{
+			"code":    code,
+			"message": message,
+		},
+	}
+}
@@ -2,11 +2,22 @@ package mcp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/content"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/inflight"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/outgoing"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/resource"
 )
 
 // JSONRPCRequest represents a JSON-RPC 2.0 request
@@ -15,6 +26,10 @@ type JSONRPCRequest struct {
 	ID      interface{}     `json:"id,omitempty"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
+
+	// Set when the message is the client's response to a server-initiated request
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *outgoing.Error `json:"error,omitempty"`
 }
 
 // JSONRPCResponse represents a JSON-RPC 2.0 response
@@ -25,11 +40,63 @@ type JSONRPCResponse struct {
 	Error   interface{} `json:"error,omitempty"`
 }
 
+// session is one connected MCP client, independent of transport
+type session struct {
+	id       string
+	inflight *inflight.Registry      // Requests being handled, by JSON-RPC id
+	notify   func(interface{}) error // Sends a server-initiated message
+	client   atomic.Value            // string: clientInfo.name from initialize
+
+	outgoing    *outgoing.Calls // Server-initiated requests awaiting a response
+	elicitation atomic.Bool     // Client declared the elicitation capability
+	canRequest  func() bool     // Whether server-initiated requests can reach the client (nil: always)
+
+	subscriptions resource.Subscriptions // Resources the client wants notifications/resources/updated for
+
+	approved bool // The operator approved this session's purchases up front (CLI --yes)
+}
+
+// clientName returns the name the client gave in initialize
+func (sess *session) clientName() string {
+	name, _ := sess.client.Load().(string)
+	return name
+}
+
+// newSession creates a session with the given ID
+func newSession(id string) *session {
+	return &session{
+		id:       id,
+		inflight: inflight.NewRegistry(),
+		outgoing: outgoing.New(),
+	}
+}
+
+// request sends a server-initiated request and waits for the client's result
+func (sess *session) request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	if sess.canRequest != nil && !sess.canRequest() {
+		return nil, fmt.Errorf("no open stream to send %s on", method)
+	}
+	return sess.outgoing.Call(ctx, sess.notify, method, params)
+}
+
 // HandleStdioTransport handles JSON-RPC over stdio with custom tools/list
-func (s *Server) HandleStdioTransport() error {
-	scanner := bufio.NewScanner(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+func (s *Server) HandleStdioTransport(ctx context.Context) error {
+	return s.serveStream(ctx, os.Stdin, os.Stdout)
+}
+
+// serveStream reads newline-delimited JSON-RPC messages from r and writes
+// responses to w. Requests are dispatched concurrently, bounded by the
+// server's worker limit, so a slow tools/call never blocks other requests.
+// Responses may arrive out of order; clients correlate them by id.
+func (s *Server) serveStream(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	out := newFrameWriter(w)
+	sess := newSession("stdio")
+	sess.notify = out.Write
+	s.addSession(sess)
+	defer s.removeSession(sess)
 
+	var wg sync.WaitGroup
 	for scanner.Scan() {
 		line := scanner.Bytes()
 
@@ -39,61 +106,150 @@ func (s *Server) HandleStdioTransport() error {
 			continue
 		}
 
-		// Handle tools/list ourselves to preserve raw schemas
-		if req.Method == "tools/list" {
-			response := s.handleToolsList(req.ID)
-			if err := encoder.Encode(response); err != nil {
-				log.Printf("Error encoding response: %v", err)
-			}
+		// Responses and notifications are cheap and order-sensitive; handle them inline
+		if isResponse(req) || isNotification(req) {
+			s.dispatch(ctx, sess, req)
 			continue
 		}
 
-		// Handle initialize
-		if req.Method == "initialize" {
-			response := s.handleInitialize(req.ID)
-			if err := encoder.Encode(response); err != nil {
-				log.Printf("Error encoding response: %v", err)
-			}
-			continue
-		}
+		// Register the request before reading on, so a cancellation right behind it finds it
+		reqCtx, call, done := sess.inflight.Start(ctx, req.ID, req.Method)
 
-		// Handle tools/call
-		if req.Method == "tools/call" {
-			response := s.handleToolsCall(req.ID, req.Params)
-			if err := encoder.Encode(response); err != nil {
+		wg.Add(1)
+		go func(req JSONRPCRequest) {
+			defer wg.Done()
+			defer done()
+			response := s.handleTracked(reqCtx, sess, call, req)
+			if response == nil {
+				return
+			}
+			if err := out.Write(response); err != nil {
 				log.Printf("Error encoding response: %v", err)
 			}
-			continue
-		}
+		}(req)
+	}
 
-		// Handle notifications/initialized (no response needed)
-		if req.Method == "notifications/initialized" {
-			continue
-		}
+	// Let in-flight requests finish before the transport closes
+	wg.Wait()
 
-		// Handle other list methods
-		if req.Method == "prompts/list" {
-			response := JSONRPCResponse{
-				JSONRPC: "2.0",
-				ID:      req.ID,
-				Result:  map[string]interface{}{"prompts": []interface{}{}},
-			}
-			encoder.Encode(response)
-			continue
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return fmt.Errorf("scanner error: %w", err)
+	}
+
+	return nil
+}
+
+// dispatch handles one message from the client. Requests are tracked by id so
+// notifications/cancelled can abort them; a cancelled request gets no
+// response, as the MCP spec requires.
+func (s *Server) dispatch(ctx context.Context, sess *session, req JSONRPCRequest) *JSONRPCResponse {
+	if isResponse(req) {
+		if !sess.outgoing.Deliver(req.ID, req.Result, req.Error) {
+			log.Printf("Ignoring response to unknown request %v", req.ID)
 		}
+		return nil
+	}
+	if isNotification(req) {
+		return s.handleMessage(ctx, sess, req)
+	}
 
-		if req.Method == "resources/list" {
-			response := JSONRPCResponse{
-				JSONRPC: "2.0",
-				ID:      req.ID,
-				Result:  map[string]interface{}{"resources": []interface{}{}},
-			}
-			encoder.Encode(response)
-			continue
+	ctx, call, done := sess.inflight.Start(ctx, req.ID, req.Method)
+	defer done()
+	return s.handleTracked(ctx, sess, call, req)
+}
+
+// handleTracked handles a request already registered in sess.inflight while
+// holding one of the server's worker slots
+func (s *Server) handleTracked(ctx context.Context, sess *session, call *inflight.Call, req JSONRPCRequest) *JSONRPCResponse {
+	if s.workers != nil {
+		select {
+		case s.workers <- struct{}{}:
+			defer func() { <-s.workers }()
+		case <-ctx.Done():
+			return nil
 		}
+	}
+
+	// A free slot and a cancellation can be ready together; never start a cancelled request
+	if call.Cancelled() {
+		return nil
+	}
+
+	response := s.handleMessage(ctx, sess, req)
+	if call.Cancelled() {
+		return nil
+	}
+	return response
+}
+
+// frameWriter serializes JSON-RPC frames so concurrent responses never interleave
+type frameWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
 
-		// Unknown method
-		response := JSONRPCResponse{
+// newFrameWriter creates a frameWriter writing newline-delimited JSON to w
+func newFrameWriter(w io.Writer) *frameWriter {
+	return &frameWriter{encoder: json.NewEncoder(w)}
+}
+
+// Write encodes one message as a single line
+func (f *frameWriter) Write(msg interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.encoder.Encode(msg)
+}
+
+// handleMessage dispatches a single JSON-RPC message independent of transport.
+// It returns nil for notifications, which never get a response.
+func (s *Server) handleMessage(ctx context.Context, sess *session, req JSONRPCRequest) *JSONRPCResponse {
+	var response JSONRPCResponse
+
+	switch req.Method {
+	case "initialize":
+		response = s.handleInitialize(sess, req.ID, req.Params)
+	case "tools/list":
+		// Handle tools/list ourselves to preserve raw schemas
+		response = s.handleToolsList(req.ID)
+	case "tools/call":
+		response = s.handleToolsCall(ctx, sess, req.ID, req.Params)
+	case RefreshMethod:
+		response = s.handleToolsRefresh(ctx, req.ID)
+	case "ping":
+		response = JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  map[string]interface{}{},
+		}
+	case "prompts/list":
+		response = s.handlePromptsList(req.ID)
+	case "prompts/get":
+		response = s.handlePromptsGet(req.ID, req.Params)
+	case "resources/list":
+		response = JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  map[string]interface{}{"resources": resource.List()},
+		}
+	case "resources/templates/list":
+		response = JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  map[string]interface{}{"resourceTemplates": resource.Templates()},
+		}
+	case "resources/read":
+		response = s.handleResourcesRead(sess, req.ID, req.Params)
+	case "resources/subscribe", "resources/unsubscribe":
+		response = s.handleResourcesSubscribe(sess, req.ID, req.Params, req.Method == "resources/subscribe")
+	case "notifications/cancelled":
+		s.handleCancelled(sess, req.Params)
+		return nil
+	default:
+		// Notifications (notifications/initialized etc.) need no response
+		if isNotification(req) {
+			return nil
+		}
+		response = JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      req.ID,
 			Error: map[string]interface{}{
@@ -101,55 +257,129 @@ func (s *Server) HandleStdioTransport() error {
 				"message": fmt.Sprintf("Method not implemented: %s", req.Method),
 			},
 		}
-		encoder.Encode(response)
 	}
 
-	if err := scanner.Err(); err != nil && err != io.EOF {
-		return fmt.Errorf("scanner error: %w", err)
-	}
+	return &response
+}
 
-	return nil
+// isResponse reports whether a message answers a server-initiated request
+func isResponse(msg JSONRPCRequest) bool {
+	return msg.Method == "" && msg.ID != nil
+}
+
+// isNotification reports whether a message is a JSON-RPC notification
+func isNotification(req JSONRPCRequest) bool {
+	return req.ID == nil || strings.HasPrefix(req.Method, "notifications/")
 }
 
 // handleInitialize handles the initialize request
-func (s *Server) handleInitialize(id interface{}) JSONRPCResponse {
+func (s *Server) handleInitialize(sess *session, id interface{}, params json.RawMessage) JSONRPCResponse {
+	var initParams struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ClientInfo      struct {
+			Name string `json:"name"`
+		} `json:"clientInfo"`
+		Capabilities struct {
+			Elicitation json.RawMessage `json:"elicitation"`
+		} `json:"capabilities"`
+	}
+	if json.Unmarshal(params, &initParams) == nil {
+		if initParams.ClientInfo.Name != "" {
+			sess.client.Store(initParams.ClientInfo.Name)
+		}
+		sess.elicitation.Store(initParams.Capabilities.Elicitation != nil)
+	}
+
 	return JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Result: map[string]interface{}{
-			"protocolVersion": "2025-03-26",
+			"protocolVersion": negotiateVersion(initParams.ProtocolVersion),
 			"capabilities": map[string]interface{}{
 				"tools": map[string]interface{}{
 					"listChanged": true,
 				},
+				"prompts": map[string]interface{}{
+					"listChanged": true,
+				},
+				"resources": map[string]interface{}{
+					"subscribe":   true,
+					"listChanged": false,
+				},
 			},
-			"serverInfo": map[string]interface{}{
-				"name":    "agent-payment",
-				"version": "1.0.0",
-			},
+			"serverInfo": s.serverInfo(),
 		},
 	}
 }
 
+// serverInfo identifies the server, and the config profile it runs under
+func (s *Server) serverInfo() map[string]interface{} {
+	info := map[string]interface{}{
+		"name":    "agent-payment",
+		"version": Version,
+	}
+	if s.profile != "" {
+		info["profile"] = s.profile
+	}
+	return info
+}
+
+// Protocol versions this server speaks, newest first
+// structuredContent, outputSchema and elicitation arrived in 2025-06-18.
+var supportedVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// SupportedVersions returns the MCP protocol versions this server speaks, newest first
+func SupportedVersions() []string {
+	return append([]string(nil), supportedVersions...)
+}
+
+// defaultVersion is answered when the client doesn't ask for a version we speak
+const defaultVersion = "2025-03-26"
+
+// negotiateVersion picks the protocol version to answer initialize with
+func negotiateVersion(requested string) string {
+	if isSupportedVersion(requested) {
+		return requested
+	}
+	return defaultVersion
+}
+
+// isSupportedVersion reports whether this server speaks a protocol version
+func isSupportedVersion(version string) bool {
+	for _, supported := range supportedVersions {
+		if supported == version {
+			return true
+		}
+	}
+	return false
+}
+
 // handleToolsList returns tools with raw schemas preserved
 func (s *Server) handleToolsList(id interface{}) JSONRPCResponse {
 	s.toolsMux.RLock()
-	defer s.toolsMux.RUnlock()
+	tools := make([]ToolWithRawSchema, 0, len(s.rawTools)+1)
+	tools = append(tools, s.rawTools...)
+	s.toolsMux.RUnlock()
+
+	tools = append(tools, quoteTool)
 
 	return JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Result: map[string]interface{}{
-			"tools": s.rawTools,
+			"tools": tools,
 		},
 	}
 }
 
 // handleToolsCall executes a tool
-func (s *Server) handleToolsCall(id interface{}, params json.RawMessage) JSONRPCResponse {
+func (s *Server) handleToolsCall(ctx context.Context, sess *session, id interface{}, params json.RawMessage) JSONRPCResponse {
 	var callParams struct {
 		Name      string                 `json:"name"`
 		Arguments map[string]interface{} `json:"arguments"`
+		Meta      struct {
+			DryRun bool `json:"dryRun"`
+		} `json:"_meta"`
 	}
 
 	if err := json.Unmarshal(params, &callParams); err != nil {
@@ -157,12 +387,27 @@ func (s *Server) handleToolsCall(id interface{}, params json.RawMessage) JSONRPC
 			JSONRPC: "2.0",
 			ID:      id,
 			Error: map[string]interface{}{
-				"code":    -32602,
+				"code":    InvalidParams,
 				"message": fmt.Sprintf("Invalid params: %v", err),
 			},
 		}
 	}
 
+	// Quotes validate and price a call without buying anything
+	if callParams.Name == QuoteToolName {
+		tool, _ := callParams.Arguments["tool"].(string)
+		args, _ := callParams.Arguments["arguments"].(map[string]interface{})
+		return s.handleQuote(sess, id, tool, args)
+	}
+	if callParams.Meta.DryRun {
+		return s.handleQuote(sess, id, callParams.Name, callParams.Arguments)
+	}
+
+	if name := s.canonicalName(callParams.Name); name != callParams.Name {
+		log.Printf("Tool '%s' is now called '%s'", callParams.Name, name)
+		callParams.Name = name
+	}
+
 	log.Printf("Executing tool: %s with arguments: %v", callParams.Name, callParams.Arguments)
 
 	// Map display name to product ID
@@ -171,46 +416,119 @@ func (s *Server) handleToolsCall(id interface{}, params json.RawMessage) JSONRPC
 	s.toolsMux.RUnlock()
 
 	if !exists {
+		// Filtered tools stay unreachable, whatever name or product ID is guessed
+		if s.filter != nil {
+			log.Printf("Refusing %s: not in the tool list", callParams.Name)
+			return JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: map[string]interface{}{
+					"code":    InvalidParams,
+					"message": fmt.Sprintf("Unknown tool: %s is not available on this server", callParams.Name),
+				},
+			}
+		}
 		// If not found, assume it's already a product ID
 		productID = callParams.Name
 	}
 
 	log.Printf("Mapped tool name '%s' to product ID '%s'", callParams.Name, productID)
 
-	// Execute via API client
-	result, err := s.apiClient.ExecuteTool(productID, callParams.Arguments)
-	if err != nil {
-		return JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      id,
-			Result: map[string]interface{}{
-				"content": []map[string]interface{}{
-					{
-						"type": "text",
-						"text": fmt.Sprintf("Tool execution failed: %v", err),
-					},
-				},
-				"isError": true,
-			},
+	callParams.Arguments = s.normalizeArguments(callParams.Name, callParams.Arguments)
+
+	// Bad arguments never cost a round trip, let alone a charge
+	if errs := s.argumentErrors(callParams.Name, callParams.Arguments); len(errs) > 0 {
+		return invalidArguments(id, callParams.Name, errs)
+	}
+
+	// Local spend caps are enforced before anything is sent to the API: the
+	// call's expected price is held against them until it is settled, so
+	// concurrent calls can't overshoot a cap together
+	var reservation *ledger.Reservation
+	if s.ledger != nil {
+		var err error
+		reservation, err = s.ledger.Reserve(sess.id, callParams.Name, s.expectedCost(callParams.Name))
+		if err != nil {
+			log.Printf("Refusing %s: %v", callParams.Name, err)
+			return apiErrorResult(id, fmt.Sprintf("Purchase refused: %v. The tool was not called and nothing was charged.", err),
+				&agentpmt.Error{Kind: agentpmt.ErrBudgetExceeded, Message: err.Error()})
 		}
 	}
+	entry := ledger.Entry{
+		Tool:      callParams.Name,
+		ProductID: productID,
+		Client:    sess.clientName(),
+		Session:   sess.id,
+	}
 
-	// Extract the actual output from the nested response structure
-	output := result.Response.Data.Output
+	// Ask the user before paying, when the approval rules say so
+	if refusal := s.checkApproval(ctx, sess, callParams.Name, callParams.Arguments); refusal != "" {
+		reservation.Release()
+		return toolError(id, refusal)
+	}
 
-	// Format result as JSON for better readability
-	outputJSON, err := json.MarshalIndent(output, "", "  ")
+	// Execute via API client; the trace lets a cancellation tell whether the purchase was sent
+	result, err := s.apiClient.ExecuteTool(inflight.TracePurchase(ctx, callParams.Name), productID, callParams.Arguments)
 	if err != nil {
-		outputJSON = []byte(fmt.Sprintf("%v", output))
+		if ctx.Err() != nil {
+			log.Printf("Tool execution aborted: %s (%v)", callParams.Name, ctx.Err())
+		}
+		if agentpmt.MayHaveCharged(err) && s.ledger != nil {
+			// Counted against the caps until the API's purchase history says otherwise
+			entry.Unconfirmed = true
+			entry.Result = err.Error()
+			reservation.Record(entry, -1)
+			s.notifyPurchase()
+		} else {
+			reservation.Release()
+		}
+		return apiErrorResult(id, fmt.Sprintf("Tool execution failed: %v", err), err)
+	}
+
+	inflight.MarkCommitted(ctx)
+
+	if s.ledger != nil {
+		amount, ok := result.Amount()
+		if !ok {
+			amount = -1 // Unknown: the ledger records the expected cost
+		}
+		entry.Result = result.PurchaseResult
+		if result.PurchaseDetails != nil {
+			entry.Details, _ = json.Marshal(result.PurchaseDetails)
+		}
+		reservation.Record(entry, amount)
+		s.notifyPurchase()
 	}
 
-	resultText := fmt.Sprintf("Tool Result:\n%s", string(outputJSON))
+	// Extract the actual output from the nested response structure
+	output, media := s.extractMedia(result.Response.Data.Output)
+	structured, text := structureOutput(output)
+	structured = s.conformingOutput(productID, structured)
+
+	// Text first, then any images, audio or files found in the output
+	blocks := append([]content.Block{content.Text(text)}, media...)
 
 	// Add purchase info if available
 	if result.PurchaseResult != "" {
-		resultText += fmt.Sprintf("\n\nPurchase: %s", result.PurchaseResult)
+		blocks = append(blocks, content.Text(fmt.Sprintf("Purchase: %s", result.PurchaseResult)))
+	}
+
+	toolResult := map[string]interface{}{
+		"content": blocks,
+	}
+	if structured != nil {
+		toolResult["structuredContent"] = structured
 	}
 
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  toolResult,
+	}
+}
+
+// toolError returns a tools/call result that reports a failure to the model
+func toolError(id interface{}, text string) JSONRPCResponse {
 	return JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
@@ -218,9 +536,20 @@ func (s *Server) handleToolsCall(id interface{}, params json.RawMessage) JSONRPC
 			"content": []map[string]interface{}{
 				{
 					"type": "text",
-					"text": resultText,
+					"text": text,
 				},
 			},
+			"isError": true,
 		},
 	}
 }
+
+// apiErrorResult reports a failed purchase like toolError, adding the error's
+// code to _meta so agents can tell whether to retry, ask the user or give up
+func apiErrorResult(id interface{}, text string, err error) JSONRPCResponse {
+	resp := toolError(id, text)
+	if meta := agentpmt.ErrorMeta(err); meta != nil {
+		resp.Result.(map[string]interface{})["_meta"] = meta
+	}
+	return resp
+}
@@ -0,0 +1,238 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/resource"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/toolset"
+	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
+)
+
+func TestServeStreamRespondsToEveryRequest(t *testing.T) {
+	server := newTestServer()
+	server.workers = make(chan struct{}, 2)
+
+	var input strings.Builder
+	input.WriteString(`{"jsonrpc":"2.0","id":0,"method":"initialize","params":{}}` + "\n")
+	input.WriteString(`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n")
+	for i := 1; i <= 20; i++ {
+		method := "ping"
+		if i%2 == 0 {
+			method = "tools/list"
+		}
+		req, _ := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", ID: i, Method: method})
+		input.Write(req)
+		input.WriteString("\n")
+	}
+
+	var out bytes.Buffer
+	if err := server.serveStream(context.Background(), strings.NewReader(input.String()), &out); err != nil {
+		t.Fatalf("serveStream() failed: %v", err)
+	}
+
+	seen := make(map[float64]bool)
+	scanner := bufio.NewScanner(&out)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("Interleaved or malformed frame %q: %v", scanner.Text(), err)
+		}
+		id, ok := resp.ID.(float64)
+		if !ok {
+			t.Fatalf("Response without numeric id: %s", scanner.Text())
+		}
+		seen[id] = true
+	}
+
+	if len(seen) != 21 {
+		t.Errorf("Expected 21 responses (notification gets none), got %d", len(seen))
+	}
+}
+
+func TestInitializeReportsProfile(t *testing.T) {
+	server := newTestServer()
+	if info := server.serverInfo(); info["profile"] != nil {
+		t.Errorf("Expected no profile without one configured, got %v", info)
+	}
+
+	server.profile = "staging"
+	resp := server.handleInitialize(newSession("test"), 1, json.RawMessage(`{}`))
+	info := resp.Result.(map[string]interface{})["serverInfo"].(map[string]interface{})
+	if info["name"] != "agent-payment" || info["profile"] != "staging" {
+		t.Errorf("serverInfo = %v", info)
+	}
+}
+
+func TestToolsCallRefusedBySpendCap(t *testing.T) {
+	spend, _ := ledger.New(ledger.Limits{Session: 0.10}, "")
+	spend.Record(ledger.Entry{Tool: "test-tool", Session: "test"}, 0.10)
+
+	server := newTestServer() // No API client: a refused call must never reach it
+	server.ledger = spend
+
+	resp := server.handleToolsCall(context.Background(), newSession("test"), 1,
+		json.RawMessage(`{"name":"test-tool","arguments":{}}`))
+
+	result := resp.Result.(map[string]interface{})
+	if result["isError"] != true {
+		t.Fatalf("Expected isError result, got %+v", result)
+	}
+	text := result["content"].([]map[string]interface{})[0]["text"].(string)
+	if !strings.Contains(text, "session spend cap") {
+		t.Errorf("Expected the result to name the session cap, got %q", text)
+	}
+	if meta, _ := result["_meta"].(map[string]interface{}); meta["errorCode"] != "budget_exceeded" || meta["retryable"] != false {
+		t.Errorf("Expected a budget_exceeded error code, got %v", result["_meta"])
+	}
+}
+
+func TestToolsCallRecordsUnconfirmedPurchase(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	spend, _ := ledger.New(ledger.Limits{Session: 1}, "")
+	server := newTestServer()
+	server.apiClient = api.NewClient("api-key", "budget-key", agentpmt.WithBaseURL(upstream.URL))
+	server.ledger = spend
+	server.rawTools[0].price = &price{Amount: 0.25, Source: "catalog"}
+
+	// A 502 may have been charged upstream: the call still counts against the caps
+	resp := server.handleToolsCall(context.Background(), newSession("test"), 1,
+		json.RawMessage(`{"name":"test-tool","arguments":{}}`))
+	if result := resp.Result.(map[string]interface{}); result["isError"] != true {
+		t.Fatalf("Expected isError result, got %+v", result)
+	}
+
+	entries := spend.Purchases(10)
+	if len(entries) != 1 || !entries[0].Unconfirmed || entries[0].Amount != 0.25 {
+		t.Fatalf("Expected one unconfirmed purchase at the quoted price, got %+v", entries)
+	}
+	if err := spend.Check("test", "test-tool", 0.80); err == nil {
+		t.Error("Expected the unconfirmed purchase to count against the session cap")
+	}
+}
+
+func TestFilteredToolsAreHiddenAndRefused(t *testing.T) {
+	server := newTestServer() // No API client: a refused call must never reach it
+	server.filter, _ = toolset.New(toolset.Rules{Deny: []string{"weather-*"}}, nil, "")
+
+	def := func(id, description string) api.ToolDefinition {
+		return api.ToolDefinition{Type: "function", Function: api.FunctionDef{Name: id, Description: description}}
+	}
+	server.installToolSet(server.buildToolSet([]api.ToolDefinition{
+		def("prod-1", "Test Tool — A test"),
+		def("prod-2", "Weather Check — Looks up the weather"),
+	}))
+
+	if len(server.rawTools) != 1 || server.rawTools[0].Name != "test-tool" {
+		t.Fatalf("Expected only test-tool to be listed, got %+v", server.rawTools)
+	}
+
+	for _, name := range []string{"weather-check", "Weather Check", "prod-2"} {
+		resp := server.handleToolsCall(context.Background(), newSession("test"), 1,
+			json.RawMessage(`{"name":"`+name+`","arguments":{}}`))
+		errMap, ok := resp.Error.(map[string]interface{})
+		if !ok || errMap["code"] != InvalidParams {
+			t.Errorf("Expected %q to be refused with InvalidParams, got %+v", name, resp)
+		}
+	}
+
+	// A listed tool's product ID resolves to its name
+	if got := server.canonicalName("prod-1"); got != "test-tool" {
+		t.Errorf("canonicalName(prod-1) = %q, want test-tool", got)
+	}
+}
+
+func TestResourcesReadAndSubscribe(t *testing.T) {
+	server := newTestServer()
+	server.ledger, _ = ledger.New(ledger.Limits{Day: 1.00}, "")
+	sess := newSession("test")
+	var sent []JSONRPCNotification
+	sess.notify = func(msg interface{}) error {
+		sent = append(sent, msg.(JSONRPCNotification))
+		return nil
+	}
+	server.addSession(sess)
+
+	call := func(method, params string) *JSONRPCResponse {
+		return server.handleMessage(context.Background(), sess, JSONRPCRequest{
+			JSONRPC: "2.0", ID: 1, Method: method, Params: json.RawMessage(params),
+		})
+	}
+
+	list := call("resources/list", `{}`).Result.(map[string]interface{})["resources"].([]resource.Resource)
+	if len(list) != 2 || list[0].URI != resource.BudgetURI {
+		t.Errorf("Expected the budget and purchases resources, got %+v", list)
+	}
+
+	// Tool docs resolve names like tools/call does
+	resp := call("resources/read", `{"uri":"agentpmt://tools/test-tool"}`)
+	contents := resp.Result.(map[string]interface{})["contents"].([]resource.Contents)
+	if !strings.Contains(contents[0].Text, `"inputSchema"`) {
+		t.Errorf("Expected the tool's schema, got %s", contents[0].Text)
+	}
+	if resp := call("resources/read", `{"uri":"agentpmt://purchases/missing"}`); resp.Error.(map[string]interface{})["code"] != ResourceNotFound {
+		t.Errorf("Expected a resource not found error, got %+v", resp)
+	}
+
+	call("resources/subscribe", `{"uri":"agentpmt://budget"}`)
+	e := server.ledger.Record(ledger.Entry{Tool: "test-tool", Session: "test"}, 0.25)
+	server.notifyPurchase()
+	if len(sent) != 1 || sent[0].Method != "notifications/resources/updated" {
+		t.Fatalf("Expected one update for the subscribed budget, got %+v", sent)
+	}
+
+	resp = call("resources/read", `{"uri":"agentpmt://purchases/`+e.ID+`"}`)
+	contents = resp.Result.(map[string]interface{})["contents"].([]resource.Contents)
+	if !strings.Contains(contents[0].Text, e.ID) {
+		t.Errorf("Expected the receipt, got %s", contents[0].Text)
+	}
+
+	call("resources/unsubscribe", `{"uri":"agentpmt://budget"}`)
+	server.notifyPurchase()
+	if len(sent) != 1 {
+		t.Errorf("Expected no update after unsubscribing, got %d", len(sent))
+	}
+}
+
+func TestPromptsListAndGet(t *testing.T) {
+	server := newTestServer()
+	server.rawTools[0].examples = []prompt.Example{{Arguments: json.RawMessage(`{"q":"hello"}`)}}
+	sess := newSession("test")
+
+	call := func(method, params string) *JSONRPCResponse {
+		return server.handleMessage(context.Background(), sess, JSONRPCRequest{
+			JSONRPC: "2.0", ID: 1, Method: method, Params: json.RawMessage(params),
+		})
+	}
+
+	prompts := call("prompts/list", `{}`).Result.(map[string]interface{})["prompts"].([]prompt.Prompt)
+	if len(prompts) != 3 || prompts[2].Name != "use-test-tool" {
+		t.Errorf("Expected the built-in prompts and one for test-tool, got %+v", prompts)
+	}
+
+	resp := call("prompts/get", `{"name":"use-test-tool","arguments":{"task":"greet"}}`)
+	result, ok := resp.Result.(prompt.Result)
+	if !ok || !strings.Contains(result.Messages[0].Content.Text, `"q": "hello"`) {
+		t.Errorf("Expected the example in the prompt, got %+v", resp)
+	}
+
+	for _, params := range []string{`{"name":"nope"}`, `{"name":"plan-within-budget","arguments":{"task":"x"}}`} {
+		if resp := call("prompts/get", params); resp.Error.(map[string]interface{})["code"] != InvalidParams {
+			t.Errorf("Expected InvalidParams for %s, got %+v", params, resp)
+		}
+	}
+}
@@ -2,14 +2,28 @@ package mcp
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/cache"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/content"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/naming"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/toolset"
 	"github.com/agentpmt/agent-payment-mcp-server/internal/api"
 	"github.com/modelcontextprotocol/go-sdk/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -21,84 +35,341 @@ type ToolWithRawSchema struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	InputSchema json.RawMessage `json:"inputSchema"`
+
+	// OutputSchema describes structuredContent, from the catalog
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+
+	// Title, annotations and cost (in _meta) come from the catalog and the override file
+	Title       string                 `json:"title,omitempty"`
+	Annotations *annotation.Hints      `json:"annotations,omitempty"`
+	Meta        map[string]interface{} `json:"_meta,omitempty"`
+
+	price    *price           // Annotated price, used for quotes and approvals
+	examples []prompt.Example // Sample calls from the catalog, for usage prompts
 }
 
 // Server wraps the MCP server and API client
 type Server struct {
-	mcpServer    *mcp.Server
-	apiClient    *api.Client
-	tools        map[string]*api.ToolDefinition
-	rawTools     []ToolWithRawSchema           // Store tools with raw schemas
-	nameToID     map[string]string             // Map display name to product ID
-	toolsMux     sync.RWMutex
+	mcpServer  *mcp.Server
+	apiClient  *api.Client
+	tools      map[string]*api.ToolDefinition
+	rawTools   []ToolWithRawSchema // Store tools with raw schemas
+	nameToID   map[string]string   // Map display name to product ID
+	toolsMux   sync.RWMutex
+	transport  string
+	listenAddr string
+	workers    chan struct{} // Bounds concurrently executing requests
+	profile    string        // Active config profile, reported in serverInfo ("" = none)
+
+	// HTTP transport access: bearer token ("" = none) and browser origins besides localhost
+	httpToken      string
+	allowedOrigins []string
+
+	refreshInterval time.Duration
+	refreshCh       chan struct{}       // Requests an immediate catalog refresh
+	refreshMux      sync.Mutex          // Serializes catalog refreshes
+	sessions        map[string]*session // Connected clients, for notifications
+	sessionsMux     sync.RWMutex
+
+	cachePath   string      // On-disk catalog cache ("" disables)
+	fingerprint string      // Identifies the credentials the cache belongs to
+	stale       atomic.Bool // Serving a cached catalog because the API was unreachable
+
+	names  *naming.Registry // Keeps tool names unique and stable (nil = not persisted)
+	filter *toolset.Filter  // Which catalog tools are exposed (nil = all)
+
+	overrides annotation.Overrides // Local title, annotation and cost overrides
+	prompts   *prompt.Library      // Team prompt templates (nil = built-in prompts only)
+
+	ledger        *ledger.Ledger   // Local spend caps (nil = none)
+	approvalRules approval.Rules   // Which purchases need the user's approval
+	tokens        *approval.Tokens // Confirmation tokens for clients without elicitation
+
+	extractor     *content.Extractor // Turns images, audio and files in output into content blocks
+	normalizeArgs bool               // Fix arguments to fit the tool schema before validating
+	readOnly      bool               // Never write the name registry or the catalog cache
 }
 
+// Transport names accepted in Config.Transport
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+)
+
 // Config holds server configuration
 type Config struct {
-	APIKey    string
-	BudgetKey string
+	APIKey     string
+	BudgetKey  string
+	Transport  string // "stdio" (default) or "http"
+	ListenAddr string // Address for the HTTP transport, e.g. "127.0.0.1:8080"
+
+	// HTTPToken is the bearer token HTTP clients must send ("" = none, loopback only)
+	HTTPToken string
+
+	// AllowedOrigins are browser origins allowed besides localhost, e.g. "https://app.example.com"
+	AllowedOrigins []string
+
+	// APIURL is the API deployment to call ("" = production)
+	APIURL string
+
+	// TLS replaces the default TLS settings for API calls (nil = defaults)
+	TLS *tls.Config
+
+	// Profile names the config profile in use, for serverInfo ("" = none)
+	Profile string
+
+	// MaxConcurrency limits how many requests are handled at once (default 8)
+	MaxConcurrency int
+
+	// RefreshInterval is how often the catalog is re-fetched (0 disables)
+	RefreshInterval time.Duration
+
+	// CachePath is where the last good catalog is stored ("" disables the cache)
+	CachePath string
+
+	// NamesPath is where tool name assignments are kept across restarts ("" = in memory)
+	NamesPath string
+
+	// Tools decides which catalog tools are exposed (nil exposes all)
+	Tools *toolset.Filter
+
+	// Overrides replace catalog titles, annotations and costs for some tools
+	Overrides annotation.Overrides
+
+	// Prompts adds a team's own prompt templates to the built-in ones (nil = none)
+	Prompts *prompt.Library
+
+	// Ledger records purchases and enforces local spend caps (nil disables them)
+	Ledger *ledger.Ledger
+
+	// Approval decides which purchases the user must approve first
+	Approval approval.Rules
+
+	// OutputDir is where outputs too large to send inline are written ("" = content.DefaultDir())
+	OutputDir string
+
+	// NormalizeArguments coerces, fills and trims tools/call arguments to fit the tool schema
+	NormalizeArguments bool
+
+	// ReadOnly builds the catalog without writing the name registry or the cache,
+	// for commands that only inspect it
+	ReadOnly bool
 }
 
+// Version is the server version reported in serverInfo
+const Version = "1.0.0"
+
+// DefaultMaxConcurrency is used when Config.MaxConcurrency is not set
+const DefaultMaxConcurrency = 8
+
 // NewServer creates and initializes a new MCP server
-func NewServer(cfg Config) (*Server, error) {
+// If the catalog can't be fetched, the server starts from the on-disk cache
+// (when one exists for these credentials), marks itself stale and keeps
+// retrying in the background. Cancelling ctx abandons the catalog fetch.
+func NewServer(ctx context.Context, cfg Config) (*Server, error) {
 	// Create API client
-	apiClient := api.NewClient(cfg.APIKey, cfg.BudgetKey)
-
-	// Fetch tools from API
-	log.Println("Fetching tools from Agent Payment API...")
-	toolsResp, err := apiClient.FetchTools(1, 100)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch tools: %w", err)
+	opts := []agentpmt.Option{agentpmt.WithBaseURL(cfg.APIURL)}
+	if cfg.TLS != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = cfg.TLS
+		opts = append(opts, agentpmt.WithTransport(transport))
 	}
-
-	log.Printf("Fetched %d tools from API", len(toolsResp.Tools))
+	apiClient := api.NewClient(cfg.APIKey, cfg.BudgetKey, opts...)
 
 	// Create MCP server
-	mcpServer := mcp.NewServer("agent-payment", "1.0.0", nil)
+	mcpServer := mcp.NewServer("agent-payment", Version, nil)
+
+	// Tool names assigned on earlier runs
+	names, err := naming.Open(cfg.NamesPath)
+	if err != nil {
+		log.Printf("Warning: starting with an empty name registry: %v", err)
+	}
 
 	// Create server instance
 	srv := &Server{
-		mcpServer: mcpServer,
-		apiClient: apiClient,
-		tools:     make(map[string]*api.ToolDefinition),
-		nameToID:  make(map[string]string),
+		mcpServer:       mcpServer,
+		apiClient:       apiClient,
+		tools:           make(map[string]*api.ToolDefinition),
+		nameToID:        make(map[string]string),
+		transport:       cfg.Transport,
+		listenAddr:      cfg.ListenAddr,
+		httpToken:       cfg.HTTPToken,
+		allowedOrigins:  cfg.AllowedOrigins,
+		profile:         cfg.Profile,
+		refreshInterval: cfg.RefreshInterval,
+		refreshCh:       make(chan struct{}, 1),
+		sessions:        make(map[string]*session),
+		cachePath:       cfg.CachePath,
+		fingerprint:     cache.Fingerprint(cfg.APIKey, cfg.BudgetKey),
+		names:           names,
+		filter:          cfg.Tools,
+		overrides:       cfg.Overrides,
+		prompts:         cfg.Prompts,
+		ledger:          cfg.Ledger,
+		approvalRules:   cfg.Approval,
+		tokens:          approval.NewTokens(approval.DefaultTokenTTL),
+		extractor:       content.NewExtractor(cfg.OutputDir),
+		normalizeArgs:   cfg.NormalizeArguments,
+		readOnly:        cfg.ReadOnly,
+	}
+
+	maxConcurrency := cfg.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultMaxConcurrency
+	}
+	srv.workers = make(chan struct{}, maxConcurrency)
+
+	// Fetch every page of the catalog from the API
+	log.Println("Fetching tools from Agent Payment API...")
+	catalog, err := apiClient.FetchAllTools(ctx, api.DefaultPageSize)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to fetch tools: %w", err)
+		}
+		if cacheErr := srv.loadCache(); cacheErr != nil {
+			return nil, fmt.Errorf("failed to fetch tools: %w (no usable cache: %v)", err, cacheErr)
+		}
+		log.Printf("Warning: failed to fetch tools, serving cached catalog until the API is reachable: %v", err)
+		return srv, nil
 	}
 
+	log.Printf("Fetched %d tools from API (%d pages)", len(catalog.Tools), catalog.Pages)
+	warnTruncated(catalog)
+
 	// Register all tools dynamically
-	for _, tool := range toolsResp.Tools {
-		if err := srv.registerTool(tool); err != nil {
+	set := srv.buildToolSet(catalog.Tools)
+	srv.installToolSet(set)
+	srv.saveCache(set)
+
+	log.Printf("Successfully registered %d tools", len(srv.tools))
+	if catalog.TotalTools > 0 {
+		log.Printf("Catalog: %d tools reported by API, %d fetched, %d registered", catalog.TotalTools, len(catalog.Tools), len(srv.tools))
+		if len(srv.tools) < catalog.TotalTools {
+			log.Printf("Warning: %d tools from the catalog were not registered", catalog.TotalTools-len(srv.tools))
+		}
+	}
+
+	return srv, nil
+}
+
+// toolSet is one consistent version of the registered catalog
+// It is built off to the side and swapped in whole by installToolSet.
+type toolSet struct {
+	defs     []api.ToolDefinition // Source definitions, in catalog order
+	tools    map[string]*api.ToolDefinition
+	rawTools []ToolWithRawSchema
+	nameToID map[string]string
+	sdkTools []*mcp.ServerTool
+}
+
+// newToolSet creates an empty toolSet
+func newToolSet() *toolSet {
+	return &toolSet{
+		tools:    make(map[string]*api.ToolDefinition),
+		nameToID: make(map[string]string),
+	}
+}
+
+// buildToolSet registers every tool definition into a new toolSet
+// Names come from the name registry so colliding descriptions get distinct
+// names and renamed tools stay callable under their former names.
+func (s *Server) buildToolSet(defs []api.ToolDefinition) *toolSet {
+	set := newToolSet()
+	set.defs = defs
+
+	wanted := make([]naming.Tool, len(defs))
+	for i, tool := range defs {
+		wanted[i] = naming.Tool{
+			ProductID: tool.Function.Name,
+			Name:      convertToMCPName(extractToolName(tool.Function.Description)),
+		}
+	}
+	assignment := s.names.Assign(wanted)
+	// Read-only servers assign names in memory only, matching what serve would list
+	if !s.readOnly {
+		if err := s.names.Save(); err != nil {
+			log.Printf("Warning: failed to save tool names: %v", err)
+		}
+	}
+
+	filtered := 0
+	for _, tool := range defs {
+		name := assignment.Names[tool.Function.Name]
+		if !s.filter.Allowed(tool.Function.Name, name) {
+			filtered++
+			continue
+		}
+		if err := s.registerTool(set, tool, name); err != nil {
 			log.Printf("Warning: failed to register tool %s: %v", tool.Function.Name, err)
 			continue
 		}
 	}
+	if filtered > 0 {
+		log.Printf("Filtered out %d tools (%s)", filtered, s.filter)
+	}
+	for alias, productID := range assignment.Aliases {
+		if _, exists := set.nameToID[alias]; !exists && set.tools[productID] != nil {
+			set.nameToID[alias] = productID
+		}
+	}
+	return set
+}
 
-	log.Printf("Successfully registered %d tools", len(srv.tools))
+// installToolSet atomically replaces the live catalog with set
+// Returns the catalog it replaced.
+func (s *Server) installToolSet(set *toolSet) []ToolWithRawSchema {
+	s.toolsMux.Lock()
+	previous := s.rawTools
+	s.tools = set.tools
+	s.rawTools = set.rawTools
+	s.nameToID = set.nameToID
+	s.toolsMux.Unlock()
+
+	// Keep the SDK registration in step (it is only used for compatibility)
+	if s.mcpServer != nil {
+		current := make(map[string]bool, len(set.rawTools))
+		for _, tool := range set.rawTools {
+			current[tool.Name] = true
+		}
+		var removed []string
+		for _, tool := range previous {
+			if !current[tool.Name] {
+				removed = append(removed, tool.Name)
+			}
+		}
+		if len(removed) > 0 {
+			s.mcpServer.RemoveTools(removed...)
+		}
+		s.mcpServer.AddTools(set.sdkTools...)
+	}
 
-	return srv, nil
+	return previous
 }
 
-// registerTool registers a single tool with the MCP server
-func (s *Server) registerTool(toolDef api.ToolDefinition) error {
-	s.toolsMux.Lock()
-	defer s.toolsMux.Unlock()
+// registerTool converts a single API tool into MCP form and adds it to set
+// mcpToolName is the MCP-compliant name the name registry assigned it.
+func (s *Server) registerTool(set *toolSet, toolDef api.ToolDefinition, mcpToolName string) error {
+	if mcpToolName == "" {
+		return fmt.Errorf("no tool name assigned")
+	}
 
 	// Store tool definition for later reference
-	s.tools[toolDef.Function.Name] = &toolDef
+	set.tools[toolDef.Function.Name] = &toolDef
 
 	// Extract human-readable name from description (before "—")
 	displayName := extractToolName(toolDef.Function.Description)
 	cleanDescription := extractCleanDescription(toolDef.Function.Description)
 
-	// Convert display name to MCP-compliant tool name (alphanumeric, hyphens, underscores only)
-	mcpToolName := convertToMCPName(displayName)
-
-	// Map both display name and MCP name to product ID for execution
-	s.nameToID[displayName] = toolDef.Function.Name
-	s.nameToID[mcpToolName] = toolDef.Function.Name
+	// Map both MCP name and display name to product ID for execution
+	// A display name shared by several tools is left to the first one.
+	set.nameToID[mcpToolName] = toolDef.Function.Name
+	if _, exists := set.nameToID[displayName]; !exists {
+		set.nameToID[displayName] = toolDef.Function.Name
+	}
 
 	// Sanitize schema to be JSON Schema 2020-12 compliant
 	// Fixes: "required": true in properties, and default value types
-	sanitizedParams := sanitizeJSONSchema(toolDef.Function.Parameters)
+	sanitizedParams := schema.Sanitize(toolDef.Function.Parameters)
 
 	// Fix sentence case in parameter descriptions/examples
 	fixedParams := fixSentenceCaseInSchema(sanitizedParams)
@@ -113,16 +384,23 @@ func (s *Server) registerTool(toolDef api.ToolDefinition) error {
 
 	// Store tool with raw schema for tools/list responses
 	// Use MCP-compliant version of display name
+	annotated := s.annotate(toolDef, mcpToolName, displayName)
 	rawTool := ToolWithRawSchema{
-		Name:        mcpToolName,                    // MCP-compliant display name
-		Description: fullDescription,                 // Full description with name
-		InputSchema: fixedParams,
+		Name:         mcpToolName,     // MCP-compliant display name
+		Description:  fullDescription, // Full description with name
+		InputSchema:  fixedParams,
+		OutputSchema: catalogOutputSchema(toolDef),
+		Title:        annotated.Title,
+		Annotations:  annotated.Hints,
+		Meta:         annotated.Meta(),
+		price:        annotatedPrice(annotated),
+		examples:     toolDef.Examples,
 	}
 	// Ensure we have valid JSON schema
 	if len(rawTool.InputSchema) == 0 || string(rawTool.InputSchema) == "null" {
 		rawTool.InputSchema = []byte(`{"type":"object","properties":{}}`)
 	}
-	s.rawTools = append(s.rawTools, rawTool)
+	set.rawTools = append(set.rawTools, rawTool)
 
 	// Still register with SDK for tool execution (use fixed schema)
 	inputSchema := convertParametersToSchema(fixedParams)
@@ -134,7 +412,7 @@ func (s *Server) registerTool(toolDef api.ToolDefinition) error {
 		},
 		Handler: s.createToolHandler(mcpToolName),
 	}
-	s.mcpServer.AddTools(serverTool)
+	set.sdkTools = append(set.sdkTools, serverTool)
 
 	return nil
 }
@@ -244,234 +522,6 @@ func trimSpace(s string) string {
 	return s[start:end]
 }
 
-// sanitizeJSONSchema sanitizes API schemas to be JSON Schema 2020-12 compliant
-// Fixes:
-// 1. "required": true inside properties (moves to top-level required array)
-// 2. Default values with wrong type (e.g., "3600" string → 3600 integer)
-// 3. Default values not in enum list (sets to first enum value)
-func sanitizeJSONSchema(parametersJSON json.RawMessage) json.RawMessage {
-	if len(parametersJSON) == 0 {
-		return parametersJSON
-	}
-
-	// Parse the schema into a map
-	var schema map[string]interface{}
-	if err := json.Unmarshal(parametersJSON, &schema); err != nil {
-		// If parse fails, return as-is
-		return parametersJSON
-	}
-
-	// Extract properties and find fields with "required": true
-	properties, ok := schema["properties"].(map[string]interface{})
-	if !ok {
-		// No properties to fix
-		return parametersJSON
-	}
-
-	// Track which fields should be in the required array
-	requiredFields := []string{}
-
-	// Check existing required array
-	if existingRequired, ok := schema["required"].([]interface{}); ok {
-		for _, field := range existingRequired {
-			if fieldStr, ok := field.(string); ok {
-				requiredFields = append(requiredFields, fieldStr)
-			}
-		}
-	}
-
-	// Process each property
-	for propName, propValue := range properties {
-		propMap, ok := propValue.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// Check for "required": true inside property
-		if required, exists := propMap["required"]; exists {
-			// Remove the invalid "required" from property
-			delete(propMap, "required")
-
-			// If it was true, add to required array (avoid duplicates)
-			if requiredBool, ok := required.(bool); ok && requiredBool {
-				found := false
-				for _, existing := range requiredFields {
-					if existing == propName {
-						found = true
-						break
-					}
-				}
-				if !found {
-					requiredFields = append(requiredFields, propName)
-				}
-			}
-		}
-
-		// Fix default value types based on the "type" field
-		if defaultValue, hasDefault := propMap["default"]; hasDefault {
-			if typeStr, hasType := propMap["type"].(string); hasType {
-				propMap["default"] = convertDefaultValueType(defaultValue, typeStr)
-			}
-		}
-
-		// Validate enum default values - if default is not in enum, fix it
-		if enumValues, hasEnum := propMap["enum"].([]interface{}); hasEnum && len(enumValues) > 0 {
-			if defaultValue, hasDefault := propMap["default"]; hasDefault {
-				// Check if default is in the enum list
-				defaultStr := fmt.Sprintf("%v", defaultValue)
-				found := false
-				for _, enumVal := range enumValues {
-					if fmt.Sprintf("%v", enumVal) == defaultStr {
-						found = true
-						break
-					}
-				}
-				// If not found, set to first enum value
-				if !found {
-					propMap["default"] = enumValues[0]
-					log.Printf("Fixed enum default for property (was '%v', set to '%v')", defaultStr, enumValues[0])
-				}
-			}
-		}
-
-		// Recursively fix nested object properties
-		if propMap["type"] == "object" {
-			if nestedProps, ok := propMap["properties"].(map[string]interface{}); ok {
-				fixNestedProperties(nestedProps)
-			}
-		}
-
-		// Fix arrays with item schemas
-		if propMap["type"] == "array" {
-			if items, ok := propMap["items"].(map[string]interface{}); ok {
-				// Fix default value in items if present
-				if itemDefault, hasDefault := items["default"]; hasDefault {
-					if itemType, hasType := items["type"].(string); hasType {
-						items["default"] = convertDefaultValueType(itemDefault, itemType)
-					}
-				}
-				// Validate enum in array items
-				if enumValues, hasEnum := items["enum"].([]interface{}); hasEnum && len(enumValues) > 0 {
-					if defaultValue, hasDefault := items["default"]; hasDefault {
-						defaultStr := fmt.Sprintf("%v", defaultValue)
-						found := false
-						for _, enumVal := range enumValues {
-							if fmt.Sprintf("%v", enumVal) == defaultStr {
-								found = true
-								break
-							}
-						}
-						if !found {
-							items["default"] = enumValues[0]
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// Update the schema with cleaned properties and required array
-	schema["properties"] = properties
-	if len(requiredFields) > 0 {
-		schema["required"] = requiredFields
-	}
-
-	// Marshal back to JSON
-	sanitized, err := json.Marshal(schema)
-	if err != nil {
-		// If marshal fails, return original
-		return parametersJSON
-	}
-
-	return json.RawMessage(sanitized)
-}
-
-// convertDefaultValueType converts a default value to match its declared type
-func convertDefaultValueType(value interface{}, typeStr string) interface{} {
-	switch typeStr {
-	case "integer":
-		// Convert string to integer if needed
-		if strVal, ok := value.(string); ok {
-			var intVal int
-			fmt.Sscanf(strVal, "%d", &intVal)
-			return intVal
-		}
-		// Already an integer or float, ensure it's int
-		if floatVal, ok := value.(float64); ok {
-			return int(floatVal)
-		}
-		return value
-
-	case "number":
-		// Convert string to float if needed
-		if strVal, ok := value.(string); ok {
-			var floatVal float64
-			fmt.Sscanf(strVal, "%f", &floatVal)
-			return floatVal
-		}
-		return value
-
-	case "boolean":
-		// Convert string to boolean if needed
-		if strVal, ok := value.(string); ok {
-			return strVal == "true" || strVal == "True" || strVal == "TRUE"
-		}
-		return value
-
-	case "string":
-		// Ensure it's a string
-		if strVal, ok := value.(string); ok {
-			return strVal
-		}
-		// Convert other types to string
-		return fmt.Sprintf("%v", value)
-
-	default:
-		return value
-	}
-}
-
-// fixNestedProperties recursively fixes nested object properties
-func fixNestedProperties(properties map[string]interface{}) {
-	for _, propValue := range properties {
-		propMap, ok := propValue.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		// Fix default value types
-		if defaultValue, hasDefault := propMap["default"]; hasDefault {
-			if typeStr, hasType := propMap["type"].(string); hasType {
-				propMap["default"] = convertDefaultValueType(defaultValue, typeStr)
-			}
-		}
-
-		// Validate enum default values
-		if enumValues, hasEnum := propMap["enum"].([]interface{}); hasEnum && len(enumValues) > 0 {
-			if defaultValue, hasDefault := propMap["default"]; hasDefault {
-				defaultStr := fmt.Sprintf("%v", defaultValue)
-				found := false
-				for _, enumVal := range enumValues {
-					if fmt.Sprintf("%v", enumVal) == defaultStr {
-						found = true
-						break
-					}
-				}
-				if !found {
-					propMap["default"] = enumValues[0]
-				}
-			}
-		}
-
-		// Recurse for nested objects
-		if propMap["type"] == "object" {
-			if nestedProps, ok := propMap["properties"].(map[string]interface{}); ok {
-				fixNestedProperties(nestedProps)
-			}
-		}
-	}
-}
-
 // fixSentenceCaseInSchema fixes sentence case in parameter descriptions
 // Capitalizes first letter after "Example: " patterns, handling both escaped and unescaped quotes
 func fixSentenceCaseInSchema(parametersJSON json.RawMessage) json.RawMessage {
@@ -551,9 +601,23 @@ func (s *Server) createToolHandler(toolName string) mcp.ToolHandler {
 	}
 }
 
-// Run starts the MCP server with custom stdio transport
+// Run starts the MCP server on the configured transport
 // We use a custom handler to preserve raw JSON schemas
 func (s *Server) Run(ctx context.Context) error {
-	log.Println("Starting MCP server on stdio transport...")
-	return s.HandleStdioTransport()
+	// Keep the catalog current while serving
+	go s.runRefresher(ctx)
+
+	switch s.transport {
+	case "", TransportStdio:
+		log.Println("Starting MCP server on stdio transport...")
+		return s.HandleStdioTransport(ctx)
+	case TransportHTTP:
+		if s.listenAddr == "" {
+			return fmt.Errorf("http transport requires a listen address")
+		}
+		log.Println("Starting MCP server on Streamable HTTP transport...")
+		return s.HandleHTTPTransport(ctx, s.listenAddr)
+	default:
+		return fmt.Errorf("unknown transport: %s", s.transport)
+	}
 }
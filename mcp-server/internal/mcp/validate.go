@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+)
+
+// InvalidParams is the JSON-RPC error code for arguments that don't match the tool schema
+const InvalidParams = -32602
+
+// reservedArguments are consumed by the server, never checked against a tool's schema
+var reservedArguments = []string{approval.TokenArgument}
+
+// withoutReserved returns a copy of args without the reserved arguments, and those it removed
+func withoutReserved(args map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	checked := make(map[string]interface{}, len(args))
+	for name, value := range args {
+		checked[name] = value
+	}
+	reserved := make(map[string]interface{})
+	for _, name := range reservedArguments {
+		if value, ok := checked[name]; ok {
+			reserved[name] = value
+			delete(checked, name)
+		}
+	}
+	return checked, reserved
+}
+
+// normalizeArguments coerces scalars, fills defaults and drops forbidden
+// properties to fit the tool's input schema, when normalization is enabled
+func (s *Server) normalizeArguments(tool string, args map[string]interface{}) map[string]interface{} {
+	if !s.normalizeArgs {
+		return args
+	}
+
+	checked, reserved := withoutReserved(args)
+	normalized, changes := schema.Normalize(s.inputSchema(tool), checked)
+	if len(changes) == 0 {
+		return args
+	}
+
+	edits := make([]string, len(changes))
+	for i, c := range changes {
+		edits[i] = c.String()
+	}
+	log.Printf("Normalized arguments for %s: %s", tool, strings.Join(edits, "; "))
+
+	for name, value := range reserved {
+		normalized[name] = value
+	}
+	return normalized
+}
+
+// argumentErrors checks args against the tool's sanitized input schema
+// Tools without a known schema are not checked.
+func (s *Server) argumentErrors(tool string, args map[string]interface{}) []schema.FieldError {
+	checked, _ := withoutReserved(args)
+	return schema.Validate(s.inputSchema(tool), checked)
+}
+
+// invalidArguments returns the InvalidParams error for a call with bad arguments
+func invalidArguments(id interface{}, tool string, errs []schema.FieldError) JSONRPCResponse {
+	problems := make([]string, len(errs))
+	for i, e := range errs {
+		problems[i] = e.String()
+	}
+	log.Printf("Rejecting %s: invalid arguments: %s", tool, strings.Join(problems, "; "))
+
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: map[string]interface{}{
+			"code":    InvalidParams,
+			"message": fmt.Sprintf("Invalid arguments for %s: %s", tool, strings.Join(problems, "; ")),
+			"data": map[string]interface{}{
+				"tool":   tool,
+				"errors": errs,
+			},
+		},
+	}
+}
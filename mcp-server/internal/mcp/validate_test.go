@@ -0,0 +1,76 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+)
+
+func TestToolsCallRejectsInvalidArguments(t *testing.T) {
+	server := newTestServer() // No API client: invalid calls must never reach it
+	server.rawTools[0].InputSchema = json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string"},
+			"units": {"type": "string", "enum": ["metric", "imperial"]}
+		},
+		"required": ["city"],
+		"additionalProperties": false
+	}`)
+
+	resp := server.handleToolsCall(context.Background(), newSession("test"), 1,
+		json.RawMessage(`{"name":"test-tool","arguments":{"units":"kelvin","color":"red"}}`))
+
+	rpcErr, ok := resp.Error.(map[string]interface{})
+	if !ok || rpcErr["code"] != InvalidParams {
+		t.Fatalf("Expected an InvalidParams error, got %+v", resp)
+	}
+	errs := rpcErr["data"].(map[string]interface{})["errors"].([]schema.FieldError)
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	if len(errs) != 3 || !fields["city"] || !fields["units"] || !fields["color"] {
+		t.Errorf("Expected errors for city, units and color, got %+v", errs)
+	}
+}
+
+func TestArgumentErrorsIgnoreReservedArguments(t *testing.T) {
+	server := newTestServer()
+	server.rawTools[0].InputSchema = json.RawMessage(`{"type":"object","properties":{},"additionalProperties":false}`)
+
+	args := map[string]interface{}{approval.TokenArgument: "abc"}
+	if errs := server.argumentErrors("test-tool", args); len(errs) != 0 {
+		t.Errorf("Expected the confirmation token to be allowed, got %+v", errs)
+	}
+	if _, kept := args[approval.TokenArgument]; !kept {
+		t.Error("Expected validation to leave the arguments untouched")
+	}
+}
+
+func TestToolsCallNormalizesArgumentsWhenEnabled(t *testing.T) {
+	server := newTestServer()
+	server.rawTools[0].InputSchema = json.RawMessage(`{
+		"type": "object",
+		"properties": {"days": {"type": "integer"}},
+		"required": ["days"],
+		"additionalProperties": false
+	}`)
+	args := map[string]interface{}{"days": "3", approval.TokenArgument: "abc"}
+
+	if got := server.normalizeArguments("test-tool", args); got["days"] != "3" {
+		t.Errorf("Expected no normalization by default, got %v", got)
+	}
+
+	server.normalizeArgs = true
+	got := server.normalizeArguments("test-tool", args)
+	if got["days"] != 3.0 || got[approval.TokenArgument] != "abc" {
+		t.Errorf("Expected days coerced and the token kept, got %v", got)
+	}
+	if errs := server.argumentErrors("test-tool", got); len(errs) != 0 {
+		t.Errorf("Expected normalized arguments to validate, got %v", errs)
+	}
+}
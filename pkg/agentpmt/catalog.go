@@ -0,0 +1,218 @@
+package agentpmt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// Catalog paging limits
+const (
+	DefaultPageSize = 100
+	// MaxPages guards against a misbehaving API reporting has_next_page forever,
+	// when it doesn't report total_qualified_tools to derive a limit from
+	MaxPages = 50
+)
+
+// Tool is a catalog entry, in the API's function-calling format
+type Tool struct {
+	Type     string   `json:"type"`
+	Function Function `json:"function"`
+	Pricing  *Pricing `json:"x-pricing,omitempty"` // Catalog price, when published
+
+	// Annotations are the tool's MCP annotations, when the catalog publishes them
+	Annotations *Annotations `json:"x-annotations,omitempty"`
+
+	// Examples are sample calls
+	Examples []Example `json:"x-examples,omitempty"`
+
+	// OutputSchema describes the tool's output, when the catalog publishes one
+	OutputSchema json.RawMessage `json:"x-output-schema,omitempty"`
+}
+
+// Function names and describes a tool; its Name is the product ID to purchase
+type Function struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"` // JSON schema, as published
+}
+
+// Pricing is the catalog's advertised price for a tool
+type Pricing struct {
+	Cost     float64 `json:"cost"`
+	Currency string  `json:"currency,omitempty"`
+	Unit     string  `json:"unit,omitempty"` // Billing unit, e.g. "call"
+}
+
+// Annotations are hints about a tool's behavior, as defined by MCP
+type Annotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
+}
+
+// Example is a sample call to a tool
+type Example struct {
+	Description string          `json:"description,omitempty"`
+	Arguments   json.RawMessage `json:"arguments"`
+}
+
+// PaginationDetails contains pagination metadata from /products/fetch
+type PaginationDetails struct {
+	ToolsOnThisPage int  `json:"tools_on_this_page"`
+	TotalTools      int  `json:"total_qualified_tools"`
+	PageReturned    int  `json:"page_returned"`
+	PageSize        int  `json:"page_size_requested"`
+	TotalPages      int  `json:"total_pages"`
+	HasNextPage     bool `json:"has_next_page"`
+}
+
+// Page is one page of the catalog, as returned by /products/fetch
+type Page struct {
+	Success bool              `json:"success"`
+	Details PaginationDetails `json:"details"`
+	Tools   []Tool            `json:"tools"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Catalog is the full tool catalog assembled from every page
+type Catalog struct {
+	Tools      []Tool
+	TotalTools int  // Total reported by the API (0 if it didn't say)
+	Pages      int  // Pages fetched
+	Truncated  bool // True if paging stopped at the page limit with pages left
+}
+
+// ListTools fetches one page of the catalog (pages start at 1; pageSize <= 0
+// means DefaultPageSize)
+func (c *Client) ListTools(ctx context.Context, page, pageSize int) (*Page, error) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	path := fmt.Sprintf("%s?page=%d&page_size=%d", FetchEndpoint, page, pageSize)
+
+	label := fmt.Sprintf("Fetching catalog page %d", page)
+	resp, err := c.retry.do(ctx, c.logger, label, func() (*http.Response, error) {
+		req, err := c.newRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+		return c.http.Do(req)
+	}, retryableTransient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tools: %w", errorFromTransport(ctx, err))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, errorFromResponse(resp.StatusCode, body)
+	}
+
+	var out Page
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if !out.Success {
+		return nil, fmt.Errorf("API error: %w", errorFromResponse(resp.StatusCode, body))
+	}
+
+	return &out, nil
+}
+
+// Pages iterates over the catalog a page at a time, following has_next_page.
+// Paging stops at the page limit (enough pages for the total the API reports,
+// plus one; MaxPages when it reports none), or if a page comes back empty
+// while claiming more pages exist, so a broken API can't loop forever. An
+// error ends the iteration.
+func (c *Client) Pages(ctx context.Context, pageSize int) iter.Seq2[*Page, error] {
+	return func(yield func(*Page, error) bool) {
+		c.walk(ctx, pageSize, yield)
+	}
+}
+
+// Tools iterates over every tool in the catalog, fetching pages as needed
+func (c *Client) Tools(ctx context.Context, pageSize int) iter.Seq2[Tool, error] {
+	return func(yield func(Tool, error) bool) {
+		for page, err := range c.Pages(ctx, pageSize) {
+			if err != nil {
+				yield(Tool{}, err)
+				return
+			}
+			for _, tool := range page.Tools {
+				if !yield(tool, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FetchCatalog fetches every page of the catalog
+func (c *Client) FetchCatalog(ctx context.Context, pageSize int) (*Catalog, error) {
+	catalog := &Catalog{}
+	var fetchErr error
+	catalog.Pages, catalog.Truncated = c.walk(ctx, pageSize, func(page *Page, err error) bool {
+		if err != nil {
+			fetchErr = err
+			return false
+		}
+		catalog.Tools = append(catalog.Tools, page.Tools...)
+		if page.Details.TotalTools > 0 {
+			catalog.TotalTools = page.Details.TotalTools
+		}
+		return true
+	})
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	return catalog, nil
+}
+
+// walk passes pages to yield in order until it returns false or paging ends,
+// and reports how many pages were fetched and whether the page limit cut
+// paging short
+func (c *Client) walk(ctx context.Context, pageSize int, yield func(*Page, error) bool) (pages int, truncated bool) {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	limit := MaxPages
+	for page := 1; ; page++ {
+		if page > limit {
+			c.logger.Printf("Warning: stopped fetching catalog after %d pages (page limit reached)", limit)
+			return limit, true
+		}
+
+		resp, err := c.ListTools(ctx, page, pageSize)
+		if err != nil {
+			yield(nil, fmt.Errorf("page %d: %w", page, err))
+			return page - 1, false
+		}
+		if !yield(resp, nil) || !resp.Details.HasNextPage {
+			return page, false
+		}
+		if len(resp.Tools) == 0 {
+			c.logger.Printf("Warning: page %d was empty but reported more pages; stopping", page)
+			return page, false
+		}
+
+		// The reported total bounds paging, with a spare page for tools added
+		// meanwhile; a full page shows the page size the API actually uses
+		if len(resp.Tools) < pageSize {
+			pageSize = len(resp.Tools)
+		}
+		if total := resp.Details.TotalTools; total > 0 {
+			limit = (total+pageSize-1)/pageSize + 1
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package agentpmt is a Go client for the AgentPMT API: list the tool
+// catalog, buy tool calls and stream their output.
+//
+//	client := agentpmt.New(apiKey, budgetKey, agentpmt.WithTimeout(time.Minute))
+//	for tool, err := range client.Tools(ctx, 0) {
+//		...
+//	}
+//
+// Failed calls return errors that wrap one of the Err* kinds, so callers can
+// tell a spent budget from a bad key or an outage with errors.Is.
+package agentpmt
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Version is the version of this package, sent in the default User-Agent
+const Version = "0.1.0"
+
+// Defaults used unless overridden by an Option
+const (
+	DefaultBaseURL   = "https://api.agentpmt.com"
+	DefaultUserAgent = "agentpmt-go/" + Version
+	DefaultTimeout   = 60 * time.Second
+)
+
+// API endpoint paths
+const (
+	FetchEndpoint    = "/products/fetch"
+	PurchaseEndpoint = "/products/purchase"
+)
+
+// Client calls the AgentPMT API with an API key and a budget key.
+// It is safe for concurrent use.
+type Client struct {
+	apiKey    string
+	budgetKey string
+	baseURL   string
+	userAgent string
+	http      *http.Client
+	retry     RetryPolicy
+	logger    *log.Logger
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL points the client at another API deployment, e.g. staging or a local mock
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		if url != "" {
+			c.baseURL = url
+		}
+	}
+}
+
+// WithTimeout limits each HTTP attempt, including reading the body (0 = no limit)
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.http.Timeout = d }
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithTransport sends requests through rt, e.g. for proxies, TLS settings or tests
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) { c.http.Transport = rt }
+}
+
+// WithHTTPClient uses hc for all requests; later WithTimeout and WithTransport
+// options modify hc
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetry replaces DefaultRetryPolicy (MaxAttempts 1 disables retries)
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// WithLogger receives retry and paging messages instead of the standard logger
+func WithLogger(l *log.Logger) Option {
+	return func(c *Client) { c.logger = l }
+}
+
+// New creates a client for the given keys
+func New(apiKey, budgetKey string, opts ...Option) *Client {
+	c := &Client{
+		apiKey:    apiKey,
+		budgetKey: budgetKey,
+		baseURL:   DefaultBaseURL,
+		userAgent: DefaultUserAgent,
+		http:      &http.Client{Timeout: DefaultTimeout},
+		retry:     DefaultRetryPolicy,
+		logger:    log.Default(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseURL returns the API URL the client sends requests to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// newRequest builds an API request with the standard headers
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("X-Budget-Key", c.budgetKey)
+	return req, nil
+}
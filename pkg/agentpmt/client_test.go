@@ -0,0 +1,174 @@
+package agentpmt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// roundTripFunc lets a test stand in for the network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// catalogServer serves totalPages pages of two tools each
+func catalogServer(totalPages int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		resp := Page{
+			Success: true,
+			Details: PaginationDetails{PageReturned: page, TotalTools: 2 * totalPages, HasNextPage: page < totalPages},
+		}
+		for i := 1; i <= 2; i++ {
+			resp.Tools = append(resp.Tools, Tool{Type: "function", Function: Function{Name: fmt.Sprintf("prod-%d-%d", page, i)}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestOptions(t *testing.T) {
+	var got *http.Request
+	client := New("api-key", "budget-key",
+		WithBaseURL("https://staging.example.com"),
+		WithUserAgent("test-agent/1.0"),
+		WithTimeout(5*time.Second),
+		WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			got = r
+			return nil, errors.New("offline")
+		})),
+		WithRetry(RetryPolicy{MaxAttempts: 1}),
+	)
+
+	if client.BaseURL() != "https://staging.example.com" || client.http.Timeout != 5*time.Second {
+		t.Errorf("Options not applied: %s, %s", client.BaseURL(), client.http.Timeout)
+	}
+
+	_, err := client.ListTools(context.Background(), 1, 0)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected an unavailable error from the transport, got %v", err)
+	}
+	if got == nil || got.URL.Host != "staging.example.com" || got.URL.Query().Get("page_size") != "100" {
+		t.Fatalf("Unexpected request %v", got)
+	}
+	for header, want := range map[string]string{"User-Agent": "test-agent/1.0", "X-API-Key": "api-key", "X-Budget-Key": "budget-key"} {
+		if got.Header.Get(header) != want {
+			t.Errorf("%s = %q, want %q", header, got.Header.Get(header), want)
+		}
+	}
+}
+
+func TestToolsIteratesAcrossPages(t *testing.T) {
+	server := catalogServer(3)
+	defer server.Close()
+	client := New("api-key", "budget-key", WithBaseURL(server.URL))
+
+	var names []string
+	for tool, err := range client.Tools(context.Background(), 2) {
+		if err != nil {
+			t.Fatalf("Tools() error = %v", err)
+		}
+		names = append(names, tool.Function.Name)
+		if len(names) == 3 {
+			break // Stopping early must not fetch the last page
+		}
+	}
+	if fmt.Sprint(names) != "[prod-1-1 prod-1-2 prod-2-1]" {
+		t.Errorf("Tools() = %v", names)
+	}
+
+	catalog, err := client.FetchCatalog(context.Background(), 2)
+	if err != nil || len(catalog.Tools) != 6 || catalog.Pages != 3 || catalog.TotalTools != 6 || catalog.Truncated {
+		t.Errorf("FetchCatalog() = %+v, %v", catalog, err)
+	}
+}
+
+func TestFetchCatalogPageLimit(t *testing.T) {
+	// A catalog bigger than MaxPages is fetched whole when the API reports its size
+	server := catalogServer(MaxPages + 10)
+	defer server.Close()
+	client := New("api-key", "budget-key", WithBaseURL(server.URL))
+	catalog, err := client.FetchCatalog(context.Background(), 2)
+	if err != nil || catalog.Pages != MaxPages+10 || catalog.Truncated {
+		t.Errorf("Expected all %d pages, got %d (truncated %v), %v", MaxPages+10, catalog.Pages, catalog.Truncated, err)
+	}
+
+	// An API claiming more pages forever is cut off: at MaxPages, or one page past its total
+	for total, want := range map[int]int{0: MaxPages, 5: 4} {
+		endless := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(Page{
+				Success: true,
+				Details: PaginationDetails{TotalTools: total, HasNextPage: true},
+				Tools:   []Tool{{Type: "function"}, {Type: "function"}},
+			})
+		}))
+		client := New("api-key", "budget-key", WithBaseURL(endless.URL))
+		catalog, err := client.FetchCatalog(context.Background(), 2)
+		endless.Close()
+		if err != nil || catalog.Pages != want || !catalog.Truncated {
+			t.Errorf("total %d: expected truncation after %d pages, got %d (truncated %v), %v", total, want, catalog.Pages, catalog.Truncated, err)
+		}
+	}
+}
+
+func TestPurchaseOutputShapes(t *testing.T) {
+	bodies := map[string]string{
+		"flat":   `{"success":true,"output":"sunny","cost":0.05}`,
+		"nested": `{"success":true,"response":{"status_code":200,"data":{"success":true,"output":"sunny"}}}`,
+		"json":   `{"success":true,"output":{"sky":"sunny"}}`,
+	}
+	want := map[string]string{"flat": "sunny", "nested": "sunny", "json": `{"sky":"sunny"}`}
+
+	for name, body := range bodies {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req PurchaseRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.ProductID != "weather" || string(req.Parameters) != `{}` || r.Header.Get(IdempotencyHeader) != "key-1" {
+				t.Errorf("%s: unexpected request %+v, key %q", name, req, r.Header.Get(IdempotencyHeader))
+			}
+			w.Write([]byte(body))
+		}))
+
+		client := New("api-key", "budget-key", WithBaseURL(server.URL))
+		resp, err := client.Purchase(context.Background(), PurchaseRequest{ProductID: "weather", IdempotencyKey: "key-1"})
+		if err != nil || resp.OutputText() != want[name] {
+			t.Errorf("%s: Purchase() = %+v, %v; want output %s", name, resp, err, want[name])
+		}
+		server.Close()
+	}
+}
+
+func TestStreamPurchase(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("stream") != "true" || r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("Expected a streaming request, got %s %v", r.URL, r.Header)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		if r.Header.Get("X-Budget-Key") == "empty-budget" {
+			fmt.Fprint(w, "data: partial\n\nevent: error\ndata: Insufficient balance\n\n")
+			return
+		}
+		fmt.Fprint(w, "data: Hello\n\ndata: , world\n\nevent: done\ndata: \n\n")
+	}))
+	defer server.Close()
+
+	var chunks []string
+	client := New("api-key", "budget-key", WithBaseURL(server.URL))
+	err := client.StreamPurchase(context.Background(), PurchaseRequest{ProductID: "echo"}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil || fmt.Sprint(chunks) != "[Hello , world]" {
+		t.Errorf("StreamPurchase() = %q, %v", chunks, err)
+	}
+
+	client = New("api-key", "empty-budget", WithBaseURL(server.URL))
+	err = client.StreamPurchase(context.Background(), PurchaseRequest{ProductID: "echo"}, func(string) {})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected a budget error from the stream, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package agentpmt
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Shapes of purchase response that DecodePurchaseResponse recognizes
+const (
+	ShapeFlat    = "flat"    // {"success": true, "output": ...}
+	ShapeNested  = "nested"  // {"success": true, "response": {"status_code": 200, "data": {"output": ...}}}
+	ShapeUnknown = "unknown" // Anything else; Output is then the whole payload
+)
+
+// envelope is every field a purchase response has been seen to use, at any level
+type envelope struct {
+	Success         *bool           `json:"success"`
+	Output          json.RawMessage `json:"output"`
+	Data            *envelope       `json:"data"`
+	Response        *envelope       `json:"response"`
+	StatusCode      json.RawMessage `json:"status_code"`
+	PurchaseResult  json.RawMessage `json:"purchase_result"`
+	PurchaseDetails json.RawMessage `json:"purchase_details"`
+	Cost            json.RawMessage `json:"cost"`
+	Error           json.RawMessage `json:"error"`
+	ErrorCode       string          `json:"error_code"`
+	Message         string          `json:"message"`
+	Detail          string          `json:"detail"`
+}
+
+// DecodePurchaseResponse parses a purchase response in either shape the API
+// uses, flat or nested, and pulls out the output, purchase result and
+// details, the tool's status code and any error. It never fails: a payload it
+// doesn't recognize, JSON or not, comes back as a successful response with
+// Shape ShapeUnknown and the whole payload as Output, so nothing is lost.
+func DecodePurchaseResponse(body []byte) *PurchaseResponse {
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return unknownShape(body)
+	}
+
+	out := &PurchaseResponse{
+		PurchaseResult:  text(env.PurchaseResult),
+		PurchaseDetails: env.PurchaseDetails,
+		Cost:            number(env.Cost),
+		errorCode:       env.ErrorCode,
+	}
+
+	// Output: flat first, then the nested forms
+	switch {
+	case env.Output != nil:
+		out.Output, out.Shape = env.Output, ShapeFlat
+	case env.Response != nil && env.Response.Data != nil && env.Response.Data.Output != nil:
+		out.Output, out.Shape = env.Response.Data.Output, ShapeNested
+	case env.Response != nil && env.Response.Output != nil:
+		out.Output, out.Shape = env.Response.Output, ShapeNested
+	case env.Data != nil && env.Data.Output != nil:
+		out.Output, out.Shape = env.Data.Output, ShapeNested
+	}
+
+	// Error and status code, wherever the API put them
+	for _, e := range []*envelope{&env, env.Response, env.Data, nestedData(env.Response)} {
+		if e == nil {
+			continue
+		}
+		if out.Error == "" {
+			out.Error, out.errorCode = errorText(e.Error, out.errorCode)
+		}
+		if out.StatusCode == 0 {
+			out.StatusCode, _ = strconv.Atoi(text(e.StatusCode))
+		}
+	}
+
+	// Success: no level says otherwise and there is no error. The outer flag
+	// only reports the purchase went through, so a failing tool inside a
+	// successful envelope is still a failure.
+	stated, failed := false, false
+	for _, e := range []*envelope{&env, env.Response, nestedData(env.Response), env.Data} {
+		if e != nil && e.Success != nil {
+			stated, failed = true, failed || !*e.Success
+		}
+	}
+	out.Success = !failed && out.Error == "" && (stated || out.Output != nil)
+	if !out.Success && out.Error == "" {
+		out.Error = firstNonEmpty(env.Message, env.Detail)
+	}
+
+	// Nothing to report either way: pass the payload on rather than drop it
+	if out.Output == nil && out.Error == "" && !failed {
+		return unknownShape(body)
+	}
+	return out
+}
+
+// decodeEvent decodes an SSE event's data if it is a whole purchase response
+// rather than a chunk of output
+func decodeEvent(data string) (*PurchaseResponse, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(data), "{") {
+		return nil, false
+	}
+	var probe struct {
+		Success  *bool           `json:"success"`
+		Output   json.RawMessage `json:"output"`
+		Response json.RawMessage `json:"response"`
+		Error    json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal([]byte(data), &probe) != nil || probe.Success == nil ||
+		(probe.Output == nil && probe.Response == nil && probe.Error == nil) {
+		return nil, false
+	}
+	return DecodePurchaseResponse([]byte(data)), true
+}
+
+// unknownShape passes an unrecognized payload on whole
+func unknownShape(body []byte) *PurchaseResponse {
+	output := json.RawMessage(body)
+	if !json.Valid(body) {
+		output, _ = json.Marshal(string(body))
+	}
+	return &PurchaseResponse{Success: true, Output: output, Shape: ShapeUnknown}
+}
+
+func nestedData(e *envelope) *envelope {
+	if e == nil {
+		return nil
+	}
+	return e.Data
+}
+
+// errorText reads an error given as a string or as {"code", "message"}
+func errorText(raw json.RawMessage, code string) (string, string) {
+	if s := text(raw); s != "" && !strings.HasPrefix(s, "{") {
+		return s, code
+	}
+	var obj struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(raw, &obj) == nil {
+		if code == "" {
+			code = obj.Code
+		}
+		return obj.Message, code
+	}
+	return "", code
+}
+
+// text returns a JSON string unquoted and any other value as JSON ("" for null)
+func text(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// number reads a JSON number, or a string holding one
+func number(raw json.RawMessage) *float64 {
+	f, err := strconv.ParseFloat(text(raw), 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package agentpmt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodePurchaseResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		shape   string
+		output  string
+		success bool
+		status  int
+		errText string
+	}{
+		{"flat", `{"success":true,"output":"sunny","purchase_result":"ok","cost":"0.05"}`, ShapeFlat, "sunny", true, 0, ""},
+		{"nested", `{"success":true,"response":{"status_code":200,"success":true,"data":{"success":true,"output":{"sky":"sunny"}}}}`,
+			ShapeNested, `{"sky":"sunny"}`, true, 200, ""},
+		{"half nested", `{"success":true,"response":{"output":"sunny"}}`, ShapeNested, "sunny", true, 0, ""},
+		{"data only", `{"data":{"output":"sunny"}}`, ShapeNested, "sunny", true, 0, ""},
+		{"nested failure", `{"success":true,"response":{"status_code":402,"success":false,"data":{"error":"Insufficient funds"}}}`,
+			"", "", true, 402, "Insufficient funds"},
+		{"error object", `{"success":false,"error":{"code":"rate_limited","message":"Slow down"}}`, "", "", false, 0, "Slow down"},
+		{"message", `{"success":false,"message":"Tool crashed"}`, "", "", false, 0, "Tool crashed"},
+		{"unknown JSON", `{"result":"sunny"}`, ShapeUnknown, `{"result":"sunny"}`, true, 0, ""},
+		{"plain text", `sunny`, ShapeUnknown, "sunny", true, 0, ""},
+	}
+
+	for _, tt := range tests {
+		r := DecodePurchaseResponse([]byte(tt.body))
+		if r.Shape != tt.shape || r.OutputText() != tt.output || r.StatusCode != tt.status || r.Error != tt.errText {
+			t.Errorf("%s: got shape %q, output %q, status %d, error %q", tt.name, r.Shape, r.OutputText(), r.StatusCode, r.Error)
+		}
+		// A failing tool under a successful envelope still isn't a success
+		if want := tt.success && tt.errText == ""; r.Success != want {
+			t.Errorf("%s: Success = %v, want %v", tt.name, r.Success, want)
+		}
+	}
+
+	r := DecodePurchaseResponse([]byte(`{"success":true,"output":1,"purchase_result":"ok","purchase_details":{"id":"r1"},"cost":"0.05"}`))
+	if r.PurchaseResult != "ok" || string(r.PurchaseDetails) != `{"id":"r1"}` || r.Cost == nil || *r.Cost != 0.05 {
+		t.Errorf("Expected the receipt fields, got %+v", r)
+	}
+}
+
+func TestPurchaseFailuresAndUnknownShapes(t *testing.T) {
+	body := `{"success":true,"response":{"status_code":402,"success":false,"data":{"error":"Insufficient funds"}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	client := New("api-key", "budget-key", WithBaseURL(server.URL), WithLogger(log.New(&logs, "", 0)))
+
+	_, err := client.Purchase(context.Background(), PurchaseRequest{ProductID: "weather"})
+	if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), "Insufficient funds") {
+		t.Errorf("Expected the nested failure to be reported, got %v", err)
+	}
+
+	body = `{"answer":42}`
+	resp, err := client.Purchase(context.Background(), PurchaseRequest{ProductID: "weather"})
+	if err != nil || resp.OutputText() != body || !strings.Contains(logs.String(), body) {
+		t.Errorf("Expected the payload passed on with a warning, got %+v, %v (logs %q)", resp, err, logs.String())
+	}
+}
+
+func TestStreamPurchaseDecodesJSONEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"success\":true,\"response\":{\"data\":{\"output\":\"sunny\"}}}\n\n")
+		fmt.Fprint(w, "data: {\"not\":\"a purchase\"}\n\n")
+		fmt.Fprint(w, "data: {\"success\":false,\"error\":\"Product not found\"}\n\n")
+	}))
+	defer server.Close()
+
+	var chunks []string
+	client := New("api-key", "budget-key", WithBaseURL(server.URL))
+	err := client.StreamPurchase(context.Background(), PurchaseRequest{ProductID: "weather"}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if fmt.Sprint(chunks) != `[sunny {"not":"a purchase"}]` || !errors.Is(err, ErrProductNotFound) {
+		t.Errorf("StreamPurchase() = %q, %v", chunks, err)
+	}
+}
@@ -0,0 +1,282 @@
+package agentpmt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Kinds of API failure; test with errors.Is
+var (
+	ErrUnauthorized    = errors.New("unauthorized")      // Missing, invalid or revoked API or budget key
+	ErrBudgetExceeded  = errors.New("budget exceeded")   // Not enough budget left to pay for the call
+	ErrRateLimited     = errors.New("rate limited")      // Too many requests; try again later
+	ErrProductNotFound = errors.New("product not found") // Unknown or withdrawn product ID
+	ErrInvalidRequest  = errors.New("invalid request")   // Arguments the API or the tool rejected
+	ErrUpstream        = errors.New("upstream error")    // The API or the tool behind it failed
+	ErrUnavailable     = errors.New("unavailable")       // The API couldn't be reached or timed out
+)
+
+// codes are the machine-readable names of the kinds
+var codes = map[error]string{
+	ErrUnauthorized:    "unauthorized",
+	ErrBudgetExceeded:  "budget_exceeded",
+	ErrRateLimited:     "rate_limited",
+	ErrProductNotFound: "product_not_found",
+	ErrInvalidRequest:  "invalid_request",
+	ErrUpstream:        "upstream_error",
+	ErrUnavailable:     "unavailable",
+}
+
+// Error is a classified API failure
+type Error struct {
+	Kind    error  // One of the Err* kinds
+	Status  int    // HTTP status, 0 if no response was received
+	Message string // What the API (or transport) said
+}
+
+func (e *Error) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("%s (status %d): %s", e.Kind, e.Status, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+func (e *Error) Unwrap() error { return e.Kind }
+
+// errorFromResponse classifies an HTTP error response, or a 2xx one whose body
+// reports success: false
+func errorFromResponse(status int, body []byte) *Error {
+	code, message := parseBody(body)
+	return classify(status, code, message)
+}
+
+// classify picks the kind of a failure from, in order: a specific HTTP status,
+// a known error code in the body, and only then well-known phrases in the
+// message. A plain 400 stays an invalid request unless the body says more.
+func classify(status int, code, message string) *Error {
+	kind := kindOfStatus(status)
+	if kind == nil || kind == ErrInvalidRequest {
+		if k := kindOfCode(code); k != nil {
+			kind = k
+		} else if k := kindOfMessage(message); k != nil {
+			kind = k
+		}
+	}
+	if kind == nil {
+		kind = ErrUpstream
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &Error{Kind: kind, Status: status, Message: message}
+}
+
+// errorFromMessage classifies a failure reported only as text, such as an SSE error event
+func errorFromMessage(message string) *Error {
+	kind := kindOfMessage(message)
+	if kind == nil {
+		kind = ErrUpstream
+	}
+	return &Error{Kind: kind, Message: message}
+}
+
+// errorFromTransport classifies an error from sending a request: the API was
+// unreachable unless ctx itself was cancelled, which is returned unchanged.
+func errorFromTransport(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return err
+	}
+	return &Error{Kind: ErrUnavailable, Message: err.Error()}
+}
+
+// ErrorCode returns err's machine-readable code, or "" if it isn't an API error
+func ErrorCode(err error) string {
+	for kind, code := range codes {
+		if errors.Is(err, kind) {
+			return code
+		}
+	}
+	return ""
+}
+
+// ErrorMeta describes err for a tool result's _meta: its machine-readable
+// code, whether it is retryable and the HTTP status, if any. It returns nil
+// for errors that didn't come from the API.
+func ErrorMeta(err error) map[string]interface{} {
+	code := ErrorCode(err)
+	if code == "" {
+		return nil
+	}
+	meta := map[string]interface{}{
+		"errorCode": code,
+		"retryable": Retryable(err),
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Status != 0 {
+		meta["httpStatus"] = apiErr.Status
+	}
+	return meta
+}
+
+// Retryable reports whether trying the same call later may succeed.
+// ErrUpstream isn't retryable: the tool may have run, and been paid for,
+// before it failed.
+func Retryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable)
+}
+
+func kindOfStatus(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUnauthorized
+	case status == http.StatusPaymentRequired:
+		return ErrBudgetExceeded
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return ErrProductNotFound
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return ErrInvalidRequest
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout ||
+		status == http.StatusServiceUnavailable:
+		return ErrUnavailable
+	case status >= 500:
+		return ErrUpstream
+	}
+	return nil
+}
+
+// errorCodes maps the API's structured error codes to kinds
+var errorCodes = map[string]error{
+	"unauthorized":            ErrUnauthorized,
+	"forbidden":               ErrUnauthorized,
+	"authentication_failed":   ErrUnauthorized,
+	"invalid_api_key":         ErrUnauthorized,
+	"invalid_budget_key":      ErrUnauthorized,
+	"budget_exceeded":         ErrBudgetExceeded,
+	"insufficient_budget":     ErrBudgetExceeded,
+	"insufficient_funds":      ErrBudgetExceeded,
+	"insufficient_balance":    ErrBudgetExceeded,
+	"payment_required":        ErrBudgetExceeded,
+	"spending_limit_exceeded": ErrBudgetExceeded,
+	"rate_limited":            ErrRateLimited,
+	"rate_limit_exceeded":     ErrRateLimited,
+	"too_many_requests":       ErrRateLimited,
+	"product_not_found":       ErrProductNotFound,
+	"unknown_product":         ErrProductNotFound,
+	"invalid_request":         ErrInvalidRequest,
+	"invalid_parameters":      ErrInvalidRequest,
+	"validation_error":        ErrInvalidRequest,
+	"missing_parameter":       ErrInvalidRequest,
+	"upstream_error":          ErrUpstream,
+	"tool_error":              ErrUpstream,
+	"unavailable":             ErrUnavailable,
+	"service_unavailable":     ErrUnavailable,
+}
+
+// kindOfCode looks up a structured error code ("Insufficient-Funds" and
+// "insufficient_funds" are the same code); unknown codes return nil
+func kindOfCode(code string) error {
+	return errorCodes[strings.Join(words(code), "_")]
+}
+
+// messagePhrases are whole phrases that identify a kind in free text. They
+// are a last resort for responses without a status or code that says more,
+// so each must be specific: a lone "budget" or "invalid" proves nothing.
+var messagePhrases = map[string]error{
+	"invalid api key":           ErrUnauthorized,
+	"invalid budget key":        ErrUnauthorized,
+	"api key not recognized":    ErrUnauthorized,
+	"budget key not recognized": ErrUnauthorized,
+	"authentication failed":     ErrUnauthorized,
+	"insufficient funds":        ErrBudgetExceeded,
+	"insufficient balance":      ErrBudgetExceeded,
+	"insufficient budget":       ErrBudgetExceeded,
+	"budget exceeded":           ErrBudgetExceeded,
+	"spending limit exceeded":   ErrBudgetExceeded,
+	"rate limit exceeded":       ErrRateLimited,
+	"too many requests":         ErrRateLimited,
+	"product not found":         ErrProductNotFound,
+	"unknown product":           ErrProductNotFound,
+	"is required":               ErrInvalidRequest,
+	"missing required":          ErrInvalidRequest,
+	"validation error":          ErrInvalidRequest,
+	"invalid parameter":         ErrInvalidRequest,
+	"invalid argument":          ErrInvalidRequest,
+}
+
+// kindOfMessage finds a message phrase on word boundaries, so "x-budget-key
+// not recognized" matches but "budgets" doesn't match "budget". When phrases
+// of different kinds match, the message is ambiguous and nil is returned.
+func kindOfMessage(message string) error {
+	text := " " + strings.Join(words(message), " ") + " "
+	var kind error
+	for phrase, k := range messagePhrases {
+		if !strings.Contains(text, " "+phrase+" ") {
+			continue
+		}
+		if kind != nil && kind != k {
+			return nil
+		}
+		kind = k
+	}
+	return kind
+}
+
+// words lowercases s and splits it at everything but letters and digits
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// parseBody extracts an error code and message from a JSON error body,
+// falling back to the body as text
+func parseBody(body []byte) (code, message string) {
+	var out struct {
+		Error     json.RawMessage `json:"error"`
+		ErrorCode string          `json:"error_code"`
+		Code      interface{}     `json:"code"`
+		Message   string          `json:"message"`
+		Detail    string          `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", strings.TrimSpace(string(body))
+	}
+
+	code = out.ErrorCode
+	if c, ok := out.Code.(string); ok && code == "" {
+		code = c
+	}
+	message = out.Message
+	if message == "" {
+		message = out.Detail
+	}
+
+	// "error" is either the message or an object with its own code and message
+	var text string
+	var nested struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(out.Error, &text) == nil && text != "" {
+		message = text
+	} else if json.Unmarshal(out.Error, &nested) == nil {
+		if nested.Message != "" {
+			message = nested.Message
+		}
+		if nested.Code != "" {
+			code = nested.Code
+		}
+	}
+	return code, message
+}
@@ -0,0 +1,86 @@
+package agentpmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorFromResponse(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		kind   error
+		msg    string
+	}{
+		{401, `{"error":"Invalid API key"}`, ErrUnauthorized, "Invalid API key"},
+		{402, `{"detail":"Out of credit"}`, ErrBudgetExceeded, "Out of credit"},
+		{404, ``, ErrProductNotFound, "Not Found"},
+		{429, `slow down`, ErrRateLimited, "slow down"},
+		{502, `{"message":"bad gateway"}`, ErrUpstream, "bad gateway"},
+		{503, ``, ErrUnavailable, "Service Unavailable"},
+		{400, `{"error":{"code":"insufficient_budget","message":"Budget spent"}}`, ErrBudgetExceeded, "Budget spent"},
+		{400, `{"error":"city is required"}`, ErrInvalidRequest, "city is required"},
+		{200, `{"success":false,"error":"X-Budget-Key not recognized"}`, ErrUnauthorized, "X-Budget-Key not recognized"},
+		{200, `{"success":false,"error":"Rate limit exceeded"}`, ErrRateLimited, "Rate limit exceeded"},
+		{200, `{"success":false,"error":"tool crashed"}`, ErrUpstream, "tool crashed"},
+		{200, `{"success":false,"error":"missing required param budget"}`, ErrInvalidRequest, "missing required param budget"},
+		{400, `{"error":"budget must be a number"}`, ErrInvalidRequest, "budget must be a number"},
+		{200, `{"success":false,"error_code":"INSUFFICIENT-FUNDS","error":"declined"}`, ErrBudgetExceeded, "declined"},
+		{401, `{"error":"budget exceeded"}`, ErrUnauthorized, "budget exceeded"},
+		{200, `{"success":false,"error":"budgets are refreshed hourly"}`, ErrUpstream, "budgets are refreshed hourly"},
+	}
+
+	for _, tt := range tests {
+		err := errorFromResponse(tt.status, []byte(tt.body))
+		if !errors.Is(err, tt.kind) || err.Message != tt.msg || err.Status != tt.status {
+			t.Errorf("errorFromResponse(%d, %s) = %+v; want %v, %q", tt.status, tt.body, err, tt.kind, tt.msg)
+		}
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	err := errorFromResponse(429, nil)
+	if ErrorCode(err) != "rate_limited" || !Retryable(err) {
+		t.Errorf("ErrorCode(429) = %s, retryable %v", ErrorCode(err), Retryable(err))
+	}
+	if err := errorFromMessage("insufficient balance"); ErrorCode(err) != "budget_exceeded" || Retryable(err) {
+		t.Errorf("ErrorCode(insufficient balance) = %s, retryable %v", ErrorCode(err), Retryable(err))
+	}
+	if err := errorFromResponse(502, nil); ErrorCode(err) != "upstream_error" || Retryable(err) {
+		t.Errorf("ErrorCode(502) = %s, retryable %v", ErrorCode(err), Retryable(err))
+	}
+	if MayHaveCharged(errorFromResponse(402, nil)) || !MayHaveCharged(errorFromResponse(502, nil)) {
+		t.Error("Expected a refusal not to charge, and an upstream failure to maybe charge")
+	}
+	if code := ErrorCode(errors.New("boom")); code != "" {
+		t.Errorf("Expected no code for other errors, got %s", code)
+	}
+}
+
+func TestErrorMeta(t *testing.T) {
+	err := fmt.Errorf("failed to execute tool: %w", &Error{Kind: ErrRateLimited, Status: 429})
+	if meta := ErrorMeta(err); meta["errorCode"] != "rate_limited" || meta["retryable"] != true || meta["httpStatus"] != 429 {
+		t.Errorf("ErrorMeta(429) = %v", meta)
+	}
+	if meta := ErrorMeta(&Error{Kind: ErrBudgetExceeded}); meta["errorCode"] != "budget_exceeded" || meta["retryable"] != false || meta["httpStatus"] != nil {
+		t.Errorf("ErrorMeta(budget) = %v", meta)
+	}
+	if meta := ErrorMeta(errors.New("boom")); meta != nil {
+		t.Errorf("Expected no meta for other errors, got %v", meta)
+	}
+}
+
+func TestErrorFromTransport(t *testing.T) {
+	err := errorFromTransport(context.Background(), errors.New("connection refused"))
+	if !errors.Is(err, ErrUnavailable) || !Retryable(err) {
+		t.Errorf("Expected an unavailable error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := errorFromTransport(ctx, context.Canceled); ErrorCode(err) != "" {
+		t.Errorf("Expected cancellations to pass through, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package agentpmt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PurchaseRequest buys one call of a tool
+type PurchaseRequest struct {
+	ProductID  string          `json:"product_id"`
+	Parameters json.RawMessage `json:"parameters"`
+
+	// IdempotencyKey identifies the purchase across retries; a random one is used if empty
+	IdempotencyKey string `json:"-"`
+}
+
+// PurchaseResponse is a decoded response from /products/purchase, the same
+// whichever shape the API answered in (see DecodePurchaseResponse)
+type PurchaseResponse struct {
+	Success         bool            `json:"success"`
+	Output          json.RawMessage `json:"output,omitempty"`      // The tool's output, wherever the API put it
+	StatusCode      int             `json:"status_code,omitempty"` // The tool's status code, when reported
+	PurchaseResult  string          `json:"purchase_result,omitempty"`
+	PurchaseDetails json.RawMessage `json:"purchase_details,omitempty"` // The receipt, as reported
+	Cost            *float64        `json:"cost,omitempty"`             // Charged amount, when reported
+	Error           string          `json:"error,omitempty"`
+
+	// Shape is the response shape recognized: ShapeFlat, ShapeNested or ShapeUnknown
+	Shape string `json:"-"`
+
+	errorCode string // The API's own error code, used to classify failures
+}
+
+// Purchase calls a tool and waits for its output.
+// Transient failures are retried like catalog fetches; every attempt sends the
+// same Idempotency-Key, so the API charges for the purchase at most once.
+func (c *Client) Purchase(ctx context.Context, req PurchaseRequest) (*PurchaseResponse, error) {
+	resp, err := c.sendPurchase(ctx, req, "Purchase", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &unconfirmedError{fmt.Errorf("failed to read response: %w", err)}
+	}
+
+	return c.decodePurchase(resp.StatusCode, body)
+}
+
+// unconfirmedError marks a purchase that failed after its request was sent,
+// so the API may have charged for it
+type unconfirmedError struct{ err error }
+
+func (e *unconfirmedError) Error() string { return e.err.Error() }
+
+func (e *unconfirmedError) Unwrap() error { return e.err }
+
+// MayHaveCharged reports whether a failed purchase may still have been
+// charged: the request reached the API but no clear answer came back (a
+// timeout, a dropped connection, a cancellation or a broken response), or
+// the API answered ErrUpstream or a gateway timeout, which can come after
+// the tool was bought. Refusals the API answered with, and failures before
+// anything was sent, return false.
+func MayHaveCharged(err error) bool {
+	var unconfirmed *unconfirmedError
+	if errors.As(err, &unconfirmed) {
+		return true
+	}
+	var apiErr *Error
+	return errors.As(err, &apiErr) && (apiErr.Kind == ErrUpstream || apiErr.Status == http.StatusGatewayTimeout)
+}
+
+// sendPurchase posts req, retrying transient failures under one idempotency
+// key, and returns a 2xx response for the caller to read. The API recognizes
+// a retried key and answers it with the original outcome instead of charging
+// again. A failure after any attempt reached the API is unconfirmed.
+func (c *Client) sendPurchase(ctx context.Context, req PurchaseRequest, label, query string, header http.Header) (*http.Response, error) {
+	if req.Parameters == nil {
+		req.Parameters = json.RawMessage(`{}`)
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	key := req.IdempotencyKey
+	if key == "" {
+		key = NewIdempotencyKey()
+	}
+
+	label = fmt.Sprintf("%s of %s (key %s)", label, req.ProductID, key)
+	trace := &purchaseTrace{}
+	resp, err := c.retry.do(ctx, c.logger, label, func() (*http.Response, error) {
+		httpReq, err := c.newRequest(ctx, "POST", PurchaseEndpoint+query, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range header {
+			httpReq.Header[name] = values
+		}
+		httpReq.Header.Set(IdempotencyHeader, key)
+		return c.http.Do(trace.with(httpReq))
+	}, retryableTransient)
+	if err != nil {
+		err = fmt.Errorf("request failed: %w", errorFromTransport(ctx, err))
+		if trace.wrote.Load() {
+			return nil, &unconfirmedError{err}
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, errorFromResponse(resp.StatusCode, body)
+	}
+	return resp, nil
+}
+
+// decodePurchase decodes a 2xx purchase response body, warning about
+// payloads in a shape it doesn't recognize
+func (c *Client) decodePurchase(status int, body []byte) (*PurchaseResponse, error) {
+	out := DecodePurchaseResponse(body)
+	if !out.Success {
+		return nil, fmt.Errorf("purchase failed: %w", out.failure(status))
+	}
+	if out.Shape == ShapeUnknown {
+		c.logger.Printf("Warning: unrecognized purchase response, passing it on whole: %s", body)
+	}
+	return out, nil
+}
+
+// failure classifies an unsuccessful purchase; a failing status code reported
+// by the tool says more than the HTTP status of the response carrying it
+func (r *PurchaseResponse) failure(httpStatus int) *Error {
+	status := httpStatus
+	if r.StatusCode >= 300 {
+		status = r.StatusCode
+	}
+	return classify(status, r.errorCode, r.Error)
+}
+
+// OutputText returns the tool's output as text: a JSON string output is
+// unquoted, anything else is returned as JSON
+func (r *PurchaseResponse) OutputText() string {
+	return text(r.Output)
+}
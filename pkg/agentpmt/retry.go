@@ -0,0 +1,186 @@
+package agentpmt
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// IdempotencyHeader carries the key that lets the API recognize a retried purchase
+const IdempotencyHeader = "Idempotency-Key"
+
+// RetryPolicy decides how often and how long to wait before retrying a failed request
+type RetryPolicy struct {
+	MaxAttempts int           // Including the first (<= 1 never retries)
+	BaseDelay   time.Duration // Wait before the first retry; doubles for each one after
+	MaxDelay    time.Duration // Longest single wait, including one asked for by Retry-After
+}
+
+// DefaultRetryPolicy retries twice, after about 250ms and 500ms
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 250 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+// Delay returns the wait before retry n (1 = first retry): exponential
+// backoff capped at MaxDelay, with the upper half randomized so clients that
+// failed together don't retry together.
+func (p RetryPolicy) Delay(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(mathrand.Int63n(int64(d-half)+1))
+}
+
+// do calls send until retry rejects the outcome (a response or an error) or
+// attempts run out. Retries wait out Delay or, when longer, the response's
+// Retry-After (capped at MaxDelay). label names the request in logs. send
+// must build a fresh request each time.
+func (p RetryPolicy) do(ctx context.Context, logger *log.Logger, label string, send func() (*http.Response, error), retry func(*http.Response, error) bool) (*http.Response, error) {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := send()
+
+		var reason string
+		var wait time.Duration
+		switch {
+		case err != nil && (ctx.Err() != nil || !retry(nil, err)):
+			return nil, err
+		case err != nil:
+			reason = err.Error()
+		case !retry(resp, nil):
+			if attempt > 1 {
+				logger.Printf("%s: status %d on attempt %d/%d", label, resp.StatusCode, attempt, attempts)
+			}
+			return resp, nil
+		default:
+			reason = fmt.Sprintf("status %d", resp.StatusCode)
+			wait, _ = retryAfter(resp.Header, time.Now())
+		}
+
+		if attempt >= attempts {
+			logger.Printf("%s: giving up after %d attempts (%s)", label, attempt, reason)
+			if err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			// Drain so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if backoff := p.Delay(attempt); wait < backoff {
+			wait = backoff
+		}
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			wait = p.MaxDelay
+		}
+		logger.Printf("%s: %s; retrying in %s (attempt %d/%d)", label, reason, wait.Round(time.Millisecond), attempt+1, attempts)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryableTransient decides retries of catalog fetches, and of purchases
+// under their Idempotency-Key: any transient error or status
+func retryableTransient(resp *http.Response, err error) bool {
+	if err != nil {
+		return retryableError(err)
+	}
+	return retryableStatus(resp.StatusCode)
+}
+
+// retryableStatus reports whether a response status means "try again later"
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableError reports whether a transport error is likely transient:
+// connection resets and refusals, dropped connections, dial failures and timeouts
+func retryableError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// purchaseTrace records whether any attempt of a purchase reached the API
+type purchaseTrace struct {
+	wrote atomic.Bool // Request headers were written, so the API may have the request
+}
+
+// with returns a request whose progress is recorded in t
+func (t *purchaseTrace) with(req *http.Request) *http.Request {
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteHeaders: func() { t.wrote.Store(true) },
+	}))
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// NewIdempotencyKey returns a random idempotency key (UUID version 4)
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,195 @@
+package agentpmt
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fast = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestDelayBacksOffWithinBounds(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for n, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := p.Delay(n); d < max/2 || d > max {
+				t.Errorf("Delay(%d) = %s, want between %s and %s", n, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestDoRetriesTransientStatus(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := fast.do(context.Background(), log.Default(), "test", func() (*http.Response, error) {
+		return http.Get(server.URL)
+	}, retryableTransient)
+	if err != nil || resp.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatalf("Expected success on the third attempt, got %v, %v after %d calls", resp, err, calls)
+	}
+	resp.Body.Close()
+}
+
+func TestDoStopsOnPermanentFailures(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	resp, err := fast.do(context.Background(), log.Default(), "test", func() (*http.Response, error) {
+		return http.Get(server.URL)
+	}, retryableTransient)
+	if err != nil || resp.StatusCode != http.StatusBadRequest || calls != 1 {
+		t.Errorf("Expected one attempt for a 400, got %d", calls)
+	}
+	resp.Body.Close()
+
+	// A closed server refuses connections: transient, retried, then reported
+	server.Close()
+	calls = 0
+	_, err = fast.do(context.Background(), log.Default(), "test", func() (*http.Response, error) {
+		calls++
+		return http.Get(server.URL)
+	}, retryableTransient)
+	if err == nil || calls != 3 {
+		t.Errorf("Expected 3 attempts and an error, got %d, %v", calls, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := http.Header{}
+
+	if _, ok := retryAfter(h, now); ok {
+		t.Error("Expected no Retry-After")
+	}
+	h.Set("Retry-After", "7")
+	if d, ok := retryAfter(h, now); !ok || d != 7*time.Second {
+		t.Errorf("RetryAfter(7) = %s, %v", d, ok)
+	}
+	h.Set("Retry-After", now.Add(30*time.Second).Format(http.TimeFormat))
+	if d, ok := retryAfter(h, now); !ok || d != 30*time.Second {
+		t.Errorf("RetryAfter(date) = %s, %v", d, ok)
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	a, b := NewIdempotencyKey(), NewIdempotencyKey()
+	if a == b || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(a) {
+		t.Errorf("Expected distinct UUIDs, got %s and %s", a, b)
+	}
+}
+
+func TestPurchaseRetriesUnderOneKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     []int // Status of each call; the last one repeats
+		retryAfter string
+		calls      int
+		ok         bool
+	}{
+		{"bad gateway", []int{502, 200}, "", 2, true},
+		{"unavailable without Retry-After", []int{503, 200}, "", 2, true},
+		{"rate limited", []int{429, 429, 200}, "0", 3, true},
+		{"budget exceeded", []int{402}, "", 1, false},
+		{"bad gateway throughout", []int{502}, "", 3, false},
+	}
+	for _, tt := range tests {
+		var keys []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Header.Get(IdempotencyHeader))
+			status := tt.status[len(tt.status)-1]
+			if len(keys) <= len(tt.status) {
+				status = tt.status[len(keys)-1]
+			}
+			if tt.retryAfter != "" && status != 200 {
+				w.Header().Set("Retry-After", tt.retryAfter)
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(`{"success":true,"output":"ok"}`))
+		}))
+
+		client := New("api-key", "budget-key", WithBaseURL(server.URL), WithRetry(fast))
+		_, err := client.Purchase(context.Background(), PurchaseRequest{ProductID: "weather"})
+		if len(keys) != tt.calls || (err == nil) != tt.ok {
+			t.Errorf("%s: %d calls, error %v; want %d calls, ok %v", tt.name, len(keys), err, tt.calls, tt.ok)
+		}
+		for _, key := range keys {
+			if key != keys[0] {
+				t.Errorf("%s: expected one idempotency key across attempts, got %v", tt.name, keys)
+			}
+		}
+		server.Close()
+	}
+}
+
+func TestPurchaseRetriedAfterSending(t *testing.T) {
+	// The first attempt is delivered, then the connection drops: retry under the same key
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyHeader))
+		if len(keys) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(`{"success":true,"output":"ok"}`))
+	}))
+	defer server.Close()
+
+	client := New("api-key", "budget-key", WithBaseURL(server.URL), WithRetry(fast))
+	if _, err := client.Purchase(context.Background(), PurchaseRequest{ProductID: "weather"}); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if len(keys) != 2 || keys[1] != keys[0] {
+		t.Errorf("Expected 2 attempts sharing one key, got %q", keys)
+	}
+
+	// Every answer is late: the API may have charged, so the failure is unconfirmed
+	var calls atomic.Int32
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	client = New("api-key", "budget-key", WithBaseURL(slow.URL), WithRetry(fast), WithTimeout(50*time.Millisecond))
+	_, err := client.Purchase(context.Background(), PurchaseRequest{ProductID: "weather"})
+	if err == nil || calls.Load() != 3 || !MayHaveCharged(err) {
+		t.Errorf("Expected 3 attempts and a timeout that may have charged, got %d, %v", calls.Load(), err)
+	}
+
+	// Nothing was sent to a refused connection, so nothing can have been charged
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	attempts := 0
+	client = New("api-key", "budget-key", WithBaseURL(closed.URL), WithRetry(fast), WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return http.DefaultTransport.RoundTrip(r)
+	})))
+	_, err = client.Purchase(context.Background(), PurchaseRequest{ProductID: "weather"})
+	if !errors.Is(err, ErrUnavailable) || attempts != 3 || MayHaveCharged(err) {
+		t.Errorf("Expected 3 uncharged attempts at a refused connection, got %d, %v", attempts, err)
+	}
+}
@@ -0,0 +1,73 @@
+package agentpmt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+// StreamPurchase calls a tool and passes its output to onChunk as the API
+// streams it (server-sent events). Events carrying a whole JSON purchase
+// response are decoded like Purchase's and their output passed on. If the API
+// answers with a plain JSON response instead, its whole output is passed as
+// one chunk. Failures before the stream starts are retried like Purchase;
+// once events arrive the call is never retried.
+func (c *Client) StreamPurchase(ctx context.Context, req PurchaseRequest, onChunk func(string)) error {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	resp, err := c.sendPurchase(ctx, req, "Streaming purchase", "?stream=true", header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		// Not SSE, fall back to regular response
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return &unconfirmedError{fmt.Errorf("failed to read response: %w", err)}
+		}
+
+		out, err := c.decodePurchase(resp.StatusCode, body)
+		if err != nil {
+			return err
+		}
+
+		// Send entire output as one chunk
+		onChunk(out.OutputText())
+		return nil
+	}
+
+	for event, err := range sse.Read(resp.Body, nil) {
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return &unconfirmedError{fmt.Errorf("SSE read error: %w", err)}
+		}
+
+		// Some deployments send whole purchase responses as JSON events
+		purchase, isPurchase := decodeEvent(event.Data)
+		if isPurchase && !purchase.Success {
+			return fmt.Errorf("purchase failed: %w", purchase.failure(resp.StatusCode))
+		}
+
+		switch event.Type {
+		case "data", "": // Default event type
+			if isPurchase {
+				onChunk(purchase.OutputText())
+			} else if event.Data != "" {
+				onChunk(event.Data)
+			}
+		case "error":
+			return fmt.Errorf("stream error: %w", errorFromMessage(event.Data))
+		case "done":
+			return nil
+		}
+	}
+
+	return nil
+}
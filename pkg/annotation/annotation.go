@@ -0,0 +1,151 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the override file name inside the install directory
+const FileName = "tool-overrides.json"
+
+// CostMetaKey is the tool _meta key that carries Cost
+const CostMetaKey = "agentpmt.com/cost"
+
+// Hints are the standard MCP tool annotations (nil hint = not stated)
+// The catalog publishes them as x-annotations in the same shape.
+type Hints struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
+}
+
+// Empty reports whether no annotation is stated
+func (h *Hints) Empty() bool {
+	return h == nil || (h.Title == "" && h.ReadOnlyHint == nil && h.DestructiveHint == nil &&
+		h.IdempotentHint == nil && h.OpenWorldHint == nil)
+}
+
+// Cost is the price annotation sent in a tool's _meta
+type Cost struct {
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency,omitempty"`
+	Unit     string  `json:"unit,omitempty"` // What one price buys, e.g. "call" or "image"
+}
+
+// Override replaces catalog metadata for one product
+// Hints are merged one field at a time; a cost replaces the catalog price.
+type Override struct {
+	Title       string `json:"title,omitempty"`
+	Annotations *Hints `json:"annotations,omitempty"`
+	Cost        *Cost  `json:"cost,omitempty"`
+}
+
+// Overrides are keyed by product ID or MCP tool name
+type Overrides map[string]Override
+
+// Find returns the override for a tool, preferring its product ID
+func (o Overrides) Find(productID, name string) *Override {
+	if override, ok := o[productID]; ok {
+		return &override
+	}
+	if override, ok := o[name]; ok {
+		return &override
+	}
+	return nil
+}
+
+// DefaultPath returns the override file path next to the executable
+func DefaultPath() string {
+	if exePath, err := os.Executable(); err == nil {
+		return filepath.Join(filepath.Dir(exePath), FileName)
+	}
+	return FileName
+}
+
+// Load reads the override file at path; a missing file means no overrides
+func Load(path string) (Overrides, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tool overrides: %w", err)
+	}
+
+	var o Overrides
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, fmt.Errorf("failed to parse tool overrides %s: %w", path, err)
+	}
+	return o, nil
+}
+
+// Tool is the metadata a listed tool is annotated with
+type Tool struct {
+	Title      string
+	Hints      *Hints // nil when nothing is stated
+	Cost       *Cost  // nil when the price is unknown
+	CostSource string // "catalog" or "override"
+}
+
+// Resolve combines catalog metadata with an optional override
+// title is the fallback title, usually the display name from the description.
+func Resolve(title string, hints *Hints, cost *Cost, override *Override) Tool {
+	var merged Hints
+	if hints != nil {
+		merged = *hints
+	}
+	t := Tool{Cost: cost}
+	if cost != nil {
+		t.CostSource = "catalog"
+	}
+
+	if override != nil {
+		if o := override.Annotations; o != nil {
+			if o.Title != "" {
+				merged.Title = o.Title
+			}
+			merged.ReadOnlyHint = pick(o.ReadOnlyHint, merged.ReadOnlyHint)
+			merged.DestructiveHint = pick(o.DestructiveHint, merged.DestructiveHint)
+			merged.IdempotentHint = pick(o.IdempotentHint, merged.IdempotentHint)
+			merged.OpenWorldHint = pick(o.OpenWorldHint, merged.OpenWorldHint)
+		}
+		if override.Title != "" {
+			merged.Title = override.Title
+		}
+		if override.Cost != nil {
+			t.Cost, t.CostSource = override.Cost, "override"
+		}
+	}
+
+	if merged.Title == "" {
+		merged.Title = title
+	}
+	t.Title = merged.Title
+	if !merged.Empty() {
+		t.Hints = &merged
+	}
+	return t
+}
+
+// Meta returns the tool's _meta object (nil when there is nothing to say)
+func (t Tool) Meta() map[string]interface{} {
+	if t.Cost == nil {
+		return nil
+	}
+	return map[string]interface{}{CostMetaKey: t.Cost}
+}
+
+// pick returns the override value when it is stated
+func pick(override, catalog *bool) *bool {
+	if override != nil {
+		return override
+	}
+	return catalog
+}
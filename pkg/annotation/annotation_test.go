@@ -0,0 +1,76 @@
+package annotation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestResolveMergesOverride(t *testing.T) {
+	catalog := &Hints{ReadOnlyHint: boolPtr(true), OpenWorldHint: boolPtr(true)}
+	override := &Override{
+		Title:       "Image Generator",
+		Annotations: &Hints{ReadOnlyHint: boolPtr(false), DestructiveHint: boolPtr(false)},
+		Cost:        &Cost{Price: 0.04, Currency: "USD", Unit: "image"},
+	}
+
+	got := Resolve("image-gen", catalog, &Cost{Price: 0.05}, override)
+	if got.Title != "Image Generator" || got.Hints.Title != "Image Generator" {
+		t.Errorf("Expected the override title, got %q", got.Title)
+	}
+	if *got.Hints.ReadOnlyHint || *got.Hints.DestructiveHint || !*got.Hints.OpenWorldHint || got.Hints.IdempotentHint != nil {
+		t.Errorf("Unexpected hints %+v", got.Hints)
+	}
+	if got.Cost.Price != 0.04 || got.CostSource != "override" {
+		t.Errorf("Expected the override cost, got %+v from %s", got.Cost, got.CostSource)
+	}
+	if *catalog.ReadOnlyHint != true {
+		t.Error("Expected the catalog hints to be left alone")
+	}
+
+	meta, _ := json.Marshal(got.Meta())
+	if string(meta) != `{"agentpmt.com/cost":{"price":0.04,"currency":"USD","unit":"image"}}` {
+		t.Errorf("Meta() = %s", meta)
+	}
+}
+
+func TestResolveWithoutMetadata(t *testing.T) {
+	got := Resolve("Weather Check", nil, nil, nil)
+	if got.Title != "Weather Check" || got.Hints == nil || got.Hints.Title != "Weather Check" {
+		t.Errorf("Expected the fallback title, got %+v", got)
+	}
+	if got.Cost != nil || got.Meta() != nil {
+		t.Errorf("Expected no cost, got %+v", got.Cost)
+	}
+	if got := Resolve("", nil, nil, nil); got.Hints != nil {
+		t.Errorf("Expected no hints at all, got %+v", got.Hints)
+	}
+}
+
+func TestLoadAndFind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if o, err := Load(path); err != nil || o != nil {
+		t.Fatalf("Expected a missing file to mean no overrides, got %v, %v", o, err)
+	}
+
+	data := `{"prod-1": {"title": "By ID"}, "weather": {"title": "By name"}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	o, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := o.Find("prod-1", "weather"); got == nil || got.Title != "By ID" {
+		t.Errorf("Expected the product ID to win, got %+v", got)
+	}
+	if got := o.Find("prod-2", "weather"); got == nil || got.Title != "By name" {
+		t.Errorf("Expected a match by name, got %+v", got)
+	}
+	if got := o.Find("prod-3", "news"); got != nil {
+		t.Errorf("Expected no override, got %+v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package approval
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenArgument is the tools/call argument that carries a confirmation token
+// It is removed from the arguments before the purchase is sent.
+const TokenArgument = "_confirmation_token"
+
+// DefaultTokenTTL is how long a confirmation token stays valid
+const DefaultTokenTTL = 5 * time.Minute
+
+// Rules decide which purchases need the user's approval (zero value: none)
+type Rules struct {
+	Always bool     `json:"always,omitempty"` // Every purchase
+	Above  float64  `json:"above,omitempty"`  // Expected cost above this; an unknown cost also asks
+	Tools  []string `json:"tools,omitempty"`  // Tool names that always ask
+}
+
+// Enabled reports whether any rule is configured
+func (r Rules) Enabled() bool {
+	return r.Always || r.Above > 0 || len(r.Tools) > 0
+}
+
+// Required reports whether a purchase of tool needs approval, and why
+func (r Rules) Required(tool string, cost float64, costKnown bool) (bool, string) {
+	if r.Always {
+		return true, "every purchase needs approval"
+	}
+	for _, name := range r.Tools {
+		if name == tool {
+			return true, fmt.Sprintf("%s always needs approval", tool)
+		}
+	}
+	if r.Above > 0 {
+		if !costKnown {
+			return true, fmt.Sprintf("cost is unknown and purchases above %.2f need approval", r.Above)
+		}
+		if cost > r.Above {
+			return true, fmt.Sprintf("expected cost %.2f is above %.2f", cost, r.Above)
+		}
+	}
+	return false, ""
+}
+
+// Tokens issues single-use confirmation tokens for clients without elicitation
+// A token is bound to the session, the tool and the exact arguments.
+type Tokens struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	issued map[string]grant
+	now    func() time.Time
+}
+
+// grant is what a token approves
+type grant struct {
+	session string
+	tool    string
+	digest  string
+	expires time.Time
+}
+
+// NewTokens creates a token store; ttl <= 0 uses DefaultTokenTTL
+func NewTokens(ttl time.Duration) *Tokens {
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	return &Tokens{ttl: ttl, issued: make(map[string]grant), now: time.Now}
+}
+
+// TTL returns how long issued tokens stay valid
+func (t *Tokens) TTL() time.Duration {
+	return t.ttl
+}
+
+// Issue returns a new token approving one purchase of tool with args
+func (t *Tokens) Issue(session, tool string, args interface{}) string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	token := hex.EncodeToString(buf)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for key, g := range t.issued {
+		if now.After(g.expires) {
+			delete(t.issued, key)
+		}
+	}
+	t.issued[token] = grant{
+		session: session,
+		tool:    tool,
+		digest:  digest(args),
+		expires: now.Add(t.ttl),
+	}
+	return token
+}
+
+// Redeem consumes token if it approves this exact purchase
+func (t *Tokens) Redeem(token, session, tool string, args interface{}) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	g, exists := t.issued[token]
+	if !exists {
+		return false
+	}
+	delete(t.issued, token)
+
+	return g.session == session && g.tool == tool && g.digest == digest(args) && !t.now().After(g.expires)
+}
+
+// digest fingerprints arguments (encoding/json sorts map keys, so equal maps match)
+func digest(args interface{}) string {
+	data, _ := json.Marshal(args)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,69 @@
+package approval
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRulesRequired(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     Rules
+		tool      string
+		cost      float64
+		costKnown bool
+		want      bool
+	}{
+		{"no rules", Rules{}, "alpha", 10, true, false},
+		{"always", Rules{Always: true}, "alpha", 0, true, true},
+		{"listed tool", Rules{Tools: []string{"alpha"}}, "alpha", 0, true, true},
+		{"unlisted tool", Rules{Tools: []string{"alpha"}}, "beta", 0, true, false},
+		{"above price", Rules{Above: 1}, "alpha", 1.5, true, true},
+		{"below price", Rules{Above: 1}, "alpha", 0.5, true, false},
+		{"unknown price", Rules{Above: 1}, "alpha", 0, false, true},
+	}
+
+	for _, tt := range tests {
+		got, reason := tt.rules.Required(tt.tool, tt.cost, tt.costKnown)
+		if got != tt.want {
+			t.Errorf("%s: Required() = %v (%s), want %v", tt.name, got, reason, tt.want)
+		}
+	}
+}
+
+func TestTokenIsSingleUseAndBound(t *testing.T) {
+	tokens := NewTokens(0)
+	args := map[string]interface{}{"q": "weather", "n": 3.0}
+
+	token := tokens.Issue("s1", "alpha", args)
+	if tokens.Redeem(token, "s1", "alpha", map[string]interface{}{"q": "other", "n": 3.0}) {
+		t.Error("Expected token to be refused for different arguments")
+	}
+
+	// A failed redemption consumes the token
+	if tokens.Redeem(token, "s1", "alpha", args) {
+		t.Error("Expected token to be single-use")
+	}
+
+	token = tokens.Issue("s1", "alpha", args)
+	if tokens.Redeem(token, "s2", "alpha", args) {
+		t.Error("Expected token to be refused for another session")
+	}
+
+	token = tokens.Issue("s1", "alpha", args)
+	if !tokens.Redeem(token, "s1", "alpha", map[string]interface{}{"n": 3.0, "q": "weather"}) {
+		t.Error("Expected token to approve the same purchase")
+	}
+}
+
+func TestTokenExpires(t *testing.T) {
+	now := time.Now()
+	tokens := NewTokens(time.Minute)
+	tokens.now = func() time.Time { return now }
+
+	token := tokens.Issue("s1", "alpha", nil)
+	now = now.Add(2 * time.Minute)
+	if tokens.Redeem(token, "s1", "alpha", nil) {
+		t.Error("Expected expired token to be refused")
+	}
+}
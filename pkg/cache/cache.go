@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+)
+
+// Version is the cache file format version; files with another version are ignored
+const Version = 1
+
+// FileName is the cache file name inside the install directory
+const FileName = "tool-cache.json"
+
+// File is the on-disk tool catalog cache
+// Tools are stored in the API's wire format so the file is interchangeable
+// between agent-payment-server and agent-payment-router.
+type File struct {
+	Version     int               `json:"version"`
+	SavedAt     time.Time         `json:"saved_at"`
+	Fingerprint string            `json:"key_fingerprint"` // Which credentials the catalog belongs to
+	Tools       []agentpmt.Tool   `json:"tools"`
+	NameToID    map[string]string `json:"name_to_id"` // MCP tool name -> product ID
+}
+
+// DefaultPath returns the cache path next to the executable
+// Falls back to the user cache directory when the install directory isn't writable.
+func DefaultPath() string {
+	if exePath, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exePath)
+		if isWritable(dir) {
+			return filepath.Join(dir, FileName)
+		}
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "agentpmt", FileName)
+	}
+	return FileName
+}
+
+// Fingerprint identifies a set of credentials without storing them
+// A cache written for one budget key is never served for another.
+func Fingerprint(apiKey, budgetKey string) string {
+	sum := sha256.Sum256([]byte(apiKey + "\x00" + budgetKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Load reads the cache at path, rejecting other versions and other credentials
+func Load(path, fingerprint string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse cache: %w", err)
+	}
+	if f.Version != Version {
+		return nil, fmt.Errorf("cache version %d is not supported (want %d)", f.Version, Version)
+	}
+	if f.Fingerprint != fingerprint {
+		return nil, fmt.Errorf("cache belongs to different credentials")
+	}
+
+	return &f, nil
+}
+
+// Save writes the cache atomically (write to a temp file, then rename)
+func Save(path string, f *File) error {
+	f.Version = Version
+	if f.SavedAt.IsZero() {
+		f.SavedAt = time.Now().UTC()
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), FileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace cache: %w", err)
+	}
+	return nil
+}
+
+// isWritable reports whether files can be created in dir
+func isWritable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	fp := Fingerprint("api-key", "budget-key")
+
+	err := Save(path, &File{
+		Fingerprint: fp,
+		Tools: []agentpmt.Tool{
+			{Type: "function", Function: agentpmt.Function{Name: "prod-1", Description: "Tool One — Does one thing"}},
+		},
+		NameToID: map[string]string{"tool-one": "prod-1"},
+	})
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	f, err := Load(path, fp)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if f.Version != Version {
+		t.Errorf("Expected version %d, got %d", Version, f.Version)
+	}
+	if len(f.Tools) != 1 || f.Tools[0].Function.Name != "prod-1" {
+		t.Errorf("Tools not preserved: %+v", f.Tools)
+	}
+	if f.NameToID["tool-one"] != "prod-1" {
+		t.Errorf("Name map not preserved: %+v", f.NameToID)
+	}
+	if f.SavedAt.IsZero() {
+		t.Error("Expected SavedAt to be set")
+	}
+}
+
+func TestLoadRejectsOtherCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := Save(path, &File{Fingerprint: Fingerprint("a", "b")}); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if _, err := Load(path, Fingerprint("a", "other")); err == nil {
+		t.Error("Expected error loading a cache written for other credentials")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json"), "fp"); err == nil {
+		t.Error("Expected error for missing cache file")
+	}
+}
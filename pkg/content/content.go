@@ -0,0 +1,423 @@
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxInline is the largest payload (decoded bytes) embedded in a message
+// Anything bigger is written to the output directory and linked instead.
+const DefaultMaxInline = 1 << 20
+
+// Block is one MCP content block of a tool result
+type Block struct {
+	Type     string    `json:"type"` // "text", "image", "audio", "resource" or "resource_link"
+	Text     string    `json:"text,omitempty"`
+	Data     string    `json:"data,omitempty"` // Base64, for image and audio
+	MimeType string    `json:"mimeType,omitempty"`
+	URI      string    `json:"uri,omitempty"`  // For resource_link
+	Name     string    `json:"name,omitempty"` // For resource_link
+	Resource *Resource `json:"resource,omitempty"`
+}
+
+// Resource is an embedded resource: text or a base64 blob
+type Resource struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// Text returns a text block
+func Text(text string) Block {
+	return Block{Type: "text", Text: text}
+}
+
+// MarshalJSON always includes "text" on text blocks, even when it is empty
+func (b Block) MarshalJSON() ([]byte, error) {
+	if b.Type == "text" {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{b.Type, b.Text})
+	}
+	type plain Block
+	return json.Marshal(plain(b))
+}
+
+// Extractor finds images, audio and files in tool output
+type Extractor struct {
+	Dir       string // Where payloads too large to embed are written
+	MaxInline int    // Largest payload embedded in a message (0 = DefaultMaxInline)
+}
+
+// DefaultDir returns the directory large outputs are written to
+func DefaultDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "agentpmt", "outputs")
+	}
+	return filepath.Join(os.TempDir(), "agentpmt-outputs")
+}
+
+// NewExtractor creates an extractor that writes large payloads to dir ("" = DefaultDir())
+func NewExtractor(dir string) *Extractor {
+	if dir == "" {
+		dir = DefaultDir()
+	}
+	return &Extractor{Dir: dir, MaxInline: DefaultMaxInline}
+}
+
+// Extract inspects output for base64 payloads, data URLs and media URLs
+// Returns output with every extracted payload replaced by a short note, so text
+// and structured copies stay small, plus one content block per payload.
+// A nil Extractor returns output unchanged.
+func (x *Extractor) Extract(output interface{}) (interface{}, []Block) {
+	if x == nil {
+		return output, nil
+	}
+
+	var blocks []Block
+	add := func(b Block) { blocks = append(blocks, b) }
+
+	if text, ok := output.(string); ok {
+		// A bare payload has no key to hint at it, so only obvious media counts
+		if note, ok := x.extractString(text, "", "", true, add); ok {
+			return note, blocks
+		}
+		return output, nil
+	}
+
+	return x.walk(output, add), blocks
+}
+
+// ExtractText is Extract for output that arrives as text, which may itself be JSON
+// Returns the text unchanged when nothing was extracted.
+func (x *Extractor) ExtractText(text string) (string, []Block) {
+	var output interface{}
+	if json.Unmarshal([]byte(text), &output) != nil {
+		output = text
+	}
+
+	redacted, blocks := x.Extract(output)
+	if len(blocks) == 0 {
+		return text, nil
+	}
+	if note, ok := redacted.(string); ok {
+		return note, blocks
+	}
+	data, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return text, blocks
+	}
+	return string(data), blocks
+}
+
+// walk rewrites value, extracting payloads from objects and arrays
+func (x *Extractor) walk(value interface{}, add func(Block)) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		mimeHint, nameHint := objectHints(v)
+		out := make(map[string]interface{}, len(v))
+		for _, key := range sortedKeys(v) {
+			out[key] = v[key]
+			switch item := v[key].(type) {
+			case string:
+				if isURLKey(key) {
+					x.linkURL(item, mimeHint, nameHint, add)
+				} else if note, ok := x.extractString(item, mimeHint, nameHint, isDataKey(key), add); ok {
+					out[key] = note
+				}
+			case map[string]interface{}, []interface{}:
+				out[key] = x.walk(item, add)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			if text, ok := item.(string); ok {
+				if note, ok := x.extractString(text, "", "", false, add); ok {
+					out[i] = note
+					continue
+				}
+			}
+			out[i] = x.walk(item, add)
+		}
+		return out
+	}
+	return value
+}
+
+// extractString turns a data URL or base64 payload into a block
+// Plain base64 is only considered when hinted, or when it sniffs as media.
+func (x *Extractor) extractString(s, mimeHint, nameHint string, hinted bool, add func(Block)) (string, bool) {
+	if data, mimeType, ok := parseDataURL(s); ok {
+		return x.emit(data, mimeType, nameHint, add), true
+	}
+
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		if !hinted {
+			return "", false
+		}
+		x.linkURL(s, mimeHint, nameHint, add)
+		return "", false
+	}
+
+	if len(s) < 64 || (!hinted && mimeHint == "") {
+		return "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		if data, err = base64.RawStdEncoding.DecodeString(s); err != nil {
+			return "", false
+		}
+	}
+
+	mimeType := mimeHint
+	if mimeType == "" {
+		mimeType = mimeByName(nameHint)
+	}
+	if mimeType == "" {
+		mimeType = sniff(data)
+	}
+	if mimeType == "" {
+		return "", false // Decodes as base64 but doesn't look like media: leave it be
+	}
+	return x.emit(data, mimeType, nameHint, add), true
+}
+
+// linkURL adds a resource_link for a URL that points at media
+func (x *Extractor) linkURL(s, mimeHint, nameHint string, add func(Block)) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+
+	name := nameHint
+	if name == "" {
+		name = path.Base(u.Path)
+	}
+	mimeType := mimeHint
+	if mimeType == "" {
+		mimeType = mimeByName(path.Base(u.Path))
+	}
+	if !isMedia(mimeType) {
+		return // Web pages and unknown links stay in the text
+	}
+
+	add(Block{Type: "resource_link", URI: s, Name: name, MimeType: mimeType})
+}
+
+// emit adds the block for a decoded payload and returns the note that replaces it
+func (x *Extractor) emit(data []byte, mimeType, name string, add func(Block)) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	if name == "" {
+		name = fileName(data, mimeType)
+	}
+
+	maxInline := x.MaxInline
+	if maxInline <= 0 {
+		maxInline = DefaultMaxInline
+	}
+	if len(data) > maxInline {
+		saved, err := x.save(data, fileName(data, mimeType))
+		if err == nil {
+			uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(saved)}).String()
+			add(Block{Type: "resource_link", URI: uri, Name: name, MimeType: mimeType})
+			return fmt.Sprintf("[%s, %d bytes, saved to %s]", mimeType, len(data), saved)
+		}
+		// Couldn't write it: embedding is better than losing it
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	uri := "agentpmt://outputs/" + url.PathEscape(name)
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		add(Block{Type: "image", Data: encoded, MimeType: mimeType})
+	case strings.HasPrefix(mimeType, "audio/"):
+		add(Block{Type: "audio", Data: encoded, MimeType: mimeType})
+	case isText(mimeType):
+		add(Block{Type: "resource", Resource: &Resource{URI: uri, MimeType: mimeType, Text: string(data)}})
+	default:
+		add(Block{Type: "resource", Resource: &Resource{URI: uri, MimeType: mimeType, Blob: encoded}})
+	}
+	return fmt.Sprintf("[%s, %d bytes, attached]", mimeType, len(data))
+}
+
+// save writes a payload to the output directory
+func (x *Extractor) save(data []byte, name string) (string, error) {
+	if err := os.MkdirAll(x.Dir, 0700); err != nil {
+		return "", err
+	}
+	file := filepath.Join(x.Dir, name)
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// parseDataURL decodes data:<mime>;base64,<payload>
+func parseDataURL(s string) ([]byte, string, bool) {
+	if !strings.HasPrefix(s, "data:") {
+		return nil, "", false
+	}
+	meta, payload, found := strings.Cut(s[len("data:"):], ",")
+	if !found || !strings.HasSuffix(meta, ";base64") {
+		return nil, "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, "", false
+	}
+	mimeType := strings.TrimSuffix(meta, ";base64")
+	if mimeType == "" {
+		mimeType = sniff(data)
+	}
+	return data, mimeType, true
+}
+
+// objectHints reads MIME type and file name hints from an object's keys
+func objectHints(obj map[string]interface{}) (mimeType, name string) {
+	for _, key := range []string{"mime_type", "mimeType", "mimetype", "content_type", "contentType", "media_type", "mediaType"} {
+		if s, ok := obj[key].(string); ok && strings.Contains(s, "/") {
+			mimeType = s
+			break
+		}
+	}
+	if mimeType == "" {
+		if format, ok := obj["format"].(string); ok {
+			mimeType = mimeByName("file." + format)
+		}
+	}
+	for _, key := range []string{"filename", "file_name", "fileName", "name"} {
+		if s, ok := obj[key].(string); ok && filepath.Ext(s) != "" {
+			name = filepath.Base(s)
+			break
+		}
+	}
+	return mimeType, name
+}
+
+// isDataKey reports whether a key usually holds a base64 payload
+func isDataKey(key string) bool {
+	switch strings.ToLower(key) {
+	case "data", "base64", "b64", "b64_json", "blob", "bytes", "image", "audio", "file", "content", "body":
+		return true
+	}
+	lower := strings.ToLower(key)
+	return strings.HasSuffix(lower, "_base64") || strings.HasSuffix(lower, "base64") || strings.HasSuffix(lower, "_b64")
+}
+
+// isURLKey reports whether a key usually holds a link to the output
+func isURLKey(key string) bool {
+	lower := strings.ToLower(key)
+	switch lower {
+	case "url", "uri", "href", "link":
+		return true
+	}
+	return strings.HasSuffix(lower, "_url") || strings.HasSuffix(key, "Url") || strings.HasSuffix(key, "URL")
+}
+
+// sniff detects media from the payload itself; "" for text or unknown bytes
+func sniff(data []byte) string {
+	mimeType := http.DetectContentType(data)
+	mimeType = strings.SplitN(mimeType, ";", 2)[0]
+	if mimeType == "application/octet-stream" || strings.HasPrefix(mimeType, "text/") {
+		return ""
+	}
+	return mimeType
+}
+
+// commonTypes covers media extensions the system MIME table may not know
+var commonTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".m4a":  "audio/mp4",
+	".flac": "audio/flac",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".csv":  "text/csv",
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".zip":  "application/zip",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// mimeByName guesses a MIME type from a file name's extension
+func mimeByName(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	if mimeType, ok := commonTypes[ext]; ok {
+		return mimeType
+	}
+	return strings.SplitN(mime.TypeByExtension(ext), ";", 2)[0]
+}
+
+// isMedia reports whether a linked MIME type is worth a resource_link
+func isMedia(mimeType string) bool {
+	if mimeType == "" || mimeType == "text/html" {
+		return false
+	}
+	return true
+}
+
+// isText reports whether a payload can be embedded as text
+func isText(mimeType string) bool {
+	switch mimeType {
+	case "application/json", "application/xml", "application/yaml", "image/svg+xml":
+		return true
+	}
+	return strings.HasPrefix(mimeType, "text/")
+}
+
+// fileName derives a stable file name from the payload hash and MIME type
+func fileName(data []byte, mimeType string) string {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:8])
+	for ext, known := range commonTypes {
+		if known == mimeType {
+			return name + ext
+		}
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		sort.Strings(exts)
+		name += preferredExt(exts)
+	}
+	return name
+}
+
+// preferredExt picks the usual extension among the registered ones
+func preferredExt(exts []string) string {
+	for _, ext := range exts {
+		switch ext {
+		case ".jpg", ".png", ".gif", ".webp", ".mp3", ".wav", ".pdf", ".txt", ".json", ".csv":
+			return ext
+		}
+	}
+	return exts[0]
+}
+
+// sortedKeys returns map keys in order so blocks come out deterministically
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
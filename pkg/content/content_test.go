@@ -0,0 +1,119 @@
+package content
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// pngBytes is enough of a PNG for content sniffing
+var pngBytes = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+func TestExtractDataURL(t *testing.T) {
+	x := &Extractor{Dir: t.TempDir()}
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngBytes)
+
+	redacted, blocks := x.Extract(map[string]interface{}{"caption": "a cat", "image": dataURL})
+	if len(blocks) != 1 || blocks[0].Type != "image" || blocks[0].MimeType != "image/png" {
+		t.Fatalf("Expected one image block, got %+v", blocks)
+	}
+	out := redacted.(map[string]interface{})
+	if out["caption"] != "a cat" || strings.HasPrefix(out["image"].(string), "data:") {
+		t.Errorf("Expected the payload replaced by a note, got %v", out)
+	}
+}
+
+func TestExtractHintedBase64(t *testing.T) {
+	x := &Extractor{Dir: t.TempDir()}
+	audio := bytes.Repeat([]byte("ID3"), 40)
+	output := map[string]interface{}{
+		"result": map[string]interface{}{
+			"mime_type": "audio/mpeg",
+			"data":      base64.StdEncoding.EncodeToString(audio),
+		},
+	}
+
+	_, blocks := x.Extract(output)
+	if len(blocks) != 1 || blocks[0].Type != "audio" || blocks[0].MimeType != "audio/mpeg" {
+		t.Fatalf("Expected one audio block, got %+v", blocks)
+	}
+}
+
+func TestExtractSniffsDocuments(t *testing.T) {
+	x := &Extractor{Dir: t.TempDir()}
+	pdf := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("x"), 80)...)
+
+	_, blocks := x.Extract(map[string]interface{}{"file": base64.StdEncoding.EncodeToString(pdf)})
+	if len(blocks) != 1 || blocks[0].Type != "resource" || blocks[0].Resource.MimeType != "application/pdf" {
+		t.Fatalf("Expected an embedded PDF resource, got %+v", blocks)
+	}
+	if blocks[0].Resource.Blob == "" {
+		t.Error("Expected the PDF embedded as a blob")
+	}
+}
+
+func TestExtractLinksMediaURLs(t *testing.T) {
+	x := &Extractor{Dir: t.TempDir()}
+	output := map[string]interface{}{
+		"image_url": "https://cdn.example.com/out/render.png",
+		"source":    "https://example.com/article",
+		"page_url":  "https://example.com/about",
+	}
+
+	redacted, blocks := x.Extract(output)
+	if len(blocks) != 1 || blocks[0].Type != "resource_link" || blocks[0].Name != "render.png" {
+		t.Fatalf("Expected one resource_link for the image, got %+v", blocks)
+	}
+	if redacted.(map[string]interface{})["image_url"] != output["image_url"] {
+		t.Error("Expected links to stay in the output")
+	}
+}
+
+func TestExtractSavesLargePayloads(t *testing.T) {
+	dir := t.TempDir()
+	x := &Extractor{Dir: dir, MaxInline: 16}
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngBytes)
+
+	note, blocks := x.Extract(dataURL)
+	if len(blocks) != 1 || blocks[0].Type != "resource_link" || !strings.HasPrefix(blocks[0].URI, "file://") {
+		t.Fatalf("Expected a file resource_link, got %+v", blocks)
+	}
+	if !strings.Contains(note.(string), dir) {
+		t.Errorf("Expected the note to name the saved file, got %q", note)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".png") {
+		t.Errorf("Expected one saved .png, got %v", entries)
+	}
+}
+
+func TestExtractLeavesPlainOutputAlone(t *testing.T) {
+	x := &Extractor{Dir: t.TempDir()}
+	output := map[string]interface{}{
+		"content": "The quick brown fox jumps over the lazy dog, again and again and again.",
+		"id":      "QUJDREVGR0hJSktMTU5PUFFSU1RVVldYWVphYmNkZWZnaGlqa2xtbm9wcXJzdHV2d3h5eg==",
+	}
+	if _, blocks := x.Extract(output); len(blocks) != 0 {
+		t.Errorf("Expected no blocks, got %+v", blocks)
+	}
+
+	text := `{"answer": 42}`
+	if got, blocks := x.ExtractText(text); got != text || blocks != nil {
+		t.Errorf("Expected JSON text unchanged, got %q %+v", got, blocks)
+	}
+
+	var nilExtractor *Extractor
+	if got, blocks := nilExtractor.Extract("anything"); got != "anything" || blocks != nil {
+		t.Errorf("Expected a nil extractor to do nothing, got %v %+v", got, blocks)
+	}
+}
+
+func TestTextBlockAlwaysHasText(t *testing.T) {
+	data, _ := json.Marshal(Text(""))
+	if string(data) != `{"type":"text","text":""}` {
+		t.Errorf("Unexpected text block JSON %s", data)
+	}
+}
@@ -1,3 +1,5 @@
+// Package detector finds the MCP clients (AI tools and IDEs) installed on this
+// machine and the config file each reads its MCP servers from.
 package detector
 
 import (
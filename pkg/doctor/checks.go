@@ -0,0 +1,340 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+)
+
+// Timeouts for the network checks; doctor should answer quickly even offline
+const (
+	reachTimeout = 10 * time.Second
+	apiTimeout   = 30 * time.Second
+)
+
+// maxDetails caps the lines listed under one check
+const maxDetails = 20
+
+// checkConfig reports which config.json was used and the settings in effect
+func checkConfig(r *Report, opts Options) {
+	var details []string
+	for _, s := range opts.Settings {
+		value := s.Value
+		if strings.HasSuffix(s.Name, "key") {
+			value = Mask(value)
+		}
+		details = append(details, fmt.Sprintf("%s = %s (from %s)", s.Name, value, s.Source))
+	}
+
+	switch {
+	case opts.ConfigErr != nil:
+		r.add("Config", StatusFail, fmt.Sprintf("%s: %v", opts.ConfigPath, opts.ConfigErr), details...)
+	case opts.ConfigPath == "":
+		r.add("Config", StatusOK, "no config.json used", details...)
+	case !exists(opts.ConfigPath):
+		r.add("Config", StatusOK, fmt.Sprintf("no config.json at %s, using environment variables", opts.ConfigPath), details...)
+	default:
+		r.add("Config", StatusOK, "loaded "+opts.ConfigPath, details...)
+	}
+}
+
+// checkKeys looks for the usual mistakes in pasted keys
+// Returns false when the keys can't work.
+func checkKeys(r *Report, apiKey, budgetKey string) bool {
+	var problems, warnings []string
+	for _, k := range []struct{ name, value string }{{"api_key", apiKey}, {"budget_key", budgetKey}} {
+		lower := strings.ToLower(k.value)
+		switch {
+		case k.value == "":
+			problems = append(problems, k.name+" is missing")
+		case strings.ContainsAny(k.value, " \t\r\n\"'"):
+			problems = append(problems, k.name+" contains spaces or quotes (pasted with extra characters?)")
+		case strings.HasPrefix(lower, "your-") || strings.HasPrefix(lower, "your_") ||
+			strings.HasPrefix(k.value, "<") || strings.Contains(lower, "xxxx"):
+			problems = append(problems, k.name+" looks like a placeholder, not a real key")
+		case len(k.value) < 16:
+			warnings = append(warnings, fmt.Sprintf("%s is unusually short (%d characters)", k.name, len(k.value)))
+		}
+	}
+	if apiKey != "" && apiKey == budgetKey {
+		warnings = append(warnings, "api_key and budget_key are the same; the budget key comes from the budget's page")
+	}
+
+	switch {
+	case len(problems) > 0:
+		r.add("Keys", StatusFail, strings.Join(problems, "; "), warnings...)
+		return false
+	case len(warnings) > 0:
+		r.add("Keys", StatusWarn, strings.Join(warnings, "; "))
+	default:
+		r.add("Keys", StatusOK, fmt.Sprintf("api_key %s, budget_key %s", Mask(apiKey), Mask(budgetKey)))
+	}
+	return true
+}
+
+// checkReachable connects to the API URL; any HTTP answer counts
+func checkReachable(ctx context.Context, r *Report, opts Options) bool {
+	u, err := url.Parse(opts.APIURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		r.add("API", StatusFail, fmt.Sprintf("%q is not a valid API URL (expected https://host)", opts.APIURL))
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reachTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.APIURL, nil)
+	if err != nil {
+		r.add("API", StatusFail, err.Error())
+		return false
+	}
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: opts.Transport}).Do(req)
+	if err != nil {
+		r.add("API", StatusFail, fmt.Sprintf("%s is unreachable: %v", opts.APIURL, err),
+			"Check network access, proxy settings and the profile's TLS settings")
+		return false
+	}
+	resp.Body.Close()
+
+	message := fmt.Sprintf("%s answered in %s", opts.APIURL, time.Since(start).Round(time.Millisecond))
+	host := u.Hostname()
+	if u.Scheme == "http" && host != "localhost" && host != "127.0.0.1" && host != "::1" {
+		r.add("API", StatusWarn, message+", but over plain http; keys are sent unencrypted")
+		return true
+	}
+	r.add("API", StatusOK, message)
+	return true
+}
+
+// newClient creates an API client that fails fast and logs nothing
+func newClient(opts Options) *agentpmt.Client {
+	clientOpts := []agentpmt.Option{
+		agentpmt.WithBaseURL(opts.APIURL),
+		agentpmt.WithTimeout(apiTimeout),
+		agentpmt.WithRetry(agentpmt.RetryPolicy{MaxAttempts: 1}),
+		agentpmt.WithLogger(log.New(io.Discard, "", 0)),
+	}
+	if opts.Transport != nil {
+		clientOpts = append(clientOpts, agentpmt.WithTransport(opts.Transport))
+	}
+	return agentpmt.New(opts.APIKey, opts.BudgetKey, clientOpts...)
+}
+
+// checkAuth fetches one catalog entry to see whether the keys are accepted
+func checkAuth(ctx context.Context, r *Report, client *agentpmt.Client) bool {
+	page, err := client.ListTools(ctx, 1, 1)
+	if errors.Is(err, agentpmt.ErrUnauthorized) {
+		r.add("API key check", StatusFail, fmt.Sprintf("%s rejected the keys: %v", agentpmt.FetchEndpoint, err))
+		return false
+	}
+	if err != nil {
+		r.add("API key check", StatusFail, fmt.Sprintf("%s failed: %v", agentpmt.FetchEndpoint, err))
+		return false
+	}
+	if !page.Success {
+		r.add("API key check", StatusFail, fmt.Sprintf("%s refused the request: %s", agentpmt.FetchEndpoint, page.Error))
+		return false
+	}
+	r.add("API key check", StatusOK, "keys accepted by "+agentpmt.FetchEndpoint)
+	return true
+}
+
+// checkCatalog fetches the whole catalog and, given a sanitizer, checks each
+// tool's input schema
+func checkCatalog(ctx context.Context, r *Report, client *agentpmt.Client, schemaWarnings func(json.RawMessage) []string) {
+	catalog, err := client.FetchCatalog(ctx, agentpmt.DefaultPageSize)
+	if err != nil {
+		r.add("Catalog", StatusFail, fmt.Sprintf("failed to fetch the catalog: %v", err))
+		return
+	}
+
+	message := fmt.Sprintf("%d tools in %d page(s)", len(catalog.Tools), catalog.Pages)
+	switch {
+	case len(catalog.Tools) == 0:
+		r.add("Catalog", StatusWarn, "the catalog is empty, so no tools will show up; check which tools the budget allows")
+	case catalog.Truncated && catalog.TotalTools > len(catalog.Tools):
+		r.add("Catalog", StatusWarn, fmt.Sprintf("%s; paging stopped at the page limit, so %d of the %d tools the API reports are missing",
+			message, catalog.TotalTools-len(catalog.Tools), catalog.TotalTools))
+	case catalog.Truncated:
+		r.add("Catalog", StatusWarn, fmt.Sprintf("%s; paging stopped at the page limit, so some tools may be missing", message))
+	case catalog.TotalTools > len(catalog.Tools):
+		r.add("Catalog", StatusWarn, fmt.Sprintf("%s, but the API reports %d", message, catalog.TotalTools))
+	default:
+		r.add("Catalog", StatusOK, message)
+	}
+
+	if schemaWarnings == nil {
+		r.add("Schemas", StatusSkip, "not checked here; agent-payment-server doctor checks them")
+		return
+	}
+	var details []string
+	changed := 0
+	for _, tool := range catalog.Tools {
+		warnings := schemaWarnings(tool.Function.Parameters)
+		if len(warnings) > 0 {
+			changed++
+		}
+		for _, w := range warnings {
+			details = append(details, tool.Function.Name+": "+w)
+		}
+	}
+	if changed == 0 {
+		r.add("Schemas", StatusOK, fmt.Sprintf("all %d input schemas are used as sent", len(catalog.Tools)))
+		return
+	}
+	r.add("Schemas", StatusWarn, fmt.Sprintf("sanitizing changes %d of %d input schemas", changed, len(catalog.Tools)),
+		capDetails(details)...)
+}
+
+// checkClients checks every detected MCP client's config for the server entry
+func checkClients(r *Report, opts Options) {
+	name := opts.ServerName
+	if name == "" {
+		name = DefaultServerName
+	}
+
+	detected := 0
+	for _, client := range opts.Clients {
+		if !client.Detected {
+			continue
+		}
+		detected++
+		checkClient(r, "Client "+client.Name, client.ConfigPath, name, opts.Binary)
+	}
+	if detected == 0 {
+		r.add("Clients", StatusWarn, "no MCP clients detected on this machine")
+	}
+}
+
+// checkClient checks that one client config runs an existing, executable binary
+func checkClient(r *Report, check, configPath, serverName, binary string) {
+	if !filepath.IsAbs(configPath) {
+		r.add(check, StatusSkip, "configured in the application's settings, which can't be checked from here")
+		return
+	}
+
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.add(check, StatusWarn, fmt.Sprintf("%s doesn't exist, so %s isn't set up", configPath, serverName))
+		return
+	}
+	if err != nil {
+		r.add(check, StatusFail, fmt.Sprintf("can't read %s: %v", configPath, err))
+		return
+	}
+
+	command, err := findCommand(data, serverName)
+	if err != nil {
+		r.add(check, StatusWarn, fmt.Sprintf("%s: %v", configPath, err))
+		return
+	}
+
+	path, err := checkExecutable(command)
+	if err != nil {
+		r.add(check, StatusFail, fmt.Sprintf("%s runs %s, which %v", configPath, command, err))
+		return
+	}
+	if binary != "" && !sameFile(path, binary) {
+		r.add(check, StatusWarn, fmt.Sprintf("runs %s, not %s", path, binary), "config: "+configPath)
+		return
+	}
+	r.add(check, StatusOK, "runs "+path, "config: "+configPath)
+}
+
+// findCommand returns the command of the named server in a client config
+// Clients keep servers under "mcpServers", "servers" (VS Code) or
+// "context_servers" (Zed, where the command may be {"path": ...}).
+func findCommand(data []byte, serverName string) (string, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("not valid JSON: %v", err)
+	}
+
+	for _, section := range []string{"mcpServers", "servers", "context_servers"} {
+		var servers map[string]struct {
+			Command json.RawMessage `json:"command"`
+		}
+		if json.Unmarshal(config[section], &servers) != nil {
+			continue
+		}
+		entry, ok := servers[serverName]
+		if !ok {
+			continue
+		}
+
+		var command string
+		if json.Unmarshal(entry.Command, &command) == nil && command != "" {
+			return command, nil
+		}
+		var zed struct {
+			Path string `json:"path"`
+		}
+		if json.Unmarshal(entry.Command, &zed) == nil && zed.Path != "" {
+			return zed.Path, nil
+		}
+		return "", fmt.Errorf("the %q server has no command", serverName)
+	}
+	return "", fmt.Errorf("no %q server configured", serverName)
+}
+
+// checkExecutable resolves command like the client would and checks it can run
+func checkExecutable(command string) (string, error) {
+	if !filepath.IsAbs(command) {
+		path, err := exec.LookPath(command)
+		if err != nil {
+			return "", fmt.Errorf("isn't on the PATH")
+		}
+		return path, nil
+	}
+
+	info, err := os.Stat(command)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "", fmt.Errorf("doesn't exist")
+	case err != nil:
+		return "", fmt.Errorf("can't be read: %v", err)
+	case info.IsDir():
+		return "", fmt.Errorf("is a directory")
+	case runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0:
+		return "", fmt.Errorf("isn't executable (chmod +x it)")
+	}
+	return command, nil
+}
+
+// sameFile reports whether two paths name the same file
+func sameFile(a, b string) bool {
+	ai, errA := os.Stat(a)
+	bi, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return os.SameFile(ai, bi)
+}
+
+// capDetails keeps a long list readable
+func capDetails(details []string) []string {
+	if len(details) <= maxDetails {
+		return details
+	}
+	return append(details[:maxDetails:maxDetails], fmt.Sprintf("... and %d more", len(details)-maxDetails))
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,175 @@
+// Package doctor diagnoses an AgentPMT MCP setup end to end: configuration,
+// keys, API access, the tool catalog and the MCP clients pointing at the
+// server. agent-payment-server and the installer both run it, each filling
+// in Options from what it knows, so teammates get the same report whichever
+// they have at hand.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/detector"
+)
+
+// Status is the outcome of one check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn" // Works, but may not do what the user expects
+	StatusFail Status = "fail" // Stops the tools from working
+	StatusSkip Status = "skip" // Not checked, usually because an earlier check failed
+)
+
+// Check is one line of the report
+type Check struct {
+	Name    string   `json:"name"`
+	Status  Status   `json:"status"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+}
+
+// Report is the result of Run, in the order the checks ran
+type Report struct {
+	OK     bool    `json:"ok"` // No check failed
+	Checks []Check `json:"checks"`
+}
+
+// Setting is one effective setting and where its value came from
+type Setting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"` // e.g. "config.json", "AGENT_PAYMENT_API_KEY", "--profile", "default"
+}
+
+// Options describe the setup to check
+type Options struct {
+	// ConfigPath is the config.json the caller read ("" if it looks for none)
+	ConfigPath string
+
+	// ConfigErr is what is wrong with the configuration, e.g. why ConfigPath
+	// couldn't be loaded (nil if nothing is)
+	ConfigErr error
+
+	// Settings in effect after config.json, profiles, environment variables and
+	// flags, shown in the report (key values are masked)
+	Settings []Setting
+
+	// API environment to check
+	APIURL    string
+	APIKey    string
+	BudgetKey string
+
+	// Transport is used for API calls (nil = http.DefaultTransport), e.g. for a
+	// profile's TLS settings
+	Transport http.RoundTripper
+
+	// SchemaWarnings reports what sanitizing changes in a tool's input schema
+	// (nil skips the check)
+	SchemaWarnings func(params json.RawMessage) []string
+
+	// Clients are the MCP clients to check, usually detector.New().DetectAll()
+	Clients []detector.Tool
+
+	// ServerName is the server's entry in client configs (default "agent-payment")
+	ServerName string
+
+	// Binary is the server binary clients are expected to run ("" = any)
+	Binary string
+}
+
+// DefaultServerName is the entry the installer writes into client configs
+const DefaultServerName = "agent-payment"
+
+// Run performs every check and returns the report
+// Checks that need the API are skipped once the API proves unusable.
+func Run(ctx context.Context, opts Options) *Report {
+	r := &Report{OK: true}
+	checkConfig(r, opts)
+	keysOK := checkKeys(r, opts.APIKey, opts.BudgetKey)
+	if checkReachable(ctx, r, opts) && keysOK {
+		if client := newClient(opts); checkAuth(ctx, r, client) {
+			checkCatalog(ctx, r, client, opts.SchemaWarnings)
+		} else {
+			r.add("Catalog", StatusSkip, "needs working keys")
+		}
+	} else {
+		r.add("API key check", StatusSkip, "needs a reachable API and keys")
+		r.add("Catalog", StatusSkip, "needs a reachable API and keys")
+	}
+	checkClients(r, opts)
+	return r
+}
+
+// add appends a check, marking the report failed on StatusFail
+func (r *Report) add(name string, status Status, message string, details ...string) {
+	if status == StatusFail {
+		r.OK = false
+	}
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Message: message, Details: details})
+}
+
+// Counts returns how many checks failed and how many warned
+func (r *Report) Counts() (failed, warned int) {
+	for _, c := range r.Checks {
+		switch c.Status {
+		case StatusFail:
+			failed++
+		case StatusWarn:
+			warned++
+		}
+	}
+	return failed, warned
+}
+
+// labels are the fixed-width status tags of the text report
+var labels = map[Status]string{
+	StatusOK:   "[ OK ]",
+	StatusWarn: "[WARN]",
+	StatusFail: "[FAIL]",
+	StatusSkip: "[SKIP]",
+}
+
+// WriteText writes the report for people to read
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, c := range r.Checks {
+		fmt.Fprintf(&b, "%s %s: %s\n", labels[c.Status], c.Name, c.Message)
+		for _, d := range c.Details {
+			fmt.Fprintf(&b, "       %s\n", d)
+		}
+	}
+
+	failed, warned := r.Counts()
+	switch {
+	case failed > 0:
+		fmt.Fprintf(&b, "\n%d problem(s) and %d warning(s) found.\n", failed, warned)
+	case warned > 0:
+		fmt.Fprintf(&b, "\nNo problems, %d warning(s).\n", warned)
+	default:
+		b.WriteString("\nEverything looks good.\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Mask hides all but the ends of a key, for reports and logs
+func Mask(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + strings.Repeat("*", len(key)-8) + key[len(key)-4:]
+}
@@ -0,0 +1,173 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/detector"
+)
+
+const (
+	testAPIKey    = "test-api-key-0123456789"
+	testBudgetKey = "test-budget-key-0123456789"
+)
+
+// apiServer serves a two-tool catalog to the test keys and 401s anyone else
+func apiServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/products/fetch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-API-Key") != testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Invalid API key"}`))
+			return
+		}
+		fmt.Fprint(w, `{"success":true,"details":{"page_returned":1,"total_tools":2,"has_next_page":false},"tools":[
+			{"type":"function","function":{"name":"prod-1","description":"One — first","parameters":{"type":"object"}}},
+			{"type":"function","function":{"name":"prod-2","description":"Two — second","parameters":{"type":"object","properties":{"x":{"type":"string","required":true}}}}}
+		]}`)
+	}))
+}
+
+// statuses maps check names to their status
+func statuses(r *Report) map[string]Status {
+	got := make(map[string]Status)
+	for _, c := range r.Checks {
+		got[c.Name] = c.Status
+	}
+	return got
+}
+
+func TestRunChecksEverything(t *testing.T) {
+	server := apiServer()
+	defer server.Close()
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "agent-payment-server")
+	os.WriteFile(binary, []byte("#!/bin/sh\n"), 0755)
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(fmt.Sprintf(`{"mcpServers":{"agent-payment":{"command":%q}}}`, binary)), 0644)
+	broken := filepath.Join(dir, "broken.json")
+	os.WriteFile(broken, []byte(`{"servers":{"agent-payment":{"command":"/nowhere/agent-payment-server"}}}`), 0644)
+
+	report := Run(context.Background(), Options{
+		Settings:  []Setting{{Name: "api_key", Value: testAPIKey, Source: "config.json"}},
+		APIURL:    server.URL,
+		APIKey:    testAPIKey,
+		BudgetKey: testBudgetKey,
+		SchemaWarnings: func(params json.RawMessage) []string {
+			if strings.Contains(string(params), `"required":true`) {
+				return []string{"per-property required moved"}
+			}
+			return nil
+		},
+		Clients: []detector.Tool{
+			{Name: "Good", Detected: true, ConfigPath: good},
+			{Name: "Broken", Detected: true, ConfigPath: broken},
+			{Name: "Missing", Detected: true, ConfigPath: filepath.Join(dir, "missing.json")},
+			{Name: "GUI", Detected: true, ConfigPath: "GUI Configuration Required"},
+			{Name: "Absent"},
+		},
+		Binary: binary,
+	})
+
+	want := map[string]Status{
+		"Config":         StatusOK,
+		"Keys":           StatusOK,
+		"API":            StatusOK,
+		"API key check":  StatusOK,
+		"Catalog":        StatusOK,
+		"Schemas":        StatusWarn,
+		"Client Good":    StatusOK,
+		"Client Broken":  StatusFail,
+		"Client Missing": StatusWarn,
+		"Client GUI":     StatusSkip,
+	}
+	got := statuses(report)
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s = %q, want %q", name, got[name], status)
+		}
+	}
+	if len(got) != len(want) || report.OK {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	for _, s := range []string{"[FAIL] Client Broken", "prod-2: per-property required moved", "(from config.json)", "1 problem(s) and 2 warning(s)"} {
+		if !strings.Contains(text.String(), s) {
+			t.Errorf("Expected %q in the text report:\n%s", s, text.String())
+		}
+	}
+	if strings.Contains(text.String(), testAPIKey) {
+		t.Error("The text report shows the API key unmasked")
+	}
+
+	var decoded Report
+	var out bytes.Buffer
+	report.WriteJSON(&out)
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded.Checks) != len(report.Checks) {
+		t.Errorf("WriteJSON() = %s, %v", out.String(), err)
+	}
+}
+
+func TestRunStopsAtBadKeys(t *testing.T) {
+	server := apiServer()
+	defer server.Close()
+
+	report := Run(context.Background(), Options{APIURL: server.URL, APIKey: "your-api-key", BudgetKey: testBudgetKey})
+	got := statuses(report)
+	if got["Keys"] != StatusFail || got["API key check"] != StatusSkip || got["Catalog"] != StatusSkip {
+		t.Errorf("Expected placeholder keys to fail and skip the API checks, got %v", got)
+	}
+
+	report = Run(context.Background(), Options{APIURL: server.URL, APIKey: "wrong-api-key-0123456789", BudgetKey: testBudgetKey})
+	got = statuses(report)
+	if got["Keys"] != StatusOK || got["API key check"] != StatusFail || got["Catalog"] != StatusSkip {
+		t.Errorf("Expected rejected keys to fail the key check, got %v", got)
+	}
+
+	report = Run(context.Background(), Options{APIURL: "api.agentpmt.com", APIKey: testAPIKey, BudgetKey: testBudgetKey})
+	if got := statuses(report); got["API"] != StatusFail || report.OK {
+		t.Errorf("Expected a URL without scheme to fail, got %v", got)
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	tests := []struct {
+		config  string
+		command string
+	}{
+		{`{"mcpServers":{"agent-payment":{"command":"/bin/a"}}}`, "/bin/a"},
+		{`{"servers":{"agent-payment":{"command":"/bin/b"}}}`, "/bin/b"},
+		{`{"context_servers":{"agent-payment":{"command":{"path":"/bin/c"}}}}`, "/bin/c"},
+		{`{"mcpServers":{"other":{"command":"/bin/d"}}}`, ""},
+		{`// comment`, ""},
+	}
+	for _, tt := range tests {
+		command, err := findCommand([]byte(tt.config), DefaultServerName)
+		if command != tt.command || (tt.command == "") != (err != nil) {
+			t.Errorf("findCommand(%s) = %q, %v", tt.config, command, err)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	if got := Mask("abcdefghijkl"); got != "abcd****ijkl" {
+		t.Errorf("Mask() = %q", got)
+	}
+	if got := Mask("short"); got != "*****" {
+		t.Errorf("Mask() = %q", got)
+	}
+}
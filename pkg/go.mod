@@ -0,0 +1,5 @@
+module github.com/Apoth3osis-ai/agent-payment-mcp/pkg
+
+go 1.23
+
+require github.com/tmaxmax/go-sse v0.11.0
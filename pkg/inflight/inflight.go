@@ -0,0 +1,203 @@
+// Package inflight tracks the requests an MCP session is handling so that
+// notifications/cancelled can abort them, and reports whether a cancelled
+// purchase may already have been charged.
+package inflight
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+)
+
+// Phase records how far an in-flight request got before it finished or was cancelled
+type Phase int32
+
+const (
+	Pending   Phase = iota // Not yet sent upstream: nothing can have been charged
+	Sent                   // Purchase request written to the API: outcome unknown
+	Committed              // API confirmed the purchase: the charge stands
+)
+
+// String describes the phase for cancellation reports
+func (p Phase) String() string {
+	switch p {
+	case Pending:
+		return "before-purchase"
+	case Sent:
+		return "purchase-in-flight"
+	case Committed:
+		return "after-commit"
+	default:
+		return "unknown"
+	}
+}
+
+// Call tracks one request that is being handled
+type Call struct {
+	ID     interface{} // JSON-RPC id
+	Method string
+
+	tool      atomic.Value // string: tool name, set once the purchase starts
+	cancel    context.CancelFunc
+	phase     atomic.Int32
+	cancelled atomic.Bool
+}
+
+// SetPhase advances the call's phase (never moves backwards)
+func (c *Call) SetPhase(p Phase) {
+	for {
+		cur := c.phase.Load()
+		if cur >= int32(p) || c.phase.CompareAndSwap(cur, int32(p)) {
+			return
+		}
+	}
+}
+
+// Phase returns the call's current phase
+func (c *Call) Phase() Phase {
+	return Phase(c.phase.Load())
+}
+
+// Cancelled reports whether the client cancelled the call
+// A cancelled call must get no response.
+func (c *Call) Cancelled() bool {
+	return c.cancelled.Load()
+}
+
+// Tool returns the tool being purchased ("" before the purchase starts)
+func (c *Call) Tool() string {
+	tool, _ := c.tool.Load().(string)
+	return tool
+}
+
+// Registry tracks a session's in-flight requests by JSON-RPC id
+type Registry struct {
+	mu    sync.Mutex
+	calls map[string]*Call
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{calls: make(map[string]*Call)}
+}
+
+// key normalizes a JSON-RPC id (string or number) for map lookups
+func key(id interface{}) string {
+	return fmt.Sprintf("%T:%v", id, id)
+}
+
+// Start registers a request and returns a context that is cancelled when the
+// client sends notifications/cancelled for it. done must be called when the
+// request finishes.
+//
+// Transports that read requests in order must call Start before reading the
+// next message, or a cancellation that follows right behind its request finds
+// nothing to cancel.
+func (r *Registry) Start(parent context.Context, id interface{}, method string) (context.Context, *Call, func()) {
+	ctx, cancel := context.WithCancel(parent)
+	call := &Call{ID: id, Method: method, cancel: cancel}
+
+	k := key(id)
+	r.mu.Lock()
+	r.calls[k] = call
+	r.mu.Unlock()
+
+	done := func() {
+		r.mu.Lock()
+		if r.calls[k] == call {
+			delete(r.calls, k)
+		}
+		r.mu.Unlock()
+		cancel()
+	}
+
+	return context.WithValue(ctx, callKey{}, call), call, done
+}
+
+// Cancel aborts the request with the given id; returns nil if it is not in flight
+func (r *Registry) Cancel(id interface{}) *Call {
+	r.mu.Lock()
+	call, exists := r.calls[key(id)]
+	r.mu.Unlock()
+
+	if !exists {
+		return nil
+	}
+	call.cancelled.Store(true)
+	call.cancel()
+	return call
+}
+
+// Abort handles notifications/cancelled: it cancels the request and logs
+// whether the purchase behind it may have been charged
+func (r *Registry) Abort(id interface{}, reason string) {
+	call := r.Cancel(id)
+	if call == nil {
+		// Already finished (or never existed); nothing to abort
+		log.Printf("Cancellation for request %v ignored: not in flight", id)
+		return
+	}
+	Report(call, reason)
+}
+
+// Len returns the number of requests in flight
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+// callKey is the context key for the current request's Call
+type callKey struct{}
+
+// FromContext returns the Call for ctx, if any
+func FromContext(ctx context.Context) *Call {
+	call, _ := ctx.Value(callKey{}).(*Call)
+	return call
+}
+
+// TracePurchase returns a context that marks the call as sent once the purchase
+// request has been fully written to the API, so a later cancellation can tell
+// whether a charge may have happened.
+func TracePurchase(ctx context.Context, tool string) context.Context {
+	call := FromContext(ctx)
+	if call == nil {
+		return ctx
+	}
+	call.tool.Store(tool)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				call.SetPhase(Sent)
+			}
+		},
+	})
+}
+
+// MarkCommitted records that the API confirmed the purchase for ctx's call
+func MarkCommitted(ctx context.Context) {
+	if call := FromContext(ctx); call != nil {
+		call.SetPhase(Committed)
+	}
+}
+
+// Report logs a line finance can reconcile against charges
+func Report(call *Call, reason string) {
+	phase := call.Phase()
+
+	var charge string
+	switch phase {
+	case Pending:
+		charge = "no charge: purchase was not sent"
+	case Sent:
+		charge = "charge unknown: purchase was already sent to the API, check purchase history"
+	case Committed:
+		charge = "charged: purchase was committed before the cancellation"
+	}
+
+	log.Printf("CANCELLED request=%v method=%s tool=%q phase=%s reason=%q (%s)",
+		call.ID, call.Method, call.Tool(), phase, reason, charge)
+}
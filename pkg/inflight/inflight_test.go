@@ -0,0 +1,83 @@
+package inflight
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCancelAbortsRequest(t *testing.T) {
+	registry := NewRegistry()
+	ctx, call, done := registry.Start(context.Background(), float64(7), "tools/call")
+	defer done()
+
+	if FromContext(ctx) != call {
+		t.Fatal("Expected the call to be reachable from its context")
+	}
+	if registry.Cancel(float64(7)) != call {
+		t.Fatal("Expected Cancel to return the in-flight call")
+	}
+
+	select {
+	case <-ctx.Done():
+	default:
+		t.Fatal("Expected request context to be cancelled")
+	}
+	if !call.Cancelled() {
+		t.Error("Expected call to be marked cancelled")
+	}
+	if call.Phase() != Pending {
+		t.Errorf("Expected phase %s, got %s", Pending, call.Phase())
+	}
+}
+
+func TestCancelUnknownRequestIsIgnored(t *testing.T) {
+	registry := NewRegistry()
+	if call := registry.Cancel("missing"); call != nil {
+		t.Error("Expected nil for a request that is not in flight")
+	}
+
+	// String and numeric ids are distinct
+	_, _, done := registry.Start(context.Background(), "7", "ping")
+	defer done()
+	if call := registry.Cancel(float64(7)); call != nil {
+		t.Error("Expected a numeric id not to match a string id")
+	}
+}
+
+func TestDoneUnregisters(t *testing.T) {
+	registry := NewRegistry()
+	_, _, done := registry.Start(context.Background(), "abc", "ping")
+	if registry.Len() != 1 {
+		t.Fatalf("Expected 1 call in flight, got %d", registry.Len())
+	}
+	done()
+	if registry.Len() != 0 || registry.Cancel("abc") != nil {
+		t.Error("Expected the call to be gone once done")
+	}
+}
+
+func TestPhaseNeverMovesBackwards(t *testing.T) {
+	call := &Call{}
+	call.SetPhase(Committed)
+	call.SetPhase(Sent)
+	if call.Phase() != Committed {
+		t.Errorf("Expected phase to stay %s, got %s", Committed, call.Phase())
+	}
+}
+
+func TestTracePurchaseRecordsTool(t *testing.T) {
+	ctx, call, done := NewRegistry().Start(context.Background(), 1, "tools/call")
+	defer done()
+
+	TracePurchase(ctx, "weather")
+	MarkCommitted(ctx)
+	if call.Tool() != "weather" || call.Phase() != Committed {
+		t.Errorf("Expected weather committed, got %q %s", call.Tool(), call.Phase())
+	}
+
+	// Without a tracked call both are no-ops
+	if TracePurchase(context.Background(), "weather") != context.Background() {
+		t.Error("Expected the context back unchanged")
+	}
+	MarkCommitted(context.Background())
+}
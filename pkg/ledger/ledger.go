@@ -0,0 +1,477 @@
+package ledger
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FileName is the ledger file name inside the install directory
+const FileName = "spend-ledger.jsonl"
+
+// Limits are local spending caps checked before every purchase (0 = no cap)
+type Limits struct {
+	Session float64            `json:"session,omitempty"`  // Per MCP session
+	Hour    float64            `json:"hour,omitempty"`     // Rolling 60 minutes
+	Day     float64            `json:"day,omitempty"`      // Rolling 24 hours
+	PerTool map[string]float64 `json:"per_tool,omitempty"` // Per tool name, rolling 24 hours
+
+	// DefaultCost is the expected cost of a tool without a known price, held
+	// against the caps while it runs and recorded when the API reports no
+	// cost, so caps still stop a runaway loop
+	DefaultCost float64 `json:"default_cost,omitempty"`
+}
+
+// Enabled reports whether any cap is configured
+func (l Limits) Enabled() bool {
+	return l.Session > 0 || l.Hour > 0 || l.Day > 0 || len(l.PerTool) > 0
+}
+
+// Entry is one recorded purchase
+type Entry struct {
+	ID        string    `json:"id,omitempty"` // Assigned by Record
+	Time      time.Time `json:"time"`
+	Tool      string    `json:"tool"`
+	ProductID string    `json:"product_id"`
+	Amount    float64   `json:"amount"`
+	Estimated bool      `json:"estimated,omitempty"` // Amount is the expected cost, not reported by the API
+	Client    string    `json:"client,omitempty"`    // MCP clientInfo name
+	Session   string    `json:"session,omitempty"`
+
+	// Unconfirmed purchases failed after reaching the API (a timeout, a
+	// cancellation, a tool error), so they may or may not have been charged
+	Unconfirmed bool `json:"unconfirmed,omitempty"`
+
+	// Receipt as reported by the API
+	Result  string          `json:"result,omitempty"`  // purchase_result
+	Details json.RawMessage `json:"details,omitempty"` // purchase_details
+}
+
+// balanceKeys are the purchase_details keys the API reports the remaining budget under
+var balanceKeys = []string{"balance", "remaining_balance", "budget_remaining", "remaining_budget"}
+
+// Balance returns the remaining budget reported with the purchase, if any
+func (e Entry) Balance() (float64, bool) {
+	var details map[string]interface{}
+	if len(e.Details) == 0 || json.Unmarshal(e.Details, &details) != nil {
+		return 0, false
+	}
+	for _, key := range balanceKeys {
+		switch v := details[key].(type) {
+		case float64:
+			return v, true
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// CapError reports which cap refused a purchase
+type CapError struct {
+	Cap   string // "session", "hourly", "daily" or "tool <name>"
+	Limit float64
+	Spent float64
+}
+
+func (e *CapError) Error() string {
+	return fmt.Sprintf("local %s spend cap reached: spent %.4f of %.4f", e.Cap, e.Spent, e.Limit)
+}
+
+// Ledger records purchases and enforces Limits
+type Ledger struct {
+	mu       sync.Mutex
+	limits   Limits
+	path     string  // Append-only JSONL file ("" keeps the ledger in memory)
+	entries  []Entry // Purchases from the last 24 hours
+	reserved map[*Reservation]struct{}
+	started  time.Time
+	now      func() time.Time
+}
+
+// Reservation holds the expected cost of one purchase against the caps from
+// Reserve until the purchase is recorded or released, so concurrent calls
+// can't all pass the same check and together overshoot a cap
+type Reservation struct {
+	l       *Ledger
+	session string
+	tool    string
+	amount  float64
+}
+
+// DefaultPath returns the ledger path next to the executable
+// Falls back to the user config directory when the install directory isn't writable.
+func DefaultPath() string {
+	if exePath, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exePath)
+		if isWritable(dir) {
+			return filepath.Join(dir, FileName)
+		}
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "agentpmt", FileName)
+	}
+	return FileName
+}
+
+// New creates a ledger, loading the last 24 hours from path so hourly and
+// daily caps survive restarts
+func New(limits Limits, path string) (*Ledger, error) {
+	l := &Ledger{limits: limits, path: path, reserved: make(map[*Reservation]struct{}), now: time.Now}
+	l.started = l.now()
+
+	if path == "" {
+		return l, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger: %w", err)
+	}
+	defer f.Close()
+
+	cutoff := l.started.Add(-24 * time.Hour)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			log.Printf("Warning: skipping malformed ledger line: %v", err)
+			continue
+		}
+		if e.Time.After(cutoff) {
+			if e.ID == "" {
+				e.ID = newID() // Written before purchases had IDs
+			}
+			l.entries = append(l.entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+
+	return l, nil
+}
+
+// Limits returns the configured caps
+func (l *Ledger) Limits() Limits {
+	return l.limits
+}
+
+// CapStatus is how much of one cap has been used
+type CapStatus struct {
+	Cap   string  `json:"cap"`
+	Limit float64 `json:"limit"`
+	Spent float64 `json:"spent"`
+}
+
+// Remaining returns what is left under the cap
+func (c CapStatus) Remaining() float64 {
+	return c.Limit - c.Spent
+}
+
+// Status returns every configured cap that applies to a purchase of tool in session
+func (l *Ledger) Status(session, tool string) []CapStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.caps(session, tool)
+}
+
+// Check returns a *CapError if a purchase of tool in session costing cost
+// would exceed a cap, counting purchases still in flight. cost < 0 means the
+// price is unknown; DefaultCost is assumed. Check holds nothing; use Reserve
+// before calling the API.
+func (l *Ledger) Check(session, tool string, cost float64) error {
+	if !l.limits.Enabled() {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.check(session, tool, l.expected(cost))
+}
+
+// Reserve checks a purchase like Check and, if it fits, holds its expected
+// cost against the caps until the returned Reservation is recorded or
+// released. Must be called before the API is called.
+func (l *Ledger) Reserve(session, tool string, cost float64) (*Reservation, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	amount := l.expected(cost)
+	if l.limits.Enabled() {
+		if err := l.check(session, tool, amount); err != nil {
+			return nil, err
+		}
+	}
+	r := &Reservation{l: l, session: session, tool: tool, amount: amount}
+	l.reserved[r] = struct{}{}
+	return r, nil
+}
+
+// Record adds the reserved purchase to the ledger in place of the
+// reservation, like Ledger.Record; amount < 0 records the reserved cost as
+// an estimate. A nil Reservation (no ledger) records nothing.
+func (r *Reservation) Record(e Entry, amount float64) Entry {
+	if r == nil {
+		return e
+	}
+	if amount < 0 {
+		amount = r.amount
+		e.Estimated = true
+	}
+
+	// Swap the hold for the entry in one step so a concurrent Reserve sees one of them
+	r.l.mu.Lock()
+	defer r.l.mu.Unlock()
+	delete(r.l.reserved, r)
+	return r.l.record(e, amount)
+}
+
+// Release drops the reservation of a purchase that wasn't charged
+func (r *Reservation) Release() {
+	if r == nil {
+		return
+	}
+	r.l.mu.Lock()
+	defer r.l.mu.Unlock()
+	delete(r.l.reserved, r)
+}
+
+// expected returns the cost to assume for a purchase (cost < 0 = unknown)
+func (l *Ledger) expected(cost float64) float64 {
+	if cost < 0 {
+		return l.limits.DefaultCost
+	}
+	return cost
+}
+
+// check returns a *CapError if spending amount would exceed a cap; l.mu must be held
+func (l *Ledger) check(session, tool string, amount float64) error {
+	for _, c := range l.caps(session, tool) {
+		if c.Spent >= c.Limit || c.Spent+amount > c.Limit {
+			return &CapError{Cap: c.Cap, Limit: c.Limit, Spent: c.Spent}
+		}
+	}
+	return nil
+}
+
+// caps computes the status of each configured cap; l.mu must be held
+func (l *Ledger) caps(session, tool string) []CapStatus {
+	now := l.now()
+	all := func(Entry) bool { return true }
+
+	var caps []CapStatus
+	add := func(name string, limit float64, since time.Time, match func(Entry) bool) {
+		if limit > 0 {
+			caps = append(caps, CapStatus{Cap: name, Limit: limit, Spent: l.sum(since, match) + l.held(match)})
+		}
+	}
+
+	// Sessions never span restarts, so only count entries recorded by this process
+	add("session", l.limits.Session, l.started, func(e Entry) bool { return e.Session == session })
+	add("hourly", l.limits.Hour, now.Add(-time.Hour), all)
+	add("daily", l.limits.Day, now.Add(-24*time.Hour), all)
+	add("tool "+tool, l.limits.PerTool[tool], now.Add(-24*time.Hour), func(e Entry) bool { return e.Tool == tool })
+	return caps
+}
+
+// Budget returns every configured cap as it applies to session, per-tool caps last
+func (l *Ledger) Budget(session string) []CapStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	caps := l.caps(session, "")
+	tools := make([]string, 0, len(l.limits.PerTool))
+	for tool := range l.limits.PerTool {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	for _, tool := range tools {
+		if limit := l.limits.PerTool[tool]; limit > 0 {
+			caps = append(caps, CapStatus{
+				Cap:   "tool " + tool,
+				Limit: limit,
+				Spent: l.sum(l.now().Add(-24*time.Hour), func(e Entry) bool { return e.Tool == tool }) +
+					l.held(func(e Entry) bool { return e.Tool == tool }),
+			})
+		}
+	}
+	return caps
+}
+
+// Record adds a purchase to the ledger and returns it as recorded
+// amount < 0 means the API didn't report a cost; DefaultCost is recorded instead.
+// Purchases that were reserved are recorded with Reservation.Record.
+func (l *Ledger) Record(e Entry, amount float64) Entry {
+	if amount < 0 {
+		amount = l.limits.DefaultCost
+		e.Estimated = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.record(e, amount)
+}
+
+// record adds a purchase to the ledger and its file; l.mu must be held
+func (l *Ledger) record(e Entry, amount float64) Entry {
+	e.Amount = amount
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if e.Time.IsZero() {
+		e.Time = l.now()
+	}
+	l.entries = append(l.entries, e)
+	l.prune()
+
+	if l.path == "" {
+		return e
+	}
+	if err := l.appendEntry(e); err != nil {
+		log.Printf("Warning: failed to write spend ledger: %v", err)
+	}
+	return e
+}
+
+// Purchases returns up to limit purchases from the last 24 hours, newest first
+func (l *Ledger) Purchases(limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune()
+
+	var recent []Entry
+	for i := len(l.entries) - 1; i >= 0 && len(recent) < limit; i-- {
+		recent = append(recent, l.entries[i])
+	}
+	return recent
+}
+
+// Purchase returns the purchase with the given ID from the last 24 hours
+func (l *Ledger) Purchase(id string) (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if e.ID == id && id != "" {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Balance returns the remaining budget the API last reported, and when
+func (l *Ledger) Balance() (float64, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if balance, ok := l.entries[i].Balance(); ok {
+			return balance, l.entries[i].Time, true
+		}
+	}
+	return 0, time.Time{}, false
+}
+
+// Spent returns the total recorded in the last 24 hours
+func (l *Ledger) Spent() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sum(l.now().Add(-24*time.Hour), func(Entry) bool { return true })
+}
+
+// LastCost returns what the API last reported charging for tool
+func (l *Ledger) LastCost(tool string) (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if e := l.entries[i]; e.Tool == tool && !e.Estimated {
+			return e.Amount, true
+		}
+	}
+	return 0, false
+}
+
+// sum adds up entries after since that match; l.mu must be held
+func (l *Ledger) sum(since time.Time, match func(Entry) bool) float64 {
+	var total float64
+	for _, e := range l.entries {
+		if e.Time.After(since) && match(e) {
+			total += e.Amount
+		}
+	}
+	return total
+}
+
+// held adds up the reservations that match; l.mu must be held
+func (l *Ledger) held(match func(Entry) bool) float64 {
+	var total float64
+	for r := range l.reserved {
+		if match(Entry{Session: r.session, Tool: r.tool}) {
+			total += r.amount
+		}
+	}
+	return total
+}
+
+// prune drops entries older than the longest window; l.mu must be held
+func (l *Ledger) prune() {
+	cutoff := l.now().Add(-24 * time.Hour)
+	i := 0
+	for i < len(l.entries) && !l.entries[i].Time.After(cutoff) {
+		i++
+	}
+	l.entries = l.entries[i:]
+}
+
+// appendEntry writes e to the ledger file; l.mu must be held
+func (l *Ledger) appendEntry(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// newID returns a random purchase ID
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// isWritable reports whether files can be created in dir
+func isWritable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
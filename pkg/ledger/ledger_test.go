@@ -0,0 +1,266 @@
+package ledger
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckEnforcesSessionCap(t *testing.T) {
+	l, _ := New(Limits{Session: 1.00}, "")
+
+	l.Record(Entry{Tool: "alpha", Session: "s1"}, 0.60)
+	if err := l.Check("s1", "alpha", -1); err != nil {
+		t.Fatalf("Expected purchase under the cap to pass, got %v", err)
+	}
+
+	l.Record(Entry{Tool: "alpha", Session: "s1"}, 0.40)
+	err := l.Check("s1", "alpha", -1)
+	var capErr *CapError
+	if !errors.As(err, &capErr) || capErr.Cap != "session" {
+		t.Fatalf("Expected session cap error, got %v", err)
+	}
+
+	// Other sessions have their own allowance
+	if err := l.Check("s2", "alpha", -1); err != nil {
+		t.Errorf("Expected another session to pass, got %v", err)
+	}
+}
+
+func TestCheckEnforcesPerToolCap(t *testing.T) {
+	l, _ := New(Limits{PerTool: map[string]float64{"alpha": 0.05}}, "")
+
+	l.Record(Entry{Tool: "alpha"}, 0.05)
+
+	err := l.Check("s1", "alpha", -1)
+	var capErr *CapError
+	if !errors.As(err, &capErr) || capErr.Cap != "tool alpha" {
+		t.Fatalf("Expected tool cap error, got %v", err)
+	}
+	if err := l.Check("s1", "beta", -1); err != nil {
+		t.Errorf("Expected other tools to pass, got %v", err)
+	}
+}
+
+func TestCheckCountsThePrice(t *testing.T) {
+	l, _ := New(Limits{Day: 1.00}, "")
+	l.Record(Entry{Tool: "alpha"}, 0.70)
+
+	if err := l.Check("s1", "alpha", 0.25); err != nil {
+		t.Errorf("Expected a 0.25 purchase to fit, got %v", err)
+	}
+	if err := l.Check("s1", "alpha", 0.50); err == nil {
+		t.Error("Expected a 0.50 purchase to exceed the daily cap")
+	}
+}
+
+func TestReserveHoldsConcurrentPurchases(t *testing.T) {
+	l, _ := New(Limits{Session: 1.00}, "")
+
+	// Ten calls at 0.30 race for a 1.00 cap: three fit
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var held []*Reservation
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, err := l.Reserve("s1", "alpha", 0.30); err == nil {
+				mu.Lock()
+				held = append(held, r)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(held) != 3 {
+		t.Fatalf("Expected 3 reservations to fit, got %d", len(held))
+	}
+
+	// A released purchase frees its share; a recorded one keeps it
+	held[0].Release()
+	e := held[1].Record(Entry{Tool: "alpha", Session: "s1"}, -1)
+	if e.Amount != 0.30 || !e.Estimated {
+		t.Errorf("Expected the reserved cost recorded as an estimate, got %+v", e)
+	}
+	held[2].Record(Entry{Tool: "alpha", Session: "s1"}, 0.20)
+
+	if spent := l.Status("s1", "alpha")[0].Spent; spent < 0.499 || spent > 0.501 {
+		t.Errorf("Expected 0.50 spent after settling, got %.4f", spent)
+	}
+	if _, err := l.Reserve("s1", "alpha", 0.60); err == nil {
+		t.Error("Expected a 0.60 purchase to exceed the cap")
+	}
+}
+
+func TestRecordingAReservationNeverFreesItsShare(t *testing.T) {
+	l, _ := New(Limits{Session: 1000}, "")
+
+	// Workers reserve and record the same amount, so the spend a concurrent
+	// Reserve sees must only ever grow: a reservation can't be gone before its
+	// entry is there
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				r, err := l.Reserve("s1", "alpha", 0.25)
+				if err != nil {
+					t.Errorf("Reserve() error = %v", err)
+					return
+				}
+				r.Record(Entry{Tool: "alpha", Session: "s1"}, 0.25)
+			}
+		}()
+	}
+
+	monitor := make(chan float64, 1)
+	go func() {
+		last, drop := 0.0, 0.0
+		for {
+			select {
+			case <-stop:
+				monitor <- drop
+				return
+			default:
+			}
+			spent := l.Status("s1", "alpha")[0].Spent
+			if spent < last {
+				drop = last - spent
+			}
+			last = spent
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	if drop := <-monitor; drop > 0 {
+		t.Errorf("Spend dropped by %.2f while a reservation was being recorded", drop)
+	}
+	if spent := l.Status("s1", "alpha")[0].Spent; spent != 400 {
+		t.Errorf("Expected 400.00 spent, got %.2f", spent)
+	}
+}
+
+func TestHourlyCapExpires(t *testing.T) {
+	now := time.Now()
+	l, _ := New(Limits{Hour: 1.00}, "")
+	l.now = func() time.Time { return now }
+
+	l.Record(Entry{Tool: "alpha"}, 1.00)
+	if err := l.Check("s1", "alpha", -1); err == nil {
+		t.Fatal("Expected hourly cap error")
+	}
+
+	now = now.Add(61 * time.Minute)
+	if err := l.Check("s1", "alpha", -1); err != nil {
+		t.Errorf("Expected hourly cap to reset after an hour, got %v", err)
+	}
+}
+
+func TestUnknownCostUsesDefaultCost(t *testing.T) {
+	l, _ := New(Limits{Day: 0.25, DefaultCost: 0.10}, "")
+
+	l.Record(Entry{Tool: "alpha"}, -1)
+	l.Record(Entry{Tool: "alpha"}, -1)
+
+	// 0.20 spent; another estimated 0.10 would exceed 0.25
+	if err := l.Check("s1", "alpha", -1); err == nil {
+		t.Error("Expected daily cap error based on the default cost")
+	}
+	if l.Spent() != 0.20 {
+		t.Errorf("Expected 0.20 spent, got %.2f", l.Spent())
+	}
+}
+
+func TestDailyCapSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	l, err := New(Limits{Day: 1.00, Session: 1.00}, path)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	l.Record(Entry{Tool: "alpha", Session: "stdio", Client: "test-client"}, 1.00)
+
+	reopened, err := New(Limits{Day: 1.00, Session: 1.00}, path)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	var capErr *CapError
+	if err := reopened.Check("stdio", "alpha", -1); !errors.As(err, &capErr) || capErr.Cap != "daily" {
+		t.Errorf("Expected daily cap error after restart, got %v", err)
+	}
+}
+
+func TestNoLimitsNeverRefuses(t *testing.T) {
+	l, _ := New(Limits{}, "")
+	l.Record(Entry{Tool: "alpha"}, 1000)
+	if err := l.Check("s1", "alpha", -1); err != nil {
+		t.Errorf("Expected no error without limits, got %v", err)
+	}
+}
+
+func TestLastCostIgnoresEstimates(t *testing.T) {
+	l, _ := New(Limits{DefaultCost: 0.05}, "")
+
+	if _, ok := l.LastCost("alpha"); ok {
+		t.Error("Expected no cost before any purchase")
+	}
+	l.Record(Entry{Tool: "alpha"}, 0.30)
+	l.Record(Entry{Tool: "alpha"}, -1)
+
+	if cost, ok := l.LastCost("alpha"); !ok || cost != 0.30 {
+		t.Errorf("Expected last reported cost 0.30, got %v (%v)", cost, ok)
+	}
+}
+
+func TestStatusReportsConfiguredCaps(t *testing.T) {
+	l, _ := New(Limits{Day: 5.00, PerTool: map[string]float64{"alpha": 1.00}}, "")
+	l.Record(Entry{Tool: "alpha"}, 0.40)
+
+	status := l.Status("s1", "alpha")
+	if len(status) != 2 {
+		t.Fatalf("Expected daily and tool caps, got %+v", status)
+	}
+	if status[0].Cap != "daily" || status[0].Remaining() != 4.60 {
+		t.Errorf("Unexpected daily status: %+v", status[0])
+	}
+	if status[1].Cap != "tool alpha" || status[1].Remaining() != 0.60 {
+		t.Errorf("Unexpected tool status: %+v", status[1])
+	}
+}
+
+func TestPurchasesAndBalance(t *testing.T) {
+	l, _ := New(Limits{PerTool: map[string]float64{"beta": 1.00, "alpha": 2.00}}, "")
+
+	first := l.Record(Entry{Tool: "alpha", Details: []byte(`{"remaining_balance": "9.50"}`)}, 0.50)
+	second := l.Record(Entry{Tool: "beta", Result: "ok"}, 0.25)
+	if first.ID == "" || first.ID == second.ID {
+		t.Fatalf("Expected distinct purchase IDs, got %q and %q", first.ID, second.ID)
+	}
+
+	recent := l.Purchases(10)
+	if len(recent) != 2 || recent[0].ID != second.ID {
+		t.Errorf("Expected the newest purchase first, got %+v", recent)
+	}
+	if got, ok := l.Purchase(first.ID); !ok || string(got.Details) != `{"remaining_balance": "9.50"}` {
+		t.Errorf("Expected the full receipt, got %+v", got)
+	}
+	if _, ok := l.Purchase("missing"); ok {
+		t.Error("Expected an unknown ID not to be found")
+	}
+
+	if balance, _, ok := l.Balance(); !ok || balance != 9.50 {
+		t.Errorf("Expected the last reported balance 9.50, got %v, %v", balance, ok)
+	}
+
+	caps := l.Budget("s1")
+	if len(caps) != 2 || caps[0].Cap != "tool alpha" || caps[0].Spent != 0.50 || caps[1].Cap != "tool beta" {
+		t.Errorf("Expected every per-tool cap, got %+v", caps)
+	}
+}
@@ -0,0 +1,292 @@
+package naming
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileName is the name registry file name inside the install directory
+const FileName = "tool-names.json"
+
+// Version is the registry file format version; files with another version are ignored
+const Version = 1
+
+// MaxLength is the longest tool name MCP allows
+const MaxLength = 64
+
+// Tool is one catalog entry to name
+type Tool struct {
+	ProductID string
+	Name      string // Preferred MCP name, derived from the description
+}
+
+// Assignment is the outcome of naming a catalog
+type Assignment struct {
+	Names   map[string]string // Product ID -> MCP tool name
+	Aliases map[string]string // Former tool name -> product ID
+}
+
+// Resolve returns the name -> product ID map for an assignment
+// Aliases never shadow a current tool name.
+func (a Assignment) Resolve() map[string]string {
+	resolve := make(map[string]string, len(a.Names)+len(a.Aliases))
+	for productID, name := range a.Names {
+		resolve[name] = productID
+	}
+	for alias, productID := range a.Aliases {
+		if _, exists := resolve[alias]; !exists {
+			resolve[alias] = productID
+		}
+	}
+	return resolve
+}
+
+// entry is a persisted name assignment
+type entry struct {
+	Name string `json:"name"` // Assigned tool name
+	Base string `json:"base"` // Preferred name it was derived from
+}
+
+// file is the on-disk registry
+type file struct {
+	Version int               `json:"version"`
+	Names   map[string]entry  `json:"names"`   // Product ID -> assignment
+	Aliases map[string]string `json:"aliases"` // Former tool name -> product ID
+}
+
+// Registry assigns unique tool names that stay the same across restarts
+// Two products with the same preferred name are told apart by a short
+// product-ID suffix; a product whose description changes gets its new name
+// and keeps the old one as an alias. A nil *Registry names each catalog
+// from scratch and remembers nothing.
+type Registry struct {
+	mu      sync.Mutex
+	path    string // JSON file ("" keeps the registry in memory)
+	names   map[string]entry
+	aliases map[string]string
+}
+
+// DefaultPath returns the registry path next to the executable
+// Falls back to the user config directory when the install directory isn't writable.
+func DefaultPath() string {
+	if exePath, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exePath)
+		if isWritable(dir) {
+			return filepath.Join(dir, FileName)
+		}
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "agentpmt", FileName)
+	}
+	return FileName
+}
+
+// Open loads the registry at path; a missing file starts an empty registry
+func Open(path string) (*Registry, error) {
+	r := &Registry{
+		path:    path,
+		names:   make(map[string]entry),
+		aliases: make(map[string]string),
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return r, fmt.Errorf("failed to read name registry: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return r, fmt.Errorf("failed to parse name registry: %w", err)
+	}
+	if f.Version != Version {
+		return r, fmt.Errorf("name registry version %d is not supported (want %d)", f.Version, Version)
+	}
+	for productID, e := range f.Names {
+		r.names[productID] = e
+	}
+	for alias, productID := range f.Aliases {
+		r.aliases[alias] = productID
+	}
+	return r, nil
+}
+
+// Assign names every tool in a catalog and records the result
+// Products keep the name they were given before as long as their preferred
+// name hasn't changed. New and renamed products are named in product-ID order:
+// the first to want a free name gets it, the rest get a product-ID suffix.
+// Products no longer in the catalog are forgotten along with their aliases.
+func (r *Registry) Assign(tools []Tool) Assignment {
+	var names map[string]entry
+	var aliases map[string]string
+	if r != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		names, aliases = r.names, r.aliases
+	}
+
+	sorted := make([]Tool, 0, len(tools))
+	seen := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		if tool.ProductID == "" || seen[tool.ProductID] {
+			continue
+		}
+		seen[tool.ProductID] = true
+		if tool.Name == "" {
+			tool.Name = clean(tool.ProductID)
+		}
+		sorted = append(sorted, tool)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ProductID < sorted[j].ProductID })
+
+	assigned := make(map[string]entry, len(sorted))
+	taken := make(map[string]bool, len(sorted))
+
+	// Unchanged products keep their names
+	for _, tool := range sorted {
+		if prev, ok := names[tool.ProductID]; ok && prev.Base == tool.Name && !taken[prev.Name] {
+			assigned[tool.ProductID] = prev
+			taken[prev.Name] = true
+		}
+	}
+
+	// New and renamed products take their preferred name if it's free
+	for _, tool := range sorted {
+		if _, ok := assigned[tool.ProductID]; ok {
+			continue
+		}
+		name := tool.Name
+		for attempt := 0; taken[name]; attempt++ {
+			name = withSuffix(tool.Name, tool.ProductID, attempt)
+		}
+		assigned[tool.ProductID] = entry{Name: name, Base: tool.Name}
+		taken[name] = true
+	}
+
+	// Former names of products still in the catalog stay callable
+	keptAliases := make(map[string]string)
+	for alias, productID := range aliases {
+		if _, ok := assigned[productID]; ok && !taken[alias] {
+			keptAliases[alias] = productID
+		}
+	}
+	for productID, current := range assigned {
+		if prev, ok := names[productID]; ok && prev.Name != current.Name && !taken[prev.Name] {
+			keptAliases[prev.Name] = productID
+		}
+	}
+
+	if r != nil {
+		r.names, r.aliases = assigned, keptAliases
+	}
+
+	a := Assignment{Names: make(map[string]string, len(assigned)), Aliases: make(map[string]string, len(keptAliases))}
+	for productID, e := range assigned {
+		a.Names[productID] = e.Name
+	}
+	for alias, productID := range keptAliases {
+		a.Aliases[alias] = productID
+	}
+	return a
+}
+
+// Save writes the registry atomically (write to a temp file, then rename)
+func (r *Registry) Save() error {
+	if r == nil || r.path == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(file{Version: Version, Names: r.names, Aliases: r.aliases}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal name registry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create name registry directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), FileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create name registry file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write name registry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write name registry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to replace name registry: %w", err)
+	}
+	return nil
+}
+
+// withSuffix disambiguates name with the end of the product ID
+// Each attempt uses one more character (starting from six) until the whole ID
+// is used; after that a counter is added.
+func withSuffix(name, productID string, attempt int) string {
+	id := strings.ToLower(clean(productID))
+	full := len(id) - 6
+	if full < 0 {
+		full = 0
+	}
+	suffix := id
+	switch {
+	case attempt < full:
+		suffix = id[len(id)-6-attempt:]
+	case attempt > full:
+		suffix = fmt.Sprintf("%s-%d", id, attempt-full+1)
+	}
+	suffix = strings.TrimLeft(suffix, "-_")
+
+	keep := MaxLength - len(suffix) - 1
+	if keep < 1 {
+		return suffix[len(suffix)-MaxLength:]
+	}
+	if len(name) > keep {
+		name = name[:keep]
+	}
+	return strings.TrimRight(name, "-_") + "-" + suffix
+}
+
+// clean keeps only the characters MCP allows in a tool name
+func clean(s string) string {
+	var b strings.Builder
+	for _, ch := range s {
+		if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') ||
+			(ch >= '0' && ch <= '9') || ch == '-' || ch == '_' {
+			b.WriteRune(ch)
+		}
+	}
+	if b.Len() == 0 {
+		return "tool"
+	}
+	return b.String()
+}
+
+// isWritable reports whether files can be created in dir
+func isWritable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
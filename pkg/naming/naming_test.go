@@ -0,0 +1,96 @@
+package naming
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAssignDisambiguatesCollisions(t *testing.T) {
+	tools := []Tool{
+		{ProductID: "68a1f0000000000000beef02", Name: "weather"},
+		{ProductID: "68a1f0000000000000beef01", Name: "weather"},
+		{ProductID: "68a1f0000000000000cafe03", Name: "news"},
+	}
+
+	a := (*Registry)(nil).Assign(tools)
+	want := map[string]string{
+		"68a1f0000000000000beef01": "weather",
+		"68a1f0000000000000beef02": "weather-beef02",
+		"68a1f0000000000000cafe03": "news",
+	}
+	if !reflect.DeepEqual(a.Names, want) {
+		t.Errorf("Assign() = %v, want %v", a.Names, want)
+	}
+
+	// Catalog order doesn't matter
+	reversed := []Tool{tools[2], tools[1], tools[0]}
+	if b := (*Registry)(nil).Assign(reversed); !reflect.DeepEqual(b.Names, want) {
+		t.Errorf("Assign() in another order = %v, want %v", b.Names, want)
+	}
+}
+
+func TestAssignKeepsNamesAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	r.Assign([]Tool{{ProductID: "prod-b", Name: "weather"}})
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A new product with a lower ID wants the same name after a restart
+	r, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	a := r.Assign([]Tool{
+		{ProductID: "prod-a", Name: "weather"},
+		{ProductID: "prod-b", Name: "weather"},
+	})
+	if a.Names["prod-b"] != "weather" || a.Names["prod-a"] != "weather-prod-a" {
+		t.Errorf("Expected the existing product to keep its name, got %v", a.Names)
+	}
+}
+
+func TestAssignKeepsRenamedToolsAsAliases(t *testing.T) {
+	r, _ := Open("")
+	r.Assign([]Tool{{ProductID: "prod-1", Name: "weather"}})
+	r.Assign([]Tool{{ProductID: "prod-1", Name: "forecast"}})
+	a := r.Assign([]Tool{{ProductID: "prod-1", Name: "forecast-pro"}})
+
+	if a.Names["prod-1"] != "forecast-pro" {
+		t.Errorf("Expected the new name, got %v", a.Names)
+	}
+	want := map[string]string{"weather": "prod-1", "forecast": "prod-1"}
+	if !reflect.DeepEqual(a.Aliases, want) {
+		t.Errorf("Aliases = %v, want %v", a.Aliases, want)
+	}
+	if resolve := a.Resolve(); resolve["weather"] != "prod-1" || resolve["forecast-pro"] != "prod-1" {
+		t.Errorf("Resolve() = %v", resolve)
+	}
+
+	// A new tool claiming a former name takes it over; a removed tool's aliases go
+	a = r.Assign([]Tool{{ProductID: "prod-1", Name: "forecast-pro"}, {ProductID: "prod-2", Name: "weather"}})
+	if a.Names["prod-2"] != "weather" || a.Aliases["weather"] != "" {
+		t.Errorf("Expected weather to belong to prod-2, got names %v aliases %v", a.Names, a.Aliases)
+	}
+	a = r.Assign([]Tool{{ProductID: "prod-2", Name: "weather"}})
+	if len(a.Aliases) != 0 {
+		t.Errorf("Expected aliases of removed tools to be dropped, got %v", a.Aliases)
+	}
+}
+
+func TestWithSuffixStaysWithinLimit(t *testing.T) {
+	long := "a-very-long-tool-name-that-goes-on-and-on-and-on-well-past-the-limit"
+	name := withSuffix(long, "68a1f0000000000000beef02", 0)
+	if len(name) > MaxLength || name[len(name)-7:] != "-beef02" {
+		t.Errorf("withSuffix() = %q", name)
+	}
+	if got := withSuffix("weather", "p1", 1); got != "weather-p1-2" {
+		t.Errorf("withSuffix() past the ID = %q, want weather-p1-2", got)
+	}
+}
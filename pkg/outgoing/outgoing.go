@@ -0,0 +1,104 @@
+// Package outgoing sends server-initiated JSON-RPC requests (such as
+// elicitation/create) to an MCP client and matches the client's responses to
+// the calls waiting for them.
+package outgoing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Error is a JSON-RPC error returned by the client
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("client error %d: %s", e.Code, e.Message)
+}
+
+// Request is a server-initiated JSON-RPC 2.0 request
+type Request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      string      `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// response is the client's answer to one Request
+type response struct {
+	result json.RawMessage
+	err    *Error
+}
+
+// Calls tracks server-initiated requests waiting for the client's response
+type Calls struct {
+	mu      sync.Mutex
+	next    int64
+	pending map[string]chan response
+}
+
+// New creates an empty tracker
+func New() *Calls {
+	return &Calls{pending: make(map[string]chan response)}
+}
+
+// Call sends a request with send and waits for the client's response or ctx
+func (o *Calls) Call(ctx context.Context, send func(interface{}) error, method string, params interface{}) (json.RawMessage, error) {
+	if send == nil {
+		return nil, fmt.Errorf("client is not connected")
+	}
+
+	reply := make(chan response, 1)
+
+	o.mu.Lock()
+	o.next++
+	id := fmt.Sprintf("srv-%d", o.next)
+	o.pending[id] = reply
+	o.mu.Unlock()
+
+	defer func() {
+		o.mu.Lock()
+		delete(o.pending, id)
+		o.mu.Unlock()
+	}()
+
+	if err := send(Request{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	select {
+	case resp := <-reply:
+		if resp.err != nil {
+			return nil, resp.err
+		}
+		return resp.result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Deliver routes a response from the client to the waiting call
+// Returns false if no call is waiting for it (late or unknown id).
+func (o *Calls) Deliver(id interface{}, result json.RawMessage, rpcErr *Error) bool {
+	// Call only sends string ids, so anything else can't be ours
+	key, ok := id.(string)
+	if !ok {
+		return false
+	}
+
+	o.mu.Lock()
+	reply, exists := o.pending[key]
+	delete(o.pending, key)
+	o.mu.Unlock()
+
+	if !exists {
+		return false
+	}
+	reply <- response{result: result, err: rpcErr}
+	return true
+}
@@ -0,0 +1,80 @@
+package outgoing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCallReturnsDeliveredResult(t *testing.T) {
+	calls := New()
+	sent := make(chan Request, 1)
+	send := func(msg interface{}) error {
+		sent <- msg.(Request)
+		return nil
+	}
+
+	go func() {
+		req := <-sent
+		if !calls.Deliver(req.ID, json.RawMessage(`{"action":"accept"}`), nil) {
+			t.Error("Expected a waiting call for the response")
+		}
+	}()
+
+	result, err := calls.Call(context.Background(), send, "elicitation/create", nil)
+	if err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if string(result) != `{"action":"accept"}` {
+		t.Errorf("Call() = %s", result)
+	}
+}
+
+func TestCallReturnsClientError(t *testing.T) {
+	calls := New()
+	send := func(msg interface{}) error {
+		go calls.Deliver(msg.(Request).ID, nil, &Error{Code: -32601, Message: "not supported"})
+		return nil
+	}
+
+	_, err := calls.Call(context.Background(), send, "elicitation/create", nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("Expected the client's error, got %v", err)
+	}
+}
+
+func TestCallStopsWithContext(t *testing.T) {
+	calls := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var id string
+	_, err := calls.Call(ctx, func(msg interface{}) error {
+		id = msg.(Request).ID
+		return nil
+	}, "elicitation/create", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline error, got %v", err)
+	}
+
+	// A late response finds nobody waiting
+	if calls.Deliver(id, json.RawMessage(`{}`), nil) {
+		t.Error("Expected a late response to be dropped")
+	}
+}
+
+func TestCallWithoutClient(t *testing.T) {
+	if _, err := New().Call(context.Background(), nil, "elicitation/create", nil); err == nil {
+		t.Error("Expected an error without a way to send")
+	}
+}
+
+func TestDeliverUnknownID(t *testing.T) {
+	calls := New()
+	if calls.Deliver("srv-1", nil, nil) || calls.Deliver(float64(1), nil, nil) {
+		t.Error("Expected unknown ids to be dropped")
+	}
+}
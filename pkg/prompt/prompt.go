@@ -0,0 +1,433 @@
+package prompt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DirName is the custom prompt directory, next to config.json
+const DirName = "prompts"
+
+// Built-in prompt names
+const (
+	PlanWithinBudget = "plan-within-budget"
+	CheapestTool     = "cheapest-tool"
+
+	// toolPrefix starts the name of each tool's usage prompt
+	toolPrefix = "use-"
+)
+
+// maxListed caps how many tools cheapest-tool lists with their price
+const maxListed = 100
+
+// ErrNotFound is returned by Get for a name that is not a prompt
+var ErrNotFound = errors.New("prompt not found")
+
+// ArgumentError reports a missing or malformed prompt argument
+type ArgumentError struct {
+	Prompt   string
+	Argument string
+	Reason   string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("prompt %s: argument %s %s", e.Prompt, e.Argument, e.Reason)
+}
+
+// Argument is an argument a prompt accepts
+type Argument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// Prompt is an entry in prompts/list
+type Prompt struct {
+	Name        string     `json:"name"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Arguments   []Argument `json:"arguments,omitempty"`
+}
+
+// Content is the text of a prompt message
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Message is one message of a prompts/get result
+type Message struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// Result is a prompts/get result
+type Result struct {
+	Description string    `json:"description,omitempty"`
+	Messages    []Message `json:"messages"`
+}
+
+// Example is a sample call the catalog publishes for a tool (x-examples)
+type Example struct {
+	Description string          `json:"description,omitempty"`
+	Arguments   json.RawMessage `json:"arguments"`
+}
+
+// Tool is what prompts need to know about a listed tool
+type Tool struct {
+	Name        string
+	Title       string
+	Description string
+	Price       *float64 // nil when unknown
+	Currency    string
+	Unit        string
+	Examples    []Example
+}
+
+// Template is a team's own prompt, read from the prompt directory
+// Placeholders like {{project}} are replaced by the argument of that name;
+// when Arguments is empty, each placeholder becomes a required argument.
+type Template struct {
+	Prompt
+	Text string `json:"template"`
+}
+
+// Library serves the built-in prompts, per-tool usage prompts and a team's
+// own templates. A nil *Library serves only the built-in and per-tool prompts.
+type Library struct {
+	custom map[string]Template
+}
+
+// DefaultDir returns the prompt directory next to the executable
+func DefaultDir() string {
+	if exePath, err := os.Executable(); err == nil {
+		return filepath.Join(filepath.Dir(exePath), DirName)
+	}
+	return DirName
+}
+
+// LoadDir reads every *.json and *.md template in dir; a missing directory means none
+// A .json file holds a Template; a .md file is the template text, described
+// by its first line.
+func LoadDir(dir string) (*Library, error) {
+	l := &Library{custom: make(map[string]Template)}
+	if dir == "" {
+		return l, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".md") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt %s: %w", path, err)
+		}
+
+		var t Template
+		if ext == ".json" {
+			if err := json.Unmarshal(data, &t); err != nil {
+				return nil, fmt.Errorf("failed to parse prompt %s: %w", path, err)
+			}
+		} else {
+			t.Text = string(data)
+			t.Description = strings.TrimSpace(strings.TrimLeft(firstLine(t.Text), "# "))
+		}
+		if t.Name == "" {
+			t.Name = strings.TrimSuffix(entry.Name(), ext)
+		}
+		if strings.TrimSpace(t.Text) == "" {
+			return nil, fmt.Errorf("prompt %s has no template text", path)
+		}
+		if len(t.Arguments) == 0 {
+			for _, name := range placeholders(t.Text) {
+				t.Arguments = append(t.Arguments, Argument{Name: name, Required: true})
+			}
+		}
+		l.custom[t.Name] = t
+	}
+	return l, nil
+}
+
+// Len returns the number of templates loaded from the prompt directory
+func (l *Library) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.custom)
+}
+
+// List returns every prompt, sorted by name
+// Templates replace built-in and per-tool prompts of the same name.
+func (l *Library) List(tools []Tool) []Prompt {
+	byName := map[string]Prompt{
+		PlanWithinBudget: planWithinBudgetPrompt,
+		CheapestTool:     cheapestToolPrompt,
+	}
+	for _, tool := range tools {
+		if len(tool.Examples) > 0 {
+			byName[toolPrefix+tool.Name] = toolPrompt(tool)
+		}
+	}
+	if l != nil {
+		for name, t := range l.custom {
+			byName[name] = t.Prompt
+		}
+	}
+
+	prompts := make([]Prompt, 0, len(byName))
+	for _, p := range byName {
+		prompts = append(prompts, p)
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+	return prompts
+}
+
+// Get renders the named prompt with args
+// Returns ErrNotFound for an unknown name and *ArgumentError for bad arguments.
+func (l *Library) Get(name string, args map[string]string, tools []Tool) (Result, error) {
+	if l != nil {
+		if t, ok := l.custom[name]; ok {
+			return t.render(args)
+		}
+	}
+
+	switch name {
+	case PlanWithinBudget:
+		return planWithinBudget(args)
+	case CheapestTool:
+		return cheapestTool(args, tools)
+	}
+	if strings.HasPrefix(name, toolPrefix) {
+		for _, tool := range tools {
+			if toolPrefix+tool.Name == name && len(tool.Examples) > 0 {
+				return useTool(tool, args), nil
+			}
+		}
+	}
+	return Result{}, ErrNotFound
+}
+
+var planWithinBudgetPrompt = Prompt{
+	Name:        PlanWithinBudget,
+	Title:       "Plan a task within a budget",
+	Description: "Plan the paid tool calls for a task so the total stays within a budget",
+	Arguments: []Argument{
+		{Name: "task", Description: "What needs to be done", Required: true},
+		{Name: "budget", Description: "Most the task may cost, e.g. 2.50", Required: true},
+	},
+}
+
+// planWithinBudget renders the plan-within-budget prompt
+func planWithinBudget(args map[string]string) (Result, error) {
+	task, err := required(PlanWithinBudget, args, "task")
+	if err != nil {
+		return Result{}, err
+	}
+	budget, err := required(PlanWithinBudget, args, "budget")
+	if err != nil {
+		return Result{}, err
+	}
+	if amount, err := strconv.ParseFloat(strings.TrimPrefix(budget, "$"), 64); err != nil || amount <= 0 {
+		return Result{}, &ArgumentError{Prompt: PlanWithinBudget, Argument: "budget", Reason: "must be a positive amount"}
+	}
+
+	text := fmt.Sprintf(`Plan how to do this task without spending more than %s:
+
+%s
+
+Before calling any paid tool, read the agentpmt://budget resource for the balance and spend caps, and price each call with the quote_tool tool. List the calls you intend to make with the expected price of each and a running total. If the plan would cost more than %s, say so and propose a cheaper plan instead of starting. After each call, compare what was charged with the plan and stop if what is left can't cover the next step.`, budget, task, budget)
+	return userResult(planWithinBudgetPrompt.Description, text), nil
+}
+
+var cheapestToolPrompt = Prompt{
+	Name:        CheapestTool,
+	Title:       "Pick the cheapest tool",
+	Description: "Choose the cheapest tool that can do a task, using the catalog's prices",
+	Arguments: []Argument{
+		{Name: "task", Description: "What the tool needs to do", Required: true},
+	},
+}
+
+// cheapestTool renders the cheapest-tool prompt with the catalog's prices
+func cheapestTool(args map[string]string, tools []Tool) (Result, error) {
+	task, err := required(CheapestTool, args, "task")
+	if err != nil {
+		return Result{}, err
+	}
+
+	var priced []Tool
+	var unpriced []string
+	for _, tool := range tools {
+		if tool.Price != nil {
+			priced = append(priced, tool)
+		} else {
+			unpriced = append(unpriced, tool.Name)
+		}
+	}
+	sort.SliceStable(priced, func(i, j int) bool { return *priced[i].Price < *priced[j].Price })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Find the cheapest tool for this task:\n\n%s\n\n", task)
+	if len(priced) > 0 {
+		b.WriteString("Tools with a listed price, cheapest first:\n")
+		for i, tool := range priced {
+			if i == maxListed {
+				fmt.Fprintf(&b, "- ... and %d more\n", len(priced)-maxListed)
+				break
+			}
+			fmt.Fprintf(&b, "- %s: %s — %s\n", tool.Name, formatPrice(tool), summary(tool))
+		}
+		b.WriteString("\n")
+	}
+	if len(unpriced) > 0 {
+		fmt.Fprintf(&b, "Tools without a listed price: %s\n\n", strings.Join(unpriced, ", "))
+	}
+	b.WriteString("Pick the cheapest tool that can actually do the task, confirm its price with the quote_tool tool, and explain the choice in a sentence before calling it.")
+	return userResult(cheapestToolPrompt.Description, b.String()), nil
+}
+
+// toolPrompt describes a tool's usage prompt
+func toolPrompt(tool Tool) Prompt {
+	title := tool.Title
+	if title == "" {
+		title = tool.Name
+	}
+	return Prompt{
+		Name:        toolPrefix + tool.Name,
+		Title:       "Use " + title,
+		Description: fmt.Sprintf("How to call %s, with examples from the catalog", tool.Name),
+		Arguments: []Argument{
+			{Name: "task", Description: "What you want the tool to do"},
+		},
+	}
+}
+
+// useTool renders a tool's usage prompt from its catalog examples
+func useTool(tool Tool, args map[string]string) Result {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Use the %s tool. %s\n", tool.Name, strings.TrimSpace(tool.Description))
+	if tool.Price != nil {
+		fmt.Fprintf(&b, "\nPrice: %s\n", formatPrice(tool))
+	}
+
+	b.WriteString("\nExamples of valid arguments:\n")
+	for _, example := range tool.Examples {
+		if example.Description != "" {
+			fmt.Fprintf(&b, "\n%s\n", example.Description)
+		}
+		fmt.Fprintf(&b, "```json\n%s\n```\n", indent(example.Arguments))
+	}
+
+	if task := strings.TrimSpace(args["task"]); task != "" {
+		fmt.Fprintf(&b, "\nTask: %s\n\nCall %s with arguments shaped like the examples.", task, tool.Name)
+	} else {
+		fmt.Fprintf(&b, "\nCall %s with arguments shaped like the examples.", tool.Name)
+	}
+	return userResult(toolPrompt(tool).Description, b.String())
+}
+
+// placeholderPattern matches {{name}} in a template
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_-]+)\s*\}\}`)
+
+// render substitutes args into a team template
+func (t Template) render(args map[string]string) (Result, error) {
+	for _, arg := range t.Arguments {
+		if arg.Required && strings.TrimSpace(args[arg.Name]) == "" {
+			return Result{}, &ArgumentError{Prompt: t.Name, Argument: arg.Name, Reason: "is required"}
+		}
+	}
+	text := placeholderPattern.ReplaceAllStringFunc(t.Text, func(match string) string {
+		return args[placeholderPattern.FindStringSubmatch(match)[1]]
+	})
+	return userResult(t.Description, text), nil
+}
+
+// placeholders returns the distinct placeholder names in text, in order
+func placeholders(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// required returns a required argument, trimmed
+func required(prompt string, args map[string]string, name string) (string, error) {
+	v := strings.TrimSpace(args[name])
+	if v == "" {
+		return "", &ArgumentError{Prompt: prompt, Argument: name, Reason: "is required"}
+	}
+	return v, nil
+}
+
+// userResult wraps text as a single user message
+func userResult(description, text string) Result {
+	return Result{
+		Description: description,
+		Messages:    []Message{{Role: "user", Content: Content{Type: "text", Text: text}}},
+	}
+}
+
+// formatPrice renders a tool's price, e.g. "0.0500 USD per call"
+func formatPrice(tool Tool) string {
+	s := fmt.Sprintf("%.4f", *tool.Price)
+	if tool.Currency != "" {
+		s += " " + tool.Currency
+	}
+	if tool.Unit != "" {
+		s += " per " + tool.Unit
+	}
+	return s
+}
+
+// summary returns the tool's title, or the first line of its description
+func summary(tool Tool) string {
+	if tool.Title != "" {
+		return tool.Title
+	}
+	return firstLine(tool.Description)
+}
+
+// firstLine returns the first non-empty line of s
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// indent pretty-prints JSON, falling back to the raw text
+func indent(raw json.RawMessage) string {
+	var v interface{}
+	if json.Unmarshal(raw, &v) != nil {
+		return string(raw)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return string(raw)
+	}
+	return string(data)
+}
@@ -0,0 +1,103 @@
+package prompt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func price(p float64) *float64 { return &p }
+
+var testTools = []Tool{
+	{Name: "image-pro", Title: "Image Pro", Price: price(0.20), Currency: "USD"},
+	{Name: "image-lite", Title: "Image Lite", Price: price(0.02), Currency: "USD", Unit: "image"},
+	{Name: "mystery", Description: "Does something"},
+	{
+		Name:        "weather",
+		Description: "Looks up the weather",
+		Examples:    []Example{{Description: "Current conditions", Arguments: []byte(`{"city":"Paris"}`)}},
+	},
+}
+
+func TestListIncludesBuiltInAndToolPrompts(t *testing.T) {
+	var names []string
+	for _, p := range (*Library)(nil).List(testTools) {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "cheapest-tool,plan-within-budget,use-weather" {
+		t.Errorf("List() = %s", got)
+	}
+}
+
+func TestGetBuiltInPrompts(t *testing.T) {
+	var l *Library
+
+	r, err := l.Get(CheapestTool, map[string]string{"task": "draw a cat"}, testTools)
+	if err != nil {
+		t.Fatalf("Get(cheapest-tool) error = %v", err)
+	}
+	text := r.Messages[0].Content.Text
+	if strings.Index(text, "image-lite") > strings.Index(text, "image-pro") || !strings.Contains(text, "0.0200 USD per image") {
+		t.Errorf("Expected tools cheapest first with their price, got:\n%s", text)
+	}
+	if !strings.Contains(text, "without a listed price: mystery, weather") {
+		t.Errorf("Expected unpriced tools to be listed, got:\n%s", text)
+	}
+
+	var argErr *ArgumentError
+	if _, err := l.Get(PlanWithinBudget, map[string]string{"task": "x", "budget": "lots"}, nil); !errors.As(err, &argErr) || argErr.Argument != "budget" {
+		t.Errorf("Expected a budget argument error, got %v", err)
+	}
+	if r, err := l.Get(PlanWithinBudget, map[string]string{"task": "research flights", "budget": "2.50"}, nil); err != nil ||
+		!strings.Contains(r.Messages[0].Content.Text, "more than 2.50") {
+		t.Errorf("Get(plan-within-budget) = %+v, %v", r, err)
+	}
+
+	r, err = l.Get("use-weather", map[string]string{"task": "rain in Oslo?"}, testTools)
+	if err != nil || !strings.Contains(r.Messages[0].Content.Text, `"city": "Paris"`) {
+		t.Errorf("Expected the catalog example, got %+v, %v", r, err)
+	}
+	if _, err := l.Get("use-mystery", nil, testTools); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected tools without examples to have no prompt, got %v", err)
+	}
+}
+
+func TestLoadDirTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if l, err := LoadDir(filepath.Join(dir, "missing")); err != nil || l.Len() != 0 {
+		t.Fatalf("Expected a missing directory to load no prompts, got %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "weekly-review.md"), []byte("# Review spend\nSummarize what {{project}} spent in {{period}}."), 0644)
+	os.WriteFile(filepath.Join(dir, "triage.json"), []byte(`{
+		"title": "Triage",
+		"arguments": [{"name": "issue", "required": true}, {"name": "team"}],
+		"template": "Triage {{issue}} for {{ team }}."
+	}`), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644)
+
+	l, err := LoadDir(dir)
+	if err != nil || l.Len() != 2 {
+		t.Fatalf("LoadDir() = %d prompts, %v", l.Len(), err)
+	}
+
+	for _, p := range l.List(nil) {
+		if p.Name == "weekly-review" && (p.Description != "Review spend" || len(p.Arguments) != 2 || !p.Arguments[0].Required) {
+			t.Errorf("Expected arguments from the placeholders, got %+v", p)
+		}
+	}
+
+	r, err := l.Get("weekly-review", map[string]string{"project": "atlas", "period": "May"}, nil)
+	if err != nil || !strings.HasSuffix(r.Messages[0].Content.Text, "Summarize what atlas spent in May.") {
+		t.Errorf("Get(weekly-review) = %+v, %v", r, err)
+	}
+	if r, err := l.Get("triage", map[string]string{"issue": "#12"}, nil); err != nil || r.Messages[0].Content.Text != "Triage #12 for ." {
+		t.Errorf("Expected optional arguments to default to empty, got %+v, %v", r, err)
+	}
+	var argErr *ArgumentError
+	if _, err := l.Get("triage", nil, nil); !errors.As(err, &argErr) {
+		t.Errorf("Expected a missing argument error, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package resource
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+)
+
+// Resource URIs
+const (
+	BudgetURI    = "agentpmt://budget"
+	PurchasesURI = "agentpmt://purchases"
+
+	purchasePrefix = PurchasesURI + "/"
+	toolPrefix     = "agentpmt://tools/"
+)
+
+// MIMEType is the type of every resource's contents
+const MIMEType = "application/json"
+
+// RecentPurchases is how many purchases agentpmt://purchases lists
+const RecentPurchases = 50
+
+// UpdatedByPurchase are the resources that change whenever a purchase is recorded
+var UpdatedByPurchase = []string{BudgetURI, PurchasesURI}
+
+// ErrNotFound is returned for URIs that name no resource
+var ErrNotFound = errors.New("resource not found")
+
+// Resource is an entry in resources/list
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Template is an entry in resources/templates/list
+type Template struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Contents is one item of a resources/read result
+type Contents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// List returns the fixed resources
+func List() []Resource {
+	return []Resource{
+		{
+			URI:         BudgetURI,
+			Name:        "budget",
+			Title:       "Budget",
+			Description: "Remaining balance last reported by AgentPMT and the local spend caps",
+			MimeType:    MIMEType,
+		},
+		{
+			URI:         PurchasesURI,
+			Name:        "purchases",
+			Title:       "Recent Purchases",
+			Description: fmt.Sprintf("Up to %d purchases from the last 24 hours, newest first", RecentPurchases),
+			MimeType:    MIMEType,
+		},
+	}
+}
+
+// Templates returns the parameterized resources
+func Templates() []Template {
+	return []Template{
+		{
+			URITemplate: purchasePrefix + "{id}",
+			Name:        "purchase",
+			Title:       "Purchase Receipt",
+			Description: "Full receipt of a recent purchase, including the API's purchase details",
+			MimeType:    MIMEType,
+		},
+		{
+			URITemplate: toolPrefix + "{name}",
+			Name:        "tool",
+			Title:       "Tool Documentation",
+			Description: "Description, input and output schemas, annotations and cost of a tool",
+			MimeType:    MIMEType,
+		},
+	}
+}
+
+// PurchaseURI returns the receipt URI of a purchase
+func PurchaseURI(id string) string {
+	return purchasePrefix + id
+}
+
+// ToolURI returns the documentation URI of a tool
+func ToolURI(name string) string {
+	return toolPrefix + name
+}
+
+// Reader serves resources/read
+type Reader struct {
+	Ledger *ledger.Ledger // nil: no purchases and no caps
+
+	// Tool returns the tools/list entry of a tool by name
+	Tool func(name string) (interface{}, bool)
+}
+
+// Read returns the contents of uri; session scopes the session spend cap
+func (r Reader) Read(uri, session string) (Contents, error) {
+	var v interface{}
+	switch {
+	case uri == BudgetURI:
+		v = r.budget(session)
+	case uri == PurchasesURI:
+		v = r.purchases()
+	case strings.HasPrefix(uri, purchasePrefix):
+		if r.Ledger == nil {
+			return Contents{}, ErrNotFound
+		}
+		e, ok := r.Ledger.Purchase(strings.TrimPrefix(uri, purchasePrefix))
+		if !ok {
+			return Contents{}, ErrNotFound
+		}
+		v = e
+	case strings.HasPrefix(uri, toolPrefix):
+		if r.Tool == nil {
+			return Contents{}, ErrNotFound
+		}
+		tool, ok := r.Tool(strings.TrimPrefix(uri, toolPrefix))
+		if !ok {
+			return Contents{}, ErrNotFound
+		}
+		v = tool
+	default:
+		return Contents{}, ErrNotFound
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return Contents{}, fmt.Errorf("failed to encode %s: %w", uri, err)
+	}
+	return Contents{URI: uri, MimeType: MIMEType, Text: string(data)}, nil
+}
+
+// Budget is the agentpmt://budget resource
+type Budget struct {
+	Balance  *Balance `json:"balance,omitempty"` // nil until the API reports one
+	Spent24h float64  `json:"spent_24h"`
+	Caps     []Cap    `json:"caps"`
+}
+
+// Balance is the remaining budget as of a purchase
+type Balance struct {
+	Amount float64   `json:"amount"`
+	AsOf   time.Time `json:"as_of"`
+}
+
+// Cap is a local spend cap and what is left under it
+type Cap struct {
+	ledger.CapStatus
+	Remaining float64 `json:"remaining"`
+}
+
+// budget reports the balance and caps
+func (r Reader) budget(session string) Budget {
+	b := Budget{Caps: []Cap{}}
+	if r.Ledger == nil {
+		return b
+	}
+	if amount, asOf, ok := r.Ledger.Balance(); ok {
+		b.Balance = &Balance{Amount: amount, AsOf: asOf}
+	}
+	b.Spent24h = r.Ledger.Spent()
+	for _, c := range r.Ledger.Budget(session) {
+		b.Caps = append(b.Caps, Cap{CapStatus: c, Remaining: c.Remaining()})
+	}
+	return b
+}
+
+// Purchase is a line of the agentpmt://purchases resource
+type Purchase struct {
+	ID        string    `json:"id"`
+	URI       string    `json:"uri"` // Full receipt
+	Time      time.Time `json:"time"`
+	Tool      string    `json:"tool"`
+	ProductID string    `json:"product_id"`
+	Amount    float64   `json:"amount"`
+	Estimated bool      `json:"estimated,omitempty"`
+}
+
+// purchases lists recent purchases
+func (r Reader) purchases() map[string][]Purchase {
+	list := []Purchase{}
+	if r.Ledger != nil {
+		for _, e := range r.Ledger.Purchases(RecentPurchases) {
+			list = append(list, Purchase{
+				ID:        e.ID,
+				URI:       PurchaseURI(e.ID),
+				Time:      e.Time,
+				Tool:      e.Tool,
+				ProductID: e.ProductID,
+				Amount:    e.Amount,
+				Estimated: e.Estimated,
+			})
+		}
+	}
+	return map[string][]Purchase{"purchases": list}
+}
+
+// Subscriptions are the resource URIs a client asked to be told about
+type Subscriptions struct {
+	mu   sync.Mutex
+	uris map[string]bool
+}
+
+// Subscribe adds uri
+func (s *Subscriptions) Subscribe(uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.uris == nil {
+		s.uris = make(map[string]bool)
+	}
+	s.uris[uri] = true
+}
+
+// Unsubscribe removes uri
+func (s *Subscriptions) Unsubscribe(uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uris, uri)
+}
+
+// Has reports whether the client subscribed to uri
+func (s *Subscriptions) Has(uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.uris[uri]
+}
@@ -0,0 +1,77 @@
+package resource
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+)
+
+func TestReadBudgetAndPurchases(t *testing.T) {
+	l, _ := ledger.New(ledger.Limits{Day: 5.00}, "")
+	e := l.Record(ledger.Entry{Tool: "weather", ProductID: "prod-1", Details: []byte(`{"balance": 8.75}`)}, 1.25)
+	r := Reader{Ledger: l}
+
+	c, err := r.Read(BudgetURI, "s1")
+	if err != nil || c.MimeType != MIMEType {
+		t.Fatalf("Read(budget) = %+v, %v", c, err)
+	}
+	var budget Budget
+	json.Unmarshal([]byte(c.Text), &budget)
+	if budget.Balance == nil || budget.Balance.Amount != 8.75 || budget.Spent24h != 1.25 {
+		t.Errorf("Unexpected budget %s", c.Text)
+	}
+	if len(budget.Caps) != 1 || budget.Caps[0].Cap != "daily" || budget.Caps[0].Remaining != 3.75 {
+		t.Errorf("Expected the daily cap with 3.75 left, got %+v", budget.Caps)
+	}
+
+	c, _ = r.Read(PurchasesURI, "s1")
+	var list struct{ Purchases []Purchase }
+	json.Unmarshal([]byte(c.Text), &list)
+	if len(list.Purchases) != 1 || list.Purchases[0].URI != PurchaseURI(e.ID) {
+		t.Errorf("Expected the purchase with its receipt URI, got %s", c.Text)
+	}
+
+	c, err = r.Read(PurchaseURI(e.ID), "s1")
+	var receipt ledger.Entry
+	json.Unmarshal([]byte(c.Text), &receipt)
+	if balance, _ := receipt.Balance(); err != nil || receipt.ID != e.ID || balance != 8.75 {
+		t.Errorf("Expected the full receipt, got %s, %v", c.Text, err)
+	}
+}
+
+func TestReadUnknownResources(t *testing.T) {
+	r := Reader{Tool: func(name string) (interface{}, bool) {
+		return map[string]string{"name": name}, name == "weather"
+	}}
+
+	if c, err := r.Read(ToolURI("weather"), ""); err != nil || c.URI != "agentpmt://tools/weather" {
+		t.Errorf("Read(tool) = %+v, %v", c, err)
+	}
+	for _, uri := range []string{ToolURI("news"), PurchaseURI("missing"), "agentpmt://other", "file:///etc/passwd"} {
+		if _, err := r.Read(uri, ""); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Read(%s) error = %v, want ErrNotFound", uri, err)
+		}
+	}
+
+	// Without a ledger the fixed resources are still readable
+	if c, err := r.Read(BudgetURI, ""); err != nil || c.Text == "" {
+		t.Errorf("Read(budget) = %+v, %v", c, err)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	var s Subscriptions
+	if s.Has(BudgetURI) {
+		t.Error("Expected no subscriptions")
+	}
+	s.Subscribe(BudgetURI)
+	if !s.Has(BudgetURI) || s.Has(PurchasesURI) {
+		t.Error("Expected only the budget subscription")
+	}
+	s.Unsubscribe(BudgetURI)
+	if s.Has(BudgetURI) {
+		t.Error("Expected the subscription to be removed")
+	}
+}
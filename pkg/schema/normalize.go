@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Change is one edit Normalize made to the arguments
+type Change struct {
+	Field  string      `json:"field"`
+	Action string      `json:"action"` // "coerced", "defaulted" or "dropped"
+	From   interface{} `json:"from,omitempty"`
+	To     interface{} `json:"to,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Action {
+	case "coerced":
+		return fmt.Sprintf("%s: coerced %#v -> %#v", c.Field, c.From, c.To)
+	case "defaulted":
+		return fmt.Sprintf("%s: filled default %#v", c.Field, c.To)
+	}
+	return fmt.Sprintf("%s: dropped %#v", c.Field, c.From)
+}
+
+// Normalize fixes arguments to fit a JSON Schema before they are validated
+// Scalars are coerced to the declared type like the catalog's defaults are, but
+// only when the conversion is lossless ("5" -> 5, "true" -> true, 5 -> "5", a
+// lone item -> a one-item list), missing properties with a declared
+// default are filled in, and properties forbidden by additionalProperties:
+// false are dropped. Anything it can't fix is left for Validate to report.
+// args is not modified; the normalized copy and every change are returned.
+func Normalize(rawSchema json.RawMessage, args map[string]interface{}) (map[string]interface{}, []Change) {
+	var root map[string]interface{}
+	if len(rawSchema) == 0 || json.Unmarshal(rawSchema, &root) != nil {
+		return args, nil
+	}
+
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+
+	var changes []Change
+	normalized, _ := normalizeValue(root, args, "", &changes).(map[string]interface{})
+	return normalized, changes
+}
+
+// normalizeValue returns value adjusted to its schema node
+func normalizeValue(node map[string]interface{}, value interface{}, path string, changes *[]Change) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return normalizeObject(node, v, path, changes)
+	case []interface{}:
+		items, _ := node["items"].(map[string]interface{})
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = item
+			if items != nil {
+				out[i] = normalizeValue(items, item, fmt.Sprintf("%s[%d]", path, i), changes)
+			}
+		}
+		return out
+	}
+
+	types := schemaTypes(node["type"])
+	if len(types) == 0 || matchesAny(value, types) {
+		return value
+	}
+	for _, t := range types {
+		if coerced, ok := coerce(value, t); ok {
+			*changes = append(*changes, Change{Field: path, Action: "coerced", From: value, To: coerced})
+			if list, isList := coerced.([]interface{}); isList {
+				return normalizeValue(node, list, path, changes) // Coerce the wrapped item too
+			}
+			return coerced
+		}
+	}
+	return value
+}
+
+// normalizeObject fills defaults, drops forbidden properties and recurses
+func normalizeObject(node map[string]interface{}, obj map[string]interface{}, path string, changes *[]Change) map[string]interface{} {
+	properties, _ := node["properties"].(map[string]interface{})
+	out := make(map[string]interface{}, len(obj))
+
+	for _, name := range sortedKeys(obj) {
+		prop, known := properties[name].(map[string]interface{})
+		if !known {
+			if node["additionalProperties"] == false {
+				*changes = append(*changes, Change{Field: join(path, name), Action: "dropped", From: obj[name]})
+				continue
+			}
+			out[name] = obj[name]
+			continue
+		}
+		out[name] = normalizeValue(prop, obj[name], join(path, name), changes)
+	}
+
+	for _, name := range sortedKeys(properties) {
+		prop, _ := properties[name].(map[string]interface{})
+		def, hasDefault := prop["default"]
+		if _, present := out[name]; present || !hasDefault {
+			continue
+		}
+		filled := normalizeValue(prop, copyValue(def), join(path, name), new([]Change))
+		out[name] = filled
+		*changes = append(*changes, Change{Field: join(path, name), Action: "defaulted", To: filled})
+	}
+
+	return out
+}
+
+// coerce converts a scalar to the JSON Schema type t without losing information
+func coerce(value interface{}, t string) (interface{}, bool) {
+	switch t {
+	case "integer":
+		switch v := value.(type) {
+		case string:
+			if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return float64(n), true
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && f == math.Trunc(f) && !math.IsInf(f, 0) {
+				return f, true
+			}
+		}
+	case "number":
+		if v, ok := value.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				return f, true
+			}
+		}
+	case "boolean":
+		if v, ok := value.(string); ok {
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true":
+				return true, true
+			case "false":
+				return false, true
+			}
+		}
+	case "string":
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+	case "array":
+		// A lone item where a list is expected
+		switch value.(type) {
+		case string, float64, bool:
+			return []interface{}{value}, true
+		}
+	}
+	return nil, false
+}
+
+// copyValue deep-copies a decoded JSON default so callers can't share it
+func copyValue(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out interface{}
+	if json.Unmarshal(data, &out) != nil {
+		return value
+	}
+	return out
+}
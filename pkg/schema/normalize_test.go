@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const normalizeSchema = `{
+	"type": "object",
+	"properties": {
+		"days": {"type": "integer"},
+		"ratio": {"type": "number"},
+		"metric": {"type": "boolean"},
+		"zip": {"type": "string"},
+		"tags": {"type": "array", "items": {"type": "integer"}},
+		"units": {"type": "string", "default": "metric"},
+		"options": {
+			"type": "object",
+			"properties": {"limit": {"type": "integer", "default": 10}}
+		}
+	},
+	"additionalProperties": false
+}`
+
+func TestNormalizeFixesTrivialMismatches(t *testing.T) {
+	args := map[string]interface{}{
+		"days":    "5",
+		"ratio":   "0.5",
+		"metric":  "TRUE",
+		"zip":     94107.0,
+		"tags":    "3",
+		"options": map[string]interface{}{},
+		"color":   "red",
+	}
+
+	got, changes := Normalize(json.RawMessage(normalizeSchema), args)
+	want := map[string]interface{}{
+		"days":    5.0,
+		"ratio":   0.5,
+		"metric":  true,
+		"zip":     "94107",
+		"tags":    []interface{}{3.0},
+		"units":   "metric",
+		"options": map[string]interface{}{"limit": 10.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Normalize() = %#v, want %#v", got, want)
+	}
+	if errs := Validate(json.RawMessage(normalizeSchema), got); len(errs) > 0 {
+		t.Errorf("Expected normalized arguments to validate, got %v", errs)
+	}
+
+	actions := make(map[string]string)
+	for _, c := range changes {
+		actions[c.Field] = c.Action
+	}
+	if actions["days"] != "coerced" || actions["units"] != "defaulted" ||
+		actions["options.limit"] != "defaulted" || actions["color"] != "dropped" {
+		t.Errorf("Unexpected changes %v", changes)
+	}
+	if args["days"] != "5" {
+		t.Error("Expected the original arguments to be left alone")
+	}
+}
+
+func TestNormalizeLeavesLossyValues(t *testing.T) {
+	args := map[string]interface{}{"days": "five", "ratio": "1.5x", "metric": "yes"}
+
+	got, changes := Normalize(json.RawMessage(normalizeSchema), args)
+	if got["days"] != "five" || got["ratio"] != "1.5x" || got["metric"] != "yes" {
+		t.Errorf("Expected unconvertible values unchanged, got %v", got)
+	}
+	for _, c := range changes {
+		if c.Action == "coerced" {
+			t.Errorf("Unexpected coercion %v", c)
+		}
+	}
+	if errs := Validate(json.RawMessage(normalizeSchema), got); len(errs) != 3 {
+		t.Errorf("Expected validation to still report all three, got %v", errs)
+	}
+}
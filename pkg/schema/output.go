@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"encoding/json"
+)
+
+// ResultKey holds tool output that isn't a JSON object
+// MCP structuredContent must be an object, so other values are wrapped as {"result": ...}.
+const ResultKey = "result"
+
+// Structure returns tool output as an MCP structuredContent object
+// Returns nil when there is no output to structure.
+func Structure(output interface{}) map[string]interface{} {
+	switch v := output.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		return v
+	}
+	return map[string]interface{}{ResultKey: output}
+}
+
+// StructureText parses text output as JSON and structures it
+// Returns nil when the text isn't a JSON object or array; plain text stays text.
+func StructureText(text string) map[string]interface{} {
+	var output interface{}
+	if json.Unmarshal([]byte(text), &output) != nil {
+		return nil
+	}
+	switch output.(type) {
+	case map[string]interface{}, []interface{}:
+		return Structure(output)
+	}
+	return nil
+}
+
+// OutputSchema adapts a catalog output schema to describe what Structure returns
+// Returns nil when the schema is missing or isn't valid JSON.
+func OutputSchema(raw json.RawMessage) json.RawMessage {
+	var node map[string]interface{}
+	if len(raw) == 0 || json.Unmarshal(raw, &node) != nil {
+		return nil
+	}
+
+	types := schemaTypes(node["type"])
+	if len(types) == 1 && types[0] == "object" {
+		return raw
+	}
+	if len(types) == 0 && node["properties"] != nil {
+		node["type"] = "object"
+		return marshal(node)
+	}
+
+	return marshal(map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{ResultKey: node},
+		"required":   []string{ResultKey},
+	})
+}
+
+// marshal encodes a schema node built by this package
+func marshal(node map[string]interface{}) json.RawMessage {
+	data, err := json.Marshal(node)
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStructureWrapsNonObjects(t *testing.T) {
+	if got := Structure(map[string]interface{}{"a": 1.0}); got["a"] != 1.0 {
+		t.Errorf("Expected objects to be used as-is, got %v", got)
+	}
+	if got := Structure([]interface{}{"x"}); got[ResultKey] == nil {
+		t.Errorf("Expected arrays to be wrapped under %q, got %v", ResultKey, got)
+	}
+	if got := Structure(nil); got != nil {
+		t.Errorf("Expected no structured content for nil output, got %v", got)
+	}
+	if got := StructureText("plain text"); got != nil {
+		t.Errorf("Expected plain text to stay unstructured, got %v", got)
+	}
+	if got := StructureText(`{"temp": 21}`); got["temp"] != 21.0 {
+		t.Errorf("Expected JSON text to be structured, got %v", got)
+	}
+}
+
+func TestOutputSchemaWrapsNonObjectSchemas(t *testing.T) {
+	object := json.RawMessage(`{"type":"object","properties":{"temp":{"type":"number"}}}`)
+	if got := OutputSchema(object); string(got) != string(object) {
+		t.Errorf("Expected object schemas unchanged, got %s", got)
+	}
+
+	wrapped := OutputSchema(json.RawMessage(`{"type":"array","items":{"type":"string"}}`))
+	structured := Structure([]interface{}{"a", "b"})
+	if errs := Validate(wrapped, structured); len(errs) > 0 {
+		t.Errorf("Expected wrapped output to match wrapped schema %s, got %v", wrapped, errs)
+	}
+
+	if got := OutputSchema(nil); got != nil {
+		t.Errorf("Expected no schema, got %s", got)
+	}
+}
@@ -0,0 +1,234 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Sanitize makes a catalog input schema JSON Schema 2020-12 compliant
+// Fixes:
+// 1. "required": true inside properties (moves to top-level required array)
+// 2. Default values with wrong type (e.g., "3600" string → 3600 integer)
+// 3. Default values not in enum list (sets to first enum value)
+// Tools are listed, and their arguments validated, with the sanitized schema.
+func Sanitize(parametersJSON json.RawMessage) json.RawMessage {
+	if len(parametersJSON) == 0 {
+		return parametersJSON
+	}
+
+	// Parse the schema into a map
+	var root map[string]interface{}
+	if err := json.Unmarshal(parametersJSON, &root); err != nil {
+		// If parse fails, return as-is
+		return parametersJSON
+	}
+
+	// Extract properties and find fields with "required": true
+	properties, ok := root["properties"].(map[string]interface{})
+	if !ok {
+		// No properties to fix
+		return parametersJSON
+	}
+
+	// Track which fields should be in the required array
+	requiredFields := []string{}
+
+	// Check existing required array
+	if existingRequired, ok := root["required"].([]interface{}); ok {
+		for _, field := range existingRequired {
+			if fieldStr, ok := field.(string); ok {
+				requiredFields = append(requiredFields, fieldStr)
+			}
+		}
+	}
+
+	// Process each property
+	for propName, propValue := range properties {
+		propMap, ok := propValue.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// Check for "required": true inside property
+		if required, exists := propMap["required"]; exists {
+			// Remove the invalid "required" from property
+			delete(propMap, "required")
+
+			// If it was true, add to required array (avoid duplicates)
+			if requiredBool, ok := required.(bool); ok && requiredBool {
+				found := false
+				for _, existing := range requiredFields {
+					if existing == propName {
+						found = true
+						break
+					}
+				}
+				if !found {
+					requiredFields = append(requiredFields, propName)
+				}
+			}
+		}
+
+		// Fix default value types based on the "type" field
+		if defaultValue, hasDefault := propMap["default"]; hasDefault {
+			if typeStr, hasType := propMap["type"].(string); hasType {
+				propMap["default"] = convertDefaultValueType(defaultValue, typeStr)
+			}
+		}
+
+		// Validate enum default values - if default is not in enum, fix it
+		if enumValues, hasEnum := propMap["enum"].([]interface{}); hasEnum && len(enumValues) > 0 {
+			if defaultValue, hasDefault := propMap["default"]; hasDefault {
+				// Check if default is in the enum list
+				defaultStr := fmt.Sprintf("%v", defaultValue)
+				found := false
+				for _, enumVal := range enumValues {
+					if fmt.Sprintf("%v", enumVal) == defaultStr {
+						found = true
+						break
+					}
+				}
+				// If not found, set to first enum value
+				if !found {
+					propMap["default"] = enumValues[0]
+				}
+			}
+		}
+
+		// Recursively fix nested object properties
+		if propMap["type"] == "object" {
+			if nestedProps, ok := propMap["properties"].(map[string]interface{}); ok {
+				fixNestedProperties(nestedProps)
+			}
+		}
+
+		// Fix arrays with item schemas
+		if propMap["type"] == "array" {
+			if items, ok := propMap["items"].(map[string]interface{}); ok {
+				// Fix default value in items if present
+				if itemDefault, hasDefault := items["default"]; hasDefault {
+					if itemType, hasType := items["type"].(string); hasType {
+						items["default"] = convertDefaultValueType(itemDefault, itemType)
+					}
+				}
+				// Validate enum in array items
+				if enumValues, hasEnum := items["enum"].([]interface{}); hasEnum && len(enumValues) > 0 {
+					if defaultValue, hasDefault := items["default"]; hasDefault {
+						defaultStr := fmt.Sprintf("%v", defaultValue)
+						found := false
+						for _, enumVal := range enumValues {
+							if fmt.Sprintf("%v", enumVal) == defaultStr {
+								found = true
+								break
+							}
+						}
+						if !found {
+							items["default"] = enumValues[0]
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Update the schema with cleaned properties and required array
+	root["properties"] = properties
+	if len(requiredFields) > 0 {
+		root["required"] = requiredFields
+	}
+
+	// Marshal back to JSON
+	sanitized, err := json.Marshal(root)
+	if err != nil {
+		// If marshal fails, return original
+		return parametersJSON
+	}
+
+	return json.RawMessage(sanitized)
+}
+
+// convertDefaultValueType converts a default value to match its declared type
+func convertDefaultValueType(value interface{}, typeStr string) interface{} {
+	switch typeStr {
+	case "integer":
+		// Convert string to integer if needed
+		if strVal, ok := value.(string); ok {
+			var intVal int
+			fmt.Sscanf(strVal, "%d", &intVal)
+			return intVal
+		}
+		// Already an integer or float, ensure it's int
+		if floatVal, ok := value.(float64); ok {
+			return int(floatVal)
+		}
+		return value
+
+	case "number":
+		// Convert string to float if needed
+		if strVal, ok := value.(string); ok {
+			var floatVal float64
+			fmt.Sscanf(strVal, "%f", &floatVal)
+			return floatVal
+		}
+		return value
+
+	case "boolean":
+		// Convert string to boolean if needed
+		if strVal, ok := value.(string); ok {
+			return strVal == "true" || strVal == "True" || strVal == "TRUE"
+		}
+		return value
+
+	case "string":
+		// Ensure it's a string
+		if strVal, ok := value.(string); ok {
+			return strVal
+		}
+		// Convert other types to string
+		return fmt.Sprintf("%v", value)
+
+	default:
+		return value
+	}
+}
+
+// fixNestedProperties recursively fixes nested object properties
+func fixNestedProperties(properties map[string]interface{}) {
+	for _, propValue := range properties {
+		propMap, ok := propValue.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// Fix default value types
+		if defaultValue, hasDefault := propMap["default"]; hasDefault {
+			if typeStr, hasType := propMap["type"].(string); hasType {
+				propMap["default"] = convertDefaultValueType(defaultValue, typeStr)
+			}
+		}
+
+		// Validate enum default values
+		if enumValues, hasEnum := propMap["enum"].([]interface{}); hasEnum && len(enumValues) > 0 {
+			if defaultValue, hasDefault := propMap["default"]; hasDefault {
+				defaultStr := fmt.Sprintf("%v", defaultValue)
+				found := false
+				for _, enumVal := range enumValues {
+					if fmt.Sprintf("%v", enumVal) == defaultStr {
+						found = true
+						break
+					}
+				}
+				if !found {
+					propMap["default"] = enumValues[0]
+				}
+			}
+		}
+
+		// Recurse for nested objects
+		if propMap["type"] == "object" {
+			if nestedProps, ok := propMap["properties"].(map[string]interface{}); ok {
+				fixNestedProperties(nestedProps)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSanitizeFixesCatalogSchemas(t *testing.T) {
+	raw := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string", "required": true},
+			"days": {"type": "integer", "default": "3"},
+			"units": {"type": "string", "enum": ["metric", "imperial"], "default": "kelvin"}
+		}
+	}`)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(Sanitize(raw), &got); err != nil {
+		t.Fatalf("Invalid sanitized schema: %v", err)
+	}
+	properties := got["properties"].(map[string]interface{})
+	if required, _ := got["required"].([]interface{}); len(required) != 1 || required[0] != "city" {
+		t.Errorf("Expected city to be required, got %v", got["required"])
+	}
+	if _, ok := properties["city"].(map[string]interface{})["required"]; ok {
+		t.Error("Expected the per-property required flag to be removed")
+	}
+	if def := properties["days"].(map[string]interface{})["default"]; def != 3.0 {
+		t.Errorf("Expected default 3, got %#v", def)
+	}
+	if def := properties["units"].(map[string]interface{})["default"]; def != "metric" {
+		t.Errorf("Expected the first enum value as default, got %#v", def)
+	}
+}
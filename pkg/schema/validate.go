@@ -0,0 +1,244 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FieldError describes one argument that doesn't match the tool's input schema
+type FieldError struct {
+	Field   string `json:"field"` // Dotted path, e.g. "options.limit" or "items[2]"
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// Validate checks args against a JSON Schema and returns every mismatch
+// Supports the keywords the catalog uses: type, properties, required (array or
+// the legacy per-property boolean), enum, items, additionalProperties,
+// minimum/maximum, minLength/maxLength and pattern. Unknown keywords are ignored.
+// An object that lists its properties rejects any other unless
+// additionalProperties allows them, so a misspelled argument is never sent.
+func Validate(rawSchema json.RawMessage, args map[string]interface{}) []FieldError {
+	return validate(rawSchema, args, true)
+}
+
+// ValidateOutput checks structured tool output against an output schema
+// Unlike arguments, output may carry properties its schema doesn't list.
+func ValidateOutput(rawSchema json.RawMessage, structured map[string]interface{}) []FieldError {
+	return validate(rawSchema, structured, false)
+}
+
+// validate checks an object against a schema; strict rejects undeclared properties
+func validate(rawSchema json.RawMessage, obj map[string]interface{}, strict bool) []FieldError {
+	var root map[string]interface{}
+	if len(rawSchema) == 0 || json.Unmarshal(rawSchema, &root) != nil {
+		return nil // No usable schema: nothing to check against
+	}
+
+	var value interface{} = obj
+	if obj == nil {
+		value = map[string]interface{}{}
+	}
+
+	var errs []FieldError
+	validateValue(root, value, "", strict, &errs)
+	return errs
+}
+
+// validateValue checks one value against its schema node, appending to errs
+func validateValue(node map[string]interface{}, value interface{}, path string, strict bool, errs *[]FieldError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if types := schemaTypes(node["type"]); len(types) > 0 && !matchesAny(value, types) {
+		fail("expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return // Other keywords don't apply to a value of the wrong type
+	}
+
+	if enum, ok := node["enum"].([]interface{}); ok && !inEnum(value, enum) {
+		fail("must be one of %s", formatEnum(enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(node, v, path, strict, errs)
+	case []interface{}:
+		if items, ok := node["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), strict, errs)
+			}
+		}
+	case string:
+		if n, ok := number(node["minLength"]); ok && float64(len([]rune(v))) < n {
+			fail("must be at least %v characters", n)
+		}
+		if n, ok := number(node["maxLength"]); ok && float64(len([]rune(v))) > n {
+			fail("must be at most %v characters", n)
+		}
+		if pattern, ok := node["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("must match pattern %s", pattern)
+			}
+		}
+	case float64:
+		if n, ok := number(node["minimum"]); ok && v < n {
+			fail("must be >= %v", n)
+		}
+		if n, ok := number(node["maximum"]); ok && v > n {
+			fail("must be <= %v", n)
+		}
+	}
+}
+
+// validateObject checks required, properties and additionalProperties
+func validateObject(node map[string]interface{}, obj map[string]interface{}, path string, strict bool, errs *[]FieldError) {
+	properties, _ := node["properties"].(map[string]interface{})
+
+	required := make(map[string]bool)
+	if list, ok := node["required"].([]interface{}); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+	// Older catalog entries mark required fields with "required": true on the property
+	for name, prop := range properties {
+		if p, ok := prop.(map[string]interface{}); ok && p["required"] == true {
+			required[name] = true
+		}
+	}
+
+	for _, name := range sortedKeys(required) {
+		if _, present := obj[name]; !present {
+			*errs = append(*errs, FieldError{Field: join(path, name), Message: "is required"})
+		}
+	}
+
+	for _, name := range sortedKeys(obj) {
+		prop, known := properties[name].(map[string]interface{})
+		if !known {
+			switch extra := node["additionalProperties"].(type) {
+			case map[string]interface{}:
+				validateValue(extra, obj[name], join(path, name), strict, errs)
+			case bool:
+				if !extra {
+					*errs = append(*errs, FieldError{Field: join(path, name), Message: "is not a known parameter"})
+				}
+			default:
+				if _, listed := node["properties"]; listed && strict {
+					*errs = append(*errs, FieldError{Field: join(path, name), Message: "is not a known parameter"})
+				}
+			}
+			continue
+		}
+		validateValue(prop, obj[name], join(path, name), strict, errs)
+	}
+}
+
+// schemaTypes reads "type" as a string or a list of strings
+func schemaTypes(t interface{}) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var types []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// matchesAny reports whether value has one of the JSON Schema types
+func matchesAny(value interface{}, types []string) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON Schema type name of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// inEnum compares by JSON encoding so numbers and strings match as JSON does
+func inEnum(value interface{}, enum []interface{}) bool {
+	want, _ := json.Marshal(value)
+	for _, option := range enum {
+		got, _ := json.Marshal(option)
+		if string(got) == string(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatEnum lists enum options for error messages
+func formatEnum(enum []interface{}) string {
+	options := make([]string, len(enum))
+	for i, option := range enum {
+		data, _ := json.Marshal(option)
+		options[i] = string(data)
+	}
+	return "[" + strings.Join(options, ", ") + "]"
+}
+
+// number reads a numeric schema keyword
+func number(v interface{}) (float64, bool) {
+	n, ok := v.(float64)
+	return n, ok
+}
+
+// join builds a dotted field path
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// sortedKeys returns map keys in order so errors are reported deterministically
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
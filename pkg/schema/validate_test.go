@@ -0,0 +1,84 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"city": {"type": "string", "minLength": 2},
+		"units": {"type": "string", "enum": ["metric", "imperial"]},
+		"days": {"type": "integer", "minimum": 1, "maximum": 14},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"legacy": {"type": "string", "required": true}
+	},
+	"required": ["city"],
+	"additionalProperties": false
+}`
+
+func TestValidateAcceptsValidArguments(t *testing.T) {
+	args := map[string]interface{}{"city": "Oslo", "units": "metric", "days": 3.0, "legacy": "x"}
+	if errs := Validate(json.RawMessage(testSchema), args); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+}
+
+func TestValidateReportsEveryField(t *testing.T) {
+	args := map[string]interface{}{
+		"units": "kelvin",
+		"days":  2.5,
+		"tags":  []interface{}{"a", 3.0},
+		"extra": true,
+	}
+
+	errs := Validate(json.RawMessage(testSchema), args)
+
+	want := map[string]bool{"city": true, "legacy": true, "units": true, "days": true, "tags[1]": true, "extra": true}
+	got := make(map[string]bool)
+	for _, e := range errs {
+		got[e.Field] = true
+	}
+	for field := range want {
+		if !got[field] {
+			t.Errorf("Expected an error for %s, got %v", field, errs)
+		}
+	}
+	if len(errs) != len(want) {
+		t.Errorf("Expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+}
+
+func TestValidateWithoutSchema(t *testing.T) {
+	if errs := Validate(nil, map[string]interface{}{"anything": 1.0}); errs != nil {
+		t.Errorf("Expected no errors without a schema, got %v", errs)
+	}
+}
+
+func TestValidateRejectsUnlistedProperties(t *testing.T) {
+	listed := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string"},
+			"options": {"type": "object"},
+			"labels": {"type": "object", "properties": {}, "additionalProperties": {"type": "string"}}
+		}
+	}`)
+	args := map[string]interface{}{
+		"city":    "Oslo",
+		"colour":  "red",
+		"options": map[string]interface{}{"anything": true},
+		"labels":  map[string]interface{}{"a": "x", "b": 1.0},
+	}
+
+	errs := Validate(listed, args)
+	if len(errs) != 2 || errs[0].Field != "colour" || errs[1].Field != "labels.b" {
+		t.Errorf("Expected errors for colour and labels.b only, got %v", errs)
+	}
+
+	// Output may carry more than its schema lists
+	if errs := ValidateOutput(listed, map[string]interface{}{"city": "Oslo", "extra": 1.0}); len(errs) != 0 {
+		t.Errorf("Expected extra output properties to be accepted, got %v", errs)
+	}
+}
@@ -0,0 +1,145 @@
+package toolset
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Rules include or exclude catalog tools (zero value: every tool)
+// Each pattern matches a product ID or an MCP tool name, case-insensitively,
+// and may use glob wildcards ("weather-*", "*-pro", "68a1f?*").
+type Rules struct {
+	Allow []string `json:"allow,omitempty"` // Only these tools (empty = all)
+	Deny  []string `json:"deny,omitempty"`  // Never these tools, even if allowed
+}
+
+// Enabled reports whether any rule is configured
+func (r Rules) Enabled() bool {
+	return len(r.Allow) > 0 || len(r.Deny) > 0
+}
+
+// Allowed reports whether the tool passes the rules
+func (r Rules) Allowed(productID, name string) bool {
+	if len(r.Allow) > 0 && !matchAny(r.Allow, productID, name) {
+		return false
+	}
+	return !matchAny(r.Deny, productID, name)
+}
+
+// validate reports the first malformed pattern
+func (r Rules) validate() error {
+	for _, pattern := range append(append([]string{}, r.Allow...), r.Deny...) {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Filter decides which catalog tools a server exposes
+// A tool must pass the base rules and those of the selected tool set.
+// A nil *Filter exposes every tool.
+type Filter struct {
+	rules []Rules
+	name  string // Selected tool set ("" = none)
+}
+
+// New combines the base rules with the named tool set from sets
+// Returns nil when nothing would be filtered.
+func New(base Rules, sets map[string]Rules, selected string) (*Filter, error) {
+	f := &Filter{name: selected}
+	if base.Enabled() {
+		if err := base.validate(); err != nil {
+			return nil, err
+		}
+		f.rules = append(f.rules, base)
+	}
+
+	if selected != "" {
+		set, ok := sets[selected]
+		if !ok {
+			return nil, fmt.Errorf("unknown tool set %q (known: %s)", selected, strings.Join(Names(sets), ", "))
+		}
+		if err := set.validate(); err != nil {
+			return nil, fmt.Errorf("tool set %q: %w", selected, err)
+		}
+		f.rules = append(f.rules, set)
+	}
+
+	if len(f.rules) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Allowed reports whether the tool with this product ID and MCP name is exposed
+func (f *Filter) Allowed(productID, name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, r := range f.rules {
+		if !r.Allowed(productID, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the filter for logs
+func (f *Filter) String() string {
+	if f == nil {
+		return "all tools"
+	}
+	var parts []string
+	if f.name != "" {
+		parts = append(parts, fmt.Sprintf("tool set %q", f.name))
+	}
+	for _, r := range f.rules {
+		if len(r.Allow) > 0 {
+			parts = append(parts, "allow "+strings.Join(r.Allow, ","))
+		}
+		if len(r.Deny) > 0 {
+			parts = append(parts, "deny "+strings.Join(r.Deny, ","))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Names returns the tool set names in order
+func Names(sets map[string]Rules) []string {
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseList splits a comma-separated pattern list, as used by flags and env vars
+func ParseList(s string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(s, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// matchAny reports whether any pattern matches the product ID or the name
+func matchAny(patterns []string, productID, name string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		for _, candidate := range []string{productID, name} {
+			if candidate == "" {
+				continue
+			}
+			if ok, _ := path.Match(pattern, strings.ToLower(candidate)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
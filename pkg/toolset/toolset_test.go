@@ -0,0 +1,65 @@
+package toolset
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRulesAllowed(t *testing.T) {
+	rules := Rules{
+		Allow: []string{"weather-*", "68a1f0000000000000beef01"},
+		Deny:  []string{"*-pro"},
+	}
+
+	tests := []struct {
+		productID, name string
+		want            bool
+	}{
+		{"p1", "weather-check", true},
+		{"p2", "Weather-Forecast", true}, // Case-insensitive
+		{"68a1f0000000000000beef01", "news", true},
+		{"p3", "weather-pro", false}, // Denied even though allowed
+		{"p4", "news", false},        // Not allowed
+	}
+	for _, tt := range tests {
+		if got := rules.Allowed(tt.productID, tt.name); got != tt.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", tt.productID, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewCombinesBaseAndToolSet(t *testing.T) {
+	sets := map[string]Rules{
+		"research": {Allow: []string{"search-*", "weather-*"}},
+		"billing":  {Allow: []string{"invoice-*"}},
+	}
+
+	f, err := New(Rules{Deny: []string{"weather-*"}}, sets, "research")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !f.Allowed("p1", "search-web") || f.Allowed("p2", "weather-check") || f.Allowed("p3", "invoice-create") {
+		t.Errorf("Expected only search tools to pass, got %s", f)
+	}
+
+	if f, err := New(Rules{}, sets, ""); err != nil || f != nil {
+		t.Errorf("Expected no filter without rules, got %v, %v", f, err)
+	}
+	if !(*Filter)(nil).Allowed("p1", "anything") {
+		t.Error("Expected a nil filter to allow everything")
+	}
+
+	if _, err := New(Rules{}, sets, "missing"); err == nil {
+		t.Error("Expected an error for an unknown tool set")
+	}
+	if _, err := New(Rules{Allow: []string{"[bad"}}, nil, ""); err == nil {
+		t.Error("Expected an error for a malformed pattern")
+	}
+}
+
+func TestParseList(t *testing.T) {
+	got := ParseList(" weather-*, ,news ")
+	if want := []string{"weather-*", "news"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseList() = %v, want %v", got, want)
+	}
+}
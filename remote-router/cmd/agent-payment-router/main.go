@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/cache"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/naming"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/config"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/mcp"
+)
+
+var Version = "dev" // Set by -ldflags at build time
+
+func main() {
+	toolSet := flag.String("toolset", "", "Named tool set from config.json to expose (overrides AGENTPMT_TOOLSET)")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "\nPlease ensure:\n")
+		fmt.Fprintf(os.Stderr, "  1. config.json exists next to the binary, OR\n")
+		fmt.Fprintf(os.Stderr, "  2. Environment variables are set:\n")
+		fmt.Fprintf(os.Stderr, "     AGENTPMT_API_KEY\n")
+		fmt.Fprintf(os.Stderr, "     AGENTPMT_BUDGET_KEY\n")
+		fmt.Fprintf(os.Stderr, "     AGENTPMT_API_URL (optional, defaults to https://api.agentpmt.com)\n")
+		os.Exit(1)
+	}
+
+	// Setup logging with secret redaction
+	mcp.SetupLogging(cfg.APIKey, cfg.BudgetKey)
+
+	log.Printf("AgentPMT MCP Router v%s starting...", Version)
+	log.Printf("API URL: %s", cfg.APIURL)
+	log.Printf("Configuration loaded (keys: %s, %s)", redact(cfg.APIKey), redact(cfg.BudgetKey))
+
+	// Create API client
+	apiClient := api.NewClient(cfg.APIURL, cfg.APIKey, cfg.BudgetKey)
+
+	// Create MCP server
+	server := mcp.NewServer(apiClient, Version)
+	server.SetMaxConcurrency(cfg.MaxConcurrency)
+	refreshInterval, _ := cfg.RefreshDuration() // Validated by config.Load
+	server.SetRefreshInterval(refreshInterval)
+
+	// Only the selected tools are listed and callable
+	if *toolSet != "" {
+		cfg.ToolSet = *toolSet
+	}
+	toolFilter, err := cfg.ToolFilter()
+	if err != nil {
+		log.Fatalf("Invalid tool selection: %v", err)
+	}
+	server.SetToolFilter(toolFilter)
+	if toolFilter != nil {
+		log.Printf("Exposing tools: %s", toolFilter)
+	}
+
+	// Local titles, annotations and costs for tools the catalog doesn't describe well
+	overridesFile := cfg.OverridesFile
+	if overridesFile == "" {
+		overridesFile = annotation.DefaultPath()
+	}
+	overrides, err := annotation.Load(overridesFile)
+	if err != nil {
+		log.Fatalf("Invalid tool overrides: %v", err)
+	}
+	server.SetOverrides(overrides)
+	if len(overrides) > 0 {
+		log.Printf("Loaded %d tool overrides from %s", len(overrides), overridesFile)
+	}
+
+	// A team's own prompt templates, served alongside the built-in ones
+	promptsDir := cfg.PromptsDir
+	if promptsDir == "" {
+		promptsDir = prompt.DefaultDir()
+	}
+	prompts, err := prompt.LoadDir(promptsDir)
+	if err != nil {
+		log.Fatalf("Invalid prompt templates: %v", err)
+	}
+	server.SetPrompts(prompts)
+	if prompts.Len() > 0 {
+		log.Printf("Loaded %d prompt templates from %s", prompts.Len(), promptsDir)
+	}
+
+	// Keep tool names from earlier runs, even when two tools' descriptions collide
+	if err := server.SetNameRegistry(naming.DefaultPath()); err != nil {
+		log.Printf("Warning: starting with an empty name registry: %v", err)
+	}
+
+	// Start from the last good catalog instead of fetching every page on every tools/list
+	server.SetCache(cache.DefaultPath(), cache.Fingerprint(cfg.APIKey, cfg.BudgetKey))
+	if err := server.LoadCache(); err != nil {
+		log.Printf("No catalog cache loaded: %v", err)
+	}
+
+	// Local spend caps, checked before every purchase
+	limits := cfg.SpendLimits()
+	spendLedger, err := ledger.New(limits, ledger.DefaultPath())
+	if err != nil {
+		log.Fatalf("Failed to open spend ledger: %v", err)
+	}
+	server.SetLedger(spendLedger)
+	if limits.Enabled() {
+		log.Printf("Local spend caps: session=%.2f hour=%.2f day=%.2f per-tool=%d (0 = none)",
+			limits.Session, limits.Hour, limits.Day, len(limits.PerTool))
+	}
+
+	// Purchases that need the user's approval first
+	rules := cfg.ApprovalRules()
+	server.SetApproval(rules)
+	if rules.Enabled() {
+		log.Printf("Purchase approval: always=%v above=%.2f tools=%v", rules.Always, rules.Above, rules.Tools)
+	}
+
+	// Images, audio and files in tool output become MCP content blocks
+	server.SetOutputDir(cfg.OutputDir)
+
+	// Opt-in: fix trivially mistyped arguments instead of rejecting them
+	server.SetNormalizeArguments(cfg.NormalizeArguments)
+	if cfg.NormalizeArguments {
+		log.Printf("Argument normalization enabled")
+	}
+
+	// SIGHUP re-fetches the tool catalog without restarting
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			server.TriggerRefresh()
+		}
+	}()
+
+	log.Printf("MCP server ready, listening on stdio...")
+
+	// Run stdio transport (blocks until stdin closes)
+	if err := server.HandleStdioTransport(); err != nil {
+		log.Fatalf("Transport error: %v", err)
+	}
+
+	log.Printf("MCP server shutting down")
+}
+
+// redact masks a secret for display
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	if len(s) <= 8 {
+		return "***"
+	}
+	return s[:4] + "***" + s[len(s)-4:]
+}
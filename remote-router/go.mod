@@ -2,4 +2,8 @@ module github.com/Apoth3osis-ai/agent-payment-mcp/remote-router
 
 go 1.23
 
+require github.com/Apoth3osis-ai/agent-payment-mcp/pkg v0.0.0
+
 require github.com/tmaxmax/go-sse v0.11.0 // indirect
+
+replace github.com/Apoth3osis-ai/agent-payment-mcp/pkg => ../pkg
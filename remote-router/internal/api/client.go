@@ -1,39 +1,38 @@
 package api
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
-	"io"
+	"log"
 	"net/http"
 	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
 )
 
 // DefaultUA is the User-Agent header sent with all requests
 const DefaultUA = "AgentPMT-MCP/1.0"
 
-// API endpoint paths
-const (
-	FetchEndpoint    = "/products/fetch"
-	PurchaseEndpoint = "/products/purchase"
-)
-
 // Client handles HTTP communication with AgentPMT API
+// It adapts the public agentpmt client to the types the router uses.
 type Client struct {
 	baseURL   string
 	apiKey    string
 	budgetKey string
 	http      *http.Client
+	api       *agentpmt.Client
 }
 
-// NewClient creates a new API client with proper timeouts and headers
-func NewClient(baseURL, apiKey, budgetKey string) *Client {
+// NewClient creates a new API client with proper timeouts and headers;
+// opts override its defaults
+func NewClient(baseURL, apiKey, budgetKey string, opts ...agentpmt.Option) *Client {
 	if baseURL == "" {
-		baseURL = "https://api.agentpmt.com"
+		baseURL = agentpmt.DefaultBaseURL
 	}
 
-	return &Client{
+	c := &Client{
 		baseURL:   baseURL,
 		apiKey:    apiKey,
 		budgetKey: budgetKey,
@@ -47,16 +46,14 @@ func NewClient(baseURL, apiKey, budgetKey string) *Client {
 			},
 		},
 	}
-}
 
-// do executes an HTTP request with standard headers
-func (c *Client) do(req *http.Request) (*http.Response, error) {
-	req.Header.Set("User-Agent", DefaultUA)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", c.apiKey)
-	req.Header.Set("X-Budget-Key", c.budgetKey)
-
-	return c.http.Do(req)
+	opts = append([]agentpmt.Option{
+		agentpmt.WithHTTPClient(c.http),
+		agentpmt.WithBaseURL(baseURL),
+		agentpmt.WithUserAgent(DefaultUA),
+	}, opts...)
+	c.api = agentpmt.New(apiKey, budgetKey, opts...)
+	return c
 }
 
 // ToolDefinition represents a tool with raw JSON schema
@@ -64,30 +61,42 @@ type ToolDefinition struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	Parameters  json.RawMessage `json:"parameters"` // Raw JSON schema
+	Pricing     *Pricing        `json:"pricing,omitempty"`
+
+	// Annotations are the tool's MCP annotations, when the catalog publishes them
+	Annotations *annotation.Hints `json:"annotations,omitempty"`
+
+	// Examples are sample calls, turned into a usage prompt per tool
+	Examples []prompt.Example `json:"examples,omitempty"`
+
+	// OutputSchema describes the tool's output, when the catalog publishes one
+	OutputSchema json.RawMessage `json:"output_schema,omitempty"`
 }
 
+// Pricing is the catalog's advertised price for a tool
+type Pricing = agentpmt.Pricing
+
 // APIToolWrapper wraps the tool in the API response format
 type APIToolWrapper struct {
-	Type     string          `json:"type"`
-	Function FunctionDef     `json:"function"`
+	Type     string      `json:"type"`
+	Function FunctionDef `json:"function"`
+	Pricing  *Pricing    `json:"x-pricing,omitempty"` // Catalog price, when published
+
+	// Annotations are the tool's MCP annotations, when the catalog publishes them
+	Annotations *annotation.Hints `json:"x-annotations,omitempty"`
+
+	// Examples are sample calls, turned into a usage prompt per tool
+	Examples []prompt.Example `json:"x-examples,omitempty"`
+
+	// OutputSchema describes the tool's output, when the catalog publishes one
+	OutputSchema json.RawMessage `json:"x-output-schema,omitempty"`
 }
 
 // FunctionDef is the function inside the API tool wrapper
-type FunctionDef struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description"`
-	Parameters  json.RawMessage `json:"parameters"`
-}
+type FunctionDef = agentpmt.Function
 
 // PaginationDetails contains pagination metadata
-type PaginationDetails struct {
-	ToolsOnThisPage int  `json:"tools_on_this_page"`
-	TotalTools      int  `json:"total_qualified_tools"`
-	PageReturned    int  `json:"page_returned"`
-	PageSize        int  `json:"page_size_requested"`
-	TotalPages      int  `json:"total_pages"`
-	HasNextPage     bool `json:"has_next_page"`
-}
+type PaginationDetails = agentpmt.PaginationDetails
 
 // FetchToolsResponse is the response from /products/fetch
 type FetchToolsResponse struct {
@@ -98,112 +107,92 @@ type FetchToolsResponse struct {
 }
 
 // FetchTools retrieves ALL available tools from the API (handles pagination automatically)
+// API failures wrap an agentpmt.Error, classified by status and body.
 func (c *Client) FetchTools(ctx context.Context) ([]ToolDefinition, error) {
-	var allTools []ToolDefinition
-	page := 1
-	pageSize := 50 // Request 50 tools per page
-
-	for {
-		// Build URL with pagination
-		url := fmt.Sprintf("%s%s?page=%d&page_size=%d", c.baseURL, FetchEndpoint, page, pageSize)
-
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		resp, err := c.do(req)
-		if err != nil {
-			return nil, fmt.Errorf("request failed: %w", err)
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
-
-		if resp.StatusCode/100 != 2 {
-			return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-		}
-
-		var out FetchToolsResponse
-		if err := json.Unmarshal(body, &out); err != nil {
-			return nil, fmt.Errorf("failed to parse response: %w", err)
-		}
+	catalog, err := c.api.FetchCatalog(ctx, 50) // Request 50 tools per page
+	if err != nil {
+		return nil, err
+	}
+	if catalog.Truncated {
+		log.Printf("Warning: catalog truncated at the page limit: fetched %d tools, the API reports %d", len(catalog.Tools), catalog.TotalTools)
+	}
 
-		if !out.Success {
-			return nil, fmt.Errorf("API error: %s", out.Error)
-		}
+	return ToolDefinitions(catalog.Tools), nil
+}
 
-		// Unwrap tools from API format to our format
-		for _, wrapper := range out.Tools {
-			allTools = append(allTools, ToolDefinition{
-				Name:        wrapper.Function.Name,
-				Description: wrapper.Function.Description,
-				Parameters:  wrapper.Function.Parameters,
-			})
+// ToolDefinitions unwraps catalog entries from the API format to ours
+func ToolDefinitions(tools []agentpmt.Tool) []ToolDefinition {
+	allTools := make([]ToolDefinition, 0, len(tools))
+	for _, tool := range tools {
+		var examples []prompt.Example
+		for _, example := range tool.Examples {
+			examples = append(examples, prompt.Example(example))
 		}
+		allTools = append(allTools, ToolDefinition{
+			Name:         tool.Function.Name,
+			Description:  tool.Function.Description,
+			Parameters:   tool.Function.Parameters,
+			Pricing:      tool.Pricing,
+			OutputSchema: tool.OutputSchema,
+			Annotations:  (*annotation.Hints)(tool.Annotations),
+			Examples:     examples,
+		})
+	}
+	return allTools
+}
 
-		// Check if there are more pages
-		if !out.Details.HasNextPage {
-			break
+// CatalogTools wraps tools back into the API format, e.g. for the catalog cache
+func CatalogTools(tools []ToolDefinition) []agentpmt.Tool {
+	wrapped := make([]agentpmt.Tool, 0, len(tools))
+	for _, tool := range tools {
+		var examples []agentpmt.Example
+		for _, example := range tool.Examples {
+			examples = append(examples, agentpmt.Example(example))
 		}
-
-		page++
+		wrapped = append(wrapped, agentpmt.Tool{
+			Type: "function",
+			Function: agentpmt.Function{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+			Pricing:      tool.Pricing,
+			OutputSchema: tool.OutputSchema,
+			Annotations:  (*agentpmt.Annotations)(tool.Annotations),
+			Examples:     examples,
+		})
 	}
-
-	return allTools, nil
+	return wrapped
 }
 
 // PurchaseRequest is the request body for /products/purchase
-type PurchaseRequest struct {
-	ProductID  string          `json:"product_id"`
-	Parameters json.RawMessage `json:"parameters"`
-}
+type PurchaseRequest = agentpmt.PurchaseRequest
 
 // PurchaseResponse is the response from /products/purchase
 type PurchaseResponse struct {
-	Success bool   `json:"success"`
-	Output  string `json:"output,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success bool     `json:"success"`
+	Output  string   `json:"output,omitempty"`
+	Cost    *float64 `json:"cost,omitempty"` // Charged amount, when the API reports it
+	Error   string   `json:"error,omitempty"`
+
+	// PurchaseDetails is the receipt, kept in the spend ledger as reported
+	PurchaseDetails json.RawMessage `json:"purchase_details,omitempty"`
 }
 
 // Purchase executes a tool synchronously
+// Transient failures are retried under one Idempotency-Key, so the purchase
+// is charged at most once. Failures wrap an agentpmt.Error.
 func (c *Client) Purchase(ctx context.Context, req PurchaseRequest) (*PurchaseResponse, error) {
-	payload, err := json.Marshal(req)
+	resp, err := c.api.Purchase(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+PurchaseEndpoint, bytes.NewReader(payload))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := c.do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var out PurchaseResponse
-	if err := json.Unmarshal(body, &out); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	if !out.Success {
-		return nil, fmt.Errorf("purchase failed: %s", out.Error)
+		return nil, err
 	}
 
-	return &out, nil
+	return &PurchaseResponse{
+		Success:         resp.Success,
+		Output:          resp.OutputText(),
+		Cost:            resp.Cost,
+		Error:           resp.Error,
+		PurchaseDetails: resp.PurchaseDetails,
+	}, nil
 }
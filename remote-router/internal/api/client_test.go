@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
 )
 
 func TestNewClient(t *testing.T) {
@@ -48,11 +50,14 @@ func TestFetchTools(t *testing.T) {
 		// Return mock response
 		resp := FetchToolsResponse{
 			Success: true,
-			Tools: []ToolDefinition{
+			Tools: []APIToolWrapper{
 				{
-					Name:        "test-tool",
-					Description: "A test tool",
-					Parameters:  json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}}}`),
+					Type: "function",
+					Function: FunctionDef{
+						Name:        "test-tool",
+						Description: "A test tool",
+						Parameters:  json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}}}`),
+					},
 				},
 			},
 		}
@@ -192,6 +197,55 @@ func TestPurchaseTimeout(t *testing.T) {
 	}
 }
 
+func TestPurchaseRetriesWithSameIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(agentpmt.IdempotencyHeader))
+		if len(keys) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(PurchaseResponse{Success: true, Output: "done"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-key", "test-budget",
+		agentpmt.WithRetry(agentpmt.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}))
+
+	req := PurchaseRequest{ProductID: "test", Parameters: json.RawMessage(`{}`)}
+	if _, err := client.Purchase(context.Background(), req); err != nil {
+		t.Fatalf("Purchase() failed: %v", err)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Expected 3 attempts sharing one key, got %q", keys)
+	}
+
+	// Client errors aren't retried
+	keys = nil
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(agentpmt.IdempotencyHeader))
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	if _, err := client.Purchase(context.Background(), req); err == nil || len(keys) != 1 {
+		t.Errorf("Expected one attempt for a 400, got %d (%v)", len(keys), err)
+	}
+
+	// Gateway errors are: the key stops the API from charging twice
+	keys = nil
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(agentpmt.IdempotencyHeader))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(PurchaseResponse{Success: true, Output: "done"})
+	})
+	if _, err := client.Purchase(context.Background(), req); err != nil || len(keys) != 2 || keys[1] != keys[0] {
+		t.Errorf("Expected a 502 retried under the same key, got %q (%v)", keys, err)
+	}
+}
+
 func TestContextCancellation(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(1 * time.Second)
@@ -5,18 +5,65 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/toolset"
 )
 
 // Config holds the application configuration
 type Config struct {
-	APIURL    string `json:"APIURL"`
-	APIKey    string `json:"APIKey"`
-	BudgetKey string `json:"BudgetKey"`
+	APIURL         string `json:"APIURL"`
+	APIKey         string `json:"APIKey"`
+	BudgetKey      string `json:"BudgetKey"`
+	MaxConcurrency int    `json:"MaxConcurrency,omitempty"` // Concurrent requests (0 = default)
+
+	// RefreshInterval is how often the tool catalog is re-fetched, e.g. "15m" ("0" disables)
+	RefreshInterval string `json:"RefreshInterval,omitempty"`
+
+	// Local spend caps enforced before every purchase (0 = no cap)
+	MaxSessionSpend float64            `json:"MaxSessionSpend,omitempty"`
+	MaxHourlySpend  float64            `json:"MaxHourlySpend,omitempty"`
+	MaxDailySpend   float64            `json:"MaxDailySpend,omitempty"`
+	MaxToolSpend    map[string]float64 `json:"MaxToolSpend,omitempty"` // Tool name -> daily cap
+	DefaultCost     float64            `json:"DefaultCost,omitempty"`  // Assumed cost when the API reports none
+
+	// Purchases the user must approve first (via elicitation or a confirmation token)
+	ApproveAll   bool     `json:"ApproveAll,omitempty"`
+	ApproveAbove float64  `json:"ApproveAbove,omitempty"` // Expected cost threshold
+	ApproveTools []string `json:"ApproveTools,omitempty"`
+
+	// OutputDir is where outputs too large to send inline are written ("" = user cache dir)
+	OutputDir string `json:"OutputDir,omitempty"`
+
+	// NormalizeArguments coerces and defaults tool arguments to fit their schema before validation
+	NormalizeArguments bool `json:"NormalizeArguments,omitempty"`
+
+	// Which catalog tools are exposed: product IDs, tool names or globs (empty = all)
+	AllowTools []string `json:"AllowTools,omitempty"`
+	DenyTools  []string `json:"DenyTools,omitempty"`
+
+	// ToolSets are named tool selections; ToolSet picks one (--toolset overrides it)
+	ToolSets map[string]toolset.Rules `json:"ToolSets,omitempty"`
+	ToolSet  string                   `json:"ToolSet,omitempty"`
+
+	// OverridesFile holds local titles, annotations and costs ("" = tool-overrides.json next to the binary)
+	OverridesFile string `json:"OverridesFile,omitempty"`
+
+	// PromptsDir holds the team's prompt templates ("" = prompts/ next to config.json)
+	PromptsDir string `json:"PromptsDir,omitempty"`
 }
 
 // DefaultAPIURL is the default AgentPMT API endpoint
 const DefaultAPIURL = "https://api.agentpmt.com"
 
+// DefaultRefreshInterval is how often the tool catalog is re-fetched by default
+const DefaultRefreshInterval = "15m"
+
 // Load reads configuration from config.json and applies environment variable overrides
 func Load() (*Config, error) {
 	cfg := &Config{}
@@ -41,11 +88,107 @@ func Load() (*Config, error) {
 	if v := os.Getenv("AGENTPMT_BUDGET_KEY"); v != "" {
 		cfg.BudgetKey = v
 	}
+	if v := os.Getenv("AGENTPMT_MAX_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("AGENTPMT_MAX_CONCURRENCY must be a non-negative integer, got %q", v)
+		}
+		cfg.MaxConcurrency = n
+	}
+
+	if v := os.Getenv("AGENTPMT_REFRESH_INTERVAL"); v != "" {
+		cfg.RefreshInterval = v
+	}
+
+	spendVars := []struct {
+		name   string
+		target *float64
+	}{
+		{"AGENTPMT_MAX_SESSION_SPEND", &cfg.MaxSessionSpend},
+		{"AGENTPMT_MAX_HOURLY_SPEND", &cfg.MaxHourlySpend},
+		{"AGENTPMT_MAX_DAILY_SPEND", &cfg.MaxDailySpend},
+		{"AGENTPMT_DEFAULT_COST", &cfg.DefaultCost},
+	}
+	for _, sv := range spendVars {
+		if v := os.Getenv(sv.name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				return nil, fmt.Errorf("%s must be a non-negative amount, got %q", sv.name, v)
+			}
+			*sv.target = f
+		}
+	}
+	if v := os.Getenv("AGENTPMT_APPROVE_ALL"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("AGENTPMT_APPROVE_ALL must be true or false, got %q", v)
+		}
+		cfg.ApproveAll = b
+	}
+	if v := os.Getenv("AGENTPMT_APPROVE_ABOVE"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("AGENTPMT_APPROVE_ABOVE must be a non-negative amount, got %q", v)
+		}
+		cfg.ApproveAbove = f
+	}
+	if v := os.Getenv("AGENTPMT_APPROVE_TOOLS"); v != "" {
+		cfg.ApproveTools = nil
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.ApproveTools = append(cfg.ApproveTools, name)
+			}
+		}
+	}
+	if v := os.Getenv("AGENTPMT_OUTPUT_DIR"); v != "" {
+		cfg.OutputDir = v
+	}
+	if v := os.Getenv("AGENTPMT_NORMALIZE_ARGS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("AGENTPMT_NORMALIZE_ARGS must be true or false, got %q", v)
+		}
+		cfg.NormalizeArguments = b
+	}
+	if v := os.Getenv("AGENTPMT_ALLOW_TOOLS"); v != "" {
+		cfg.AllowTools = toolset.ParseList(v)
+	}
+	if v := os.Getenv("AGENTPMT_DENY_TOOLS"); v != "" {
+		cfg.DenyTools = toolset.ParseList(v)
+	}
+	if v := os.Getenv("AGENTPMT_TOOLSET"); v != "" {
+		cfg.ToolSet = v
+	}
+	if v := os.Getenv("AGENTPMT_OVERRIDES_FILE"); v != "" {
+		cfg.OverridesFile = v
+	}
+	if v := os.Getenv("AGENTPMT_PROMPTS_DIR"); v != "" {
+		cfg.PromptsDir = v
+	}
+	if cfg.PromptsDir == "" && configPath != "" {
+		cfg.PromptsDir = filepath.Join(filepath.Dir(configPath), prompt.DirName)
+	}
+	if v := os.Getenv("AGENTPMT_MAX_TOOL_SPEND"); v != "" {
+		caps, err := parseToolSpend(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MaxToolSpend = caps
+	}
 
 	// Set default API URL if still empty
 	if cfg.APIURL == "" {
 		cfg.APIURL = DefaultAPIURL
 	}
+	if cfg.RefreshInterval == "" {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+	if _, err := cfg.RefreshDuration(); err != nil {
+		return nil, err
+	}
+	if _, err := cfg.ToolFilter(); err != nil {
+		return nil, err
+	}
 
 	// Validate required fields
 	if cfg.APIKey == "" {
@@ -58,6 +201,57 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// RefreshDuration parses RefreshInterval ("0" or empty disables refresh)
+func (c *Config) RefreshDuration() (time.Duration, error) {
+	if c.RefreshInterval == "" || c.RefreshInterval == "0" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(c.RefreshInterval)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("RefreshInterval must be a duration like \"15m\", got %q", c.RefreshInterval)
+	}
+	return d, nil
+}
+
+// SpendLimits returns the local spend caps for the ledger
+func (c *Config) SpendLimits() ledger.Limits {
+	return ledger.Limits{
+		Session:     c.MaxSessionSpend,
+		Hour:        c.MaxHourlySpend,
+		Day:         c.MaxDailySpend,
+		PerTool:     c.MaxToolSpend,
+		DefaultCost: c.DefaultCost,
+	}
+}
+
+// ApprovalRules returns which purchases need the user's approval
+func (c *Config) ApprovalRules() approval.Rules {
+	return approval.Rules{
+		Always: c.ApproveAll,
+		Above:  c.ApproveAbove,
+		Tools:  c.ApproveTools,
+	}
+}
+
+// ToolFilter returns which catalog tools the router exposes (nil = all)
+func (c *Config) ToolFilter() (*toolset.Filter, error) {
+	return toolset.New(toolset.Rules{Allow: c.AllowTools, Deny: c.DenyTools}, c.ToolSets, c.ToolSet)
+}
+
+// parseToolSpend parses "tool=amount,tool=amount"
+func parseToolSpend(v string) (map[string]float64, error) {
+	caps := make(map[string]float64)
+	for _, pair := range strings.Split(v, ",") {
+		name, amount, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		f, err := strconv.ParseFloat(amount, 64)
+		if !ok || name == "" || err != nil || f < 0 {
+			return nil, fmt.Errorf("AGENTPMT_MAX_TOOL_SPEND must look like \"tool=1.00,other=0.50\", got %q", v)
+		}
+		caps[name] = f
+	}
+	return caps, nil
+}
+
 // findConfigFile locates config.json relative to the executable or current directory
 func findConfigFile() (string, error) {
 	// Try current directory first
@@ -95,9 +289,28 @@ func loadFromFile(path string, cfg *Config) error {
 // Sanitize returns a copy of the config with secrets redacted (for logging)
 func (c *Config) Sanitize() *Config {
 	return &Config{
-		APIURL:    c.APIURL,
-		APIKey:    redact(c.APIKey),
-		BudgetKey: redact(c.BudgetKey),
+		APIURL:          c.APIURL,
+		APIKey:          redact(c.APIKey),
+		BudgetKey:       redact(c.BudgetKey),
+		MaxConcurrency:  c.MaxConcurrency,
+		RefreshInterval: c.RefreshInterval,
+		MaxSessionSpend: c.MaxSessionSpend,
+		MaxHourlySpend:  c.MaxHourlySpend,
+		MaxDailySpend:   c.MaxDailySpend,
+		MaxToolSpend:    c.MaxToolSpend,
+		DefaultCost:     c.DefaultCost,
+		ApproveAll:      c.ApproveAll,
+		ApproveAbove:    c.ApproveAbove,
+		ApproveTools:    c.ApproveTools,
+		OutputDir:       c.OutputDir,
+
+		NormalizeArguments: c.NormalizeArguments,
+		AllowTools:         c.AllowTools,
+		DenyTools:          c.DenyTools,
+		ToolSets:           c.ToolSets,
+		ToolSet:            c.ToolSet,
+		OverridesFile:      c.OverridesFile,
+		PromptsDir:         c.PromptsDir,
 	}
 }
 
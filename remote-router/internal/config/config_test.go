@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadWithEnvVars(t *testing.T) {
@@ -155,3 +156,82 @@ func TestMissingRequiredFields(t *testing.T) {
 		t.Error("Expected error when BudgetKey is missing")
 	}
 }
+
+func TestLoadRefreshInterval(t *testing.T) {
+	os.Setenv("AGENTPMT_API_KEY", "test-api-key")
+	os.Setenv("AGENTPMT_BUDGET_KEY", "test-budget-key")
+	defer os.Clearenv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if d, _ := cfg.RefreshDuration(); d != 15*time.Minute {
+		t.Errorf("Expected default refresh interval of 15m, got %s", d)
+	}
+
+	os.Setenv("AGENTPMT_REFRESH_INTERVAL", "0")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if d, _ := cfg.RefreshDuration(); d != 0 {
+		t.Errorf("Expected refresh to be disabled, got %s", d)
+	}
+
+	os.Setenv("AGENTPMT_REFRESH_INTERVAL", "soon")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for invalid refresh interval")
+	}
+}
+
+func TestLoadSpendLimits(t *testing.T) {
+	os.Setenv("AGENTPMT_API_KEY", "test-api-key")
+	os.Setenv("AGENTPMT_BUDGET_KEY", "test-budget-key")
+	os.Setenv("AGENTPMT_MAX_DAILY_SPEND", "5.00")
+	os.Setenv("AGENTPMT_MAX_TOOL_SPEND", "image-gen=1.50, search=0.25")
+	defer os.Clearenv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	limits := cfg.SpendLimits()
+	if limits.Day != 5.00 {
+		t.Errorf("Expected daily cap 5.00, got %.2f", limits.Day)
+	}
+	if limits.PerTool["image-gen"] != 1.50 || limits.PerTool["search"] != 0.25 {
+		t.Errorf("Unexpected per-tool caps: %+v", limits.PerTool)
+	}
+
+	os.Setenv("AGENTPMT_MAX_TOOL_SPEND", "image-gen")
+	if _, err := Load(); err == nil {
+		t.Error("Expected error for malformed AGENTPMT_MAX_TOOL_SPEND")
+	}
+}
+
+func TestLoadToolFilterFromEnv(t *testing.T) {
+	os.Setenv("AGENTPMT_API_KEY", "test-api-key")
+	os.Setenv("AGENTPMT_BUDGET_KEY", "test-budget-key")
+	os.Setenv("AGENTPMT_ALLOW_TOOLS", "weather-*, news")
+	os.Setenv("AGENTPMT_DENY_TOOLS", "weather-pro")
+	defer os.Clearenv()
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	filter, err := cfg.ToolFilter()
+	if err != nil {
+		t.Fatalf("ToolFilter() failed: %v", err)
+	}
+	if !filter.Allowed("p1", "weather-check") || filter.Allowed("p2", "weather-pro") || filter.Allowed("p3", "stocks") {
+		t.Errorf("Unexpected filter %s", filter)
+	}
+
+	os.Setenv("AGENTPMT_TOOLSET", "missing")
+	if _, err := Load(); err == nil {
+		t.Error("Expected an error for an unknown tool set")
+	}
+}
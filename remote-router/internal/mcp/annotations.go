@@ -0,0 +1,25 @@
+package mcp
+
+import (
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
+)
+
+// annotate works out a tool's title, MCP annotations and cost from the
+// catalog and the local override file
+func (s *Server) annotate(tool api.ToolDefinition, mcpToolName string) annotation.Tool {
+	var cost *annotation.Cost
+	if p := tool.Pricing; p != nil {
+		cost = &annotation.Cost{Price: p.Cost, Currency: p.Currency, Unit: p.Unit}
+	}
+	override := s.overrides.Find(tool.Name, mcpToolName)
+	return annotation.Resolve(readablePart(tool.Description), tool.Annotations, cost, override)
+}
+
+// annotatedPrice returns the price a tool is annotated with, if any
+func annotatedPrice(a annotation.Tool) *price {
+	if a.Cost == nil {
+		return nil
+	}
+	return &price{Amount: a.Cost.Price, Currency: a.Cost.Currency, Unit: a.Cost.Unit, Source: a.CostSource}
+}
@@ -0,0 +1,131 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+)
+
+// checkApproval asks the user to approve a purchase when the approval rules
+// require it. Clients with the elicitation capability get an elicitation/create
+// prompt; others get a confirmation token the agent must pass back in a second
+// call. Returns a non-empty message when the purchase must not go ahead.
+// The confirmation token argument is removed from args either way.
+func (s *Server) checkApproval(ctx context.Context, tool string, args map[string]interface{}) string {
+	token, _ := args[approval.TokenArgument].(string)
+	delete(args, approval.TokenArgument)
+
+	expected := s.lookupPrice(tool)
+	cost, costKnown := 0.0, expected != nil
+	if costKnown {
+		cost = expected.Amount
+	}
+	required, reason := s.approvalRules.Required(tool, cost, costKnown)
+	if !required {
+		return ""
+	}
+
+	if s.elicitation.Load() {
+		approved, err := s.elicitApproval(ctx, tool, args, expected, reason)
+		if err == nil {
+			if approved {
+				log.Printf("Purchase of %s approved by the user", tool)
+				return ""
+			}
+			log.Printf("Purchase of %s declined by the user", tool)
+			return "purchase declined by the user. The tool was not called and nothing was charged."
+		}
+		if ctx.Err() != nil {
+			return "purchase approval was cancelled. The tool was not called and nothing was charged."
+		}
+		log.Printf("Elicitation failed, falling back to a confirmation token: %v", err)
+	}
+
+	if token != "" {
+		if s.tokens.Redeem(token, stdioSession, tool, args) {
+			log.Printf("Purchase of %s confirmed with a token", tool)
+			return ""
+		}
+		log.Printf("Rejected confirmation token for %s", tool)
+	}
+
+	token = s.tokens.Issue(stdioSession, tool, args)
+	argsJSON, _ := json.Marshal(args)
+	return fmt.Sprintf("approval required: %s. Nothing was charged.\n\n"+
+		"Show the user this purchase and ask them to approve it:\n"+
+		"  Tool: %s\n  Arguments: %s\n  Expected cost: %s\n\n"+
+		"If they approve, call %s again with the same arguments plus \"%s\": \"%s\". "+
+		"The token works once and expires in %s.",
+		reason, tool, argsJSON, formatPrice(expected),
+		tool, approval.TokenArgument, token, s.tokens.TTL())
+}
+
+// elicitApproval asks the user through elicitation/create
+func (s *Server) elicitApproval(ctx context.Context, tool string, args map[string]interface{}, expected *price, reason string) (bool, error) {
+	argsJSON, _ := json.MarshalIndent(args, "", "  ")
+	message := fmt.Sprintf("Approve this paid tool call?\n\nTool: %s\nArguments: %s\nExpected cost: %s\n\n(%s)",
+		tool, argsJSON, formatPrice(expected), reason)
+
+	raw, err := s.request(ctx, "elicitation/create", map[string]interface{}{
+		"message": message,
+		"requestedSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"approve": map[string]interface{}{
+					"type":        "boolean",
+					"title":       "Approve purchase",
+					"description": fmt.Sprintf("Pay for %s", tool),
+					"default":     true,
+				},
+			},
+			"required": []string{"approve"},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var result struct {
+		Action  string `json:"action"` // "accept", "decline" or "cancel"
+		Content struct {
+			Approve *bool `json:"approve"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return false, fmt.Errorf("invalid elicitation result: %w", err)
+	}
+
+	// Accepting without the field counts as approval: the form only has one question
+	approved := result.Action == "accept" && (result.Content.Approve == nil || *result.Content.Approve)
+	return approved, nil
+}
+
+// formatPrice describes an expected price for the user
+func formatPrice(p *price) string {
+	if p == nil {
+		return "unknown"
+	}
+	amount := fmt.Sprintf("%.2f", p.Amount)
+	if p.Currency != "" {
+		amount += " " + p.Currency
+	}
+	if p.Unit != "" {
+		amount += " per " + p.Unit
+	}
+	if p.Source == "last-charge" {
+		return amount + " (last charged for this tool)"
+	}
+	return amount
+}
+
+// request sends a server-initiated request to the client and waits for its result
+func (s *Server) request(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	s.notifyMux.RLock()
+	send := s.notify
+	s.notifyMux.RUnlock()
+
+	return s.outgoing.Call(ctx, send, method, params)
+}
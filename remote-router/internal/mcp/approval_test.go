@@ -0,0 +1,90 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
+)
+
+func TestToolsCallApprovedViaElicitation(t *testing.T) {
+	mockClient := &mockAPIClient{purchaseResponse: &api.PurchaseResponse{Success: true, Output: "paid result"}}
+	server := NewServer(mockClient, "1.0.0")
+	server.SetApproval(approval.Rules{Tools: []string{"test-tool"}})
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.serve(stdinR, stdoutW)
+		stdoutW.Close()
+	}()
+
+	lines := bufio.NewScanner(stdoutR)
+	readMessage := func() map[string]interface{} {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("Expected a message from the server: %v", lines.Err())
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+			t.Fatalf("Invalid message %s: %v", lines.Text(), err)
+		}
+		return msg
+	}
+
+	fmt.Fprintln(stdinW, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{"elicitation":{}}}}`)
+	readMessage()
+
+	fmt.Fprintln(stdinW, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"test-tool","arguments":{"q":"x"}}}`)
+
+	elicit := readMessage()
+	if elicit["method"] != "elicitation/create" {
+		t.Fatalf("Expected elicitation/create, got %v", elicit)
+	}
+
+	fmt.Fprintf(stdinW, `{"jsonrpc":"2.0","id":%q,"result":{"action":"accept","content":{"approve":true}}}`+"\n", elicit["id"])
+
+	resp := readMessage()
+	result := resp["result"].(map[string]interface{})
+	if resp["id"] != float64(2) || result["isError"] == true {
+		t.Fatalf("Expected request 2 to succeed after approval, got %v", resp)
+	}
+	if text := fmt.Sprint(result["content"]); !strings.Contains(text, "paid result") {
+		t.Errorf("Expected the tool output, got %s", text)
+	}
+
+	stdinW.Close()
+	if err := <-done; err != nil {
+		t.Errorf("serve() failed: %v", err)
+	}
+}
+
+func TestToolsCallRequiresConfirmationTokenWithoutElicitation(t *testing.T) {
+	mockClient := &mockAPIClient{purchaseResponse: &api.PurchaseResponse{Success: true, Output: "paid result"}}
+	server := NewServer(mockClient, "1.0.0")
+	server.SetApproval(approval.Rules{Always: true})
+
+	args := map[string]interface{}{"q": "x"}
+	first := server.handleToolsCall(context.Background(), 1, map[string]interface{}{"name": "test-tool", "arguments": args})
+	refusal := first.Result.(MCPToolCallResult)
+	if !refusal.IsError || !strings.Contains(refusal.Content[0].Text, approval.TokenArgument) {
+		t.Fatalf("Expected a confirmation token request, got %+v", refusal)
+	}
+
+	text := refusal.Content[0].Text
+	token := text[strings.Index(text, approval.TokenArgument)+len(approval.TokenArgument)+4:]
+	token = token[:strings.Index(token, `"`)]
+
+	args = map[string]interface{}{"q": "x", approval.TokenArgument: token}
+	second := server.handleToolsCall(context.Background(), 2, map[string]interface{}{"name": "test-tool", "arguments": args})
+	if result := second.Result.(MCPToolCallResult); result.IsError {
+		t.Fatalf("Expected the confirmed call to succeed, got %+v", result)
+	}
+}
@@ -0,0 +1,15 @@
+package mcp
+
+import "log"
+
+// handleCancelled handles notifications/cancelled from the client
+func (s *Server) handleCancelled(params map[string]interface{}) {
+	requestID, ok := params["requestId"]
+	if !ok || requestID == nil {
+		log.Printf("Ignoring notifications/cancelled without requestId")
+		return
+	}
+	reason, _ := params["reason"].(string)
+
+	s.inflight.Abort(requestID, reason)
+}
@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/content"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+)
+
+// purchaseResult returns purchase output as text, any images, audio or files
+// found in it as their own content blocks, and structuredContent when it is JSON
+func (s *Server) purchaseResult(id interface{}, productID, output string) JSONRPCResponse {
+	text, media := s.extractor.ExtractText(output)
+	structured := s.conformingOutput(productID, schema.StructureText(text))
+
+	return jsonOK(id, MCPToolCallResult{
+		Content:           append([]MCPContent{content.Text(text)}, media...),
+		StructuredContent: structured,
+	})
+}
+
+// catalogOutputSchema returns the output schema the catalog publishes for productID
+// nameMux must be held
+func (s *Server) catalogOutputSchema(productID string) json.RawMessage {
+	for _, tool := range s.tools {
+		if s.nameToIDMap[tool.Name] == productID {
+			return tool.OutputSchema
+		}
+	}
+	return nil
+}
+
+// conformingOutput returns structured output only if it fits the tool's
+// catalog output schema, since structuredContent must match the listed
+// outputSchema; output that doesn't fit is still sent as text
+func (s *Server) conformingOutput(productID string, structured map[string]interface{}) map[string]interface{} {
+	if structured == nil {
+		return nil
+	}
+
+	s.nameMux.RLock()
+	published := s.catalogOutputSchema(productID)
+	s.nameMux.RUnlock()
+	if len(published) == 0 {
+		return structured
+	}
+
+	if errs := schema.ValidateOutput(published, structured); len(errs) > 0 {
+		log.Printf("Warning: output of %s doesn't match its catalog output schema, sending it as text only: %v", productID, errs)
+		return nil
+	}
+	return structured
+}
@@ -0,0 +1,137 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
+)
+
+func TestToolsCallReturnsStructuredContent(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{Name: "prod-1", Description: "weather — Forecasts", Parameters: json.RawMessage(`{}`)},
+		},
+		purchaseResponse: &api.PurchaseResponse{Success: true, Output: `{"temp": 21, "summary": "sunny"}`},
+	}
+	server := NewServer(mockClient, "1.0.0")
+	server.handleToolsList(1)
+
+	var sent []JSONRPCNotification
+	server.setNotifier(func(msg interface{}) error {
+		sent = append(sent, msg.(JSONRPCNotification))
+		return nil
+	})
+
+	resp := server.handleToolsCall(context.Background(), 2, map[string]interface{}{"name": "weather"})
+	result := resp.Result.(MCPToolCallResult)
+	if result.StructuredContent["summary"] != "sunny" {
+		t.Fatalf("Expected structured output, got %+v", result)
+	}
+	if result.Content[0].Text != `{"temp": 21, "summary": "sunny"}` {
+		t.Errorf("Expected the JSON text fallback, got %q", result.Content[0].Text)
+	}
+
+	// Results never change the listed tools: only the catalog provides output schemas
+	if len(sent) != 0 {
+		t.Errorf("Expected no notifications, got %+v", sent)
+	}
+	tools := server.handleToolsList(3).Result.(map[string]interface{})["tools"].([]MCPTool)
+	if len(tools[0].OutputSchema) != 0 {
+		t.Errorf("Expected weather to list no output schema, got %s", tools[0].OutputSchema)
+	}
+
+	// Plain text output stays text
+	mockClient.purchaseResponse = &api.PurchaseResponse{Success: true, Output: "sunny, 21C"}
+	resp = server.handleToolsCall(context.Background(), 4, map[string]interface{}{"name": "weather"})
+	if result := resp.Result.(MCPToolCallResult); result.StructuredContent != nil {
+		t.Errorf("Expected no structured content for text output, got %+v", result.StructuredContent)
+	}
+}
+
+func TestCatalogOutputSchemaIsListed(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{
+				Name:         "prod-1",
+				Description:  "search — Finds things",
+				Parameters:   json.RawMessage(`{}`),
+				OutputSchema: json.RawMessage(`{"type":"array","items":{"type":"string"}}`),
+			},
+		},
+	}
+	server := NewServer(mockClient, "1.0.0")
+
+	tools := server.handleToolsList(1).Result.(map[string]interface{})["tools"].([]MCPTool)
+	var listed map[string]interface{}
+	if err := json.Unmarshal(tools[0].OutputSchema, &listed); err != nil {
+		t.Fatalf("Invalid output schema %s: %v", tools[0].OutputSchema, err)
+	}
+	// Non-object output is wrapped, since structuredContent must be an object
+	if listed["type"] != "object" || listed["properties"].(map[string]interface{})["result"] == nil {
+		t.Errorf("Expected the array schema wrapped under result, got %s", tools[0].OutputSchema)
+	}
+}
+
+func TestOutputNotMatchingTheCatalogSchemaIsTextOnly(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{
+				Name:         "prod-1",
+				Description:  "weather — Forecasts",
+				Parameters:   json.RawMessage(`{}`),
+				OutputSchema: json.RawMessage(`{"type":"object","properties":{"temp":{"type":"number"}}}`),
+			},
+		},
+		purchaseResponse: &api.PurchaseResponse{Success: true, Output: `{"temp": "warm"}`},
+	}
+	server := NewServer(mockClient, "1.0.0")
+	server.handleToolsList(1)
+
+	result := server.handleToolsCall(context.Background(), 2, map[string]interface{}{"name": "weather"}).Result.(MCPToolCallResult)
+	if result.StructuredContent != nil {
+		t.Errorf("Expected no structured content for output that breaks the schema, got %+v", result.StructuredContent)
+	}
+	if result.Content[0].Text != `{"temp": "warm"}` {
+		t.Errorf("Expected the output as text, got %q", result.Content[0].Text)
+	}
+
+	mockClient.purchaseResponse = &api.PurchaseResponse{Success: true, Output: `{"temp": 21}`}
+	result = server.handleToolsCall(context.Background(), 3, map[string]interface{}{"name": "weather"}).Result.(MCPToolCallResult)
+	if result.StructuredContent["temp"] != 21.0 {
+		t.Errorf("Expected structured content for conforming output, got %+v", result)
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	if got := negotiateVersion("2025-06-18"); got != "2025-06-18" {
+		t.Errorf("Expected a supported version to be echoed, got %s", got)
+	}
+	if got := negotiateVersion("1999-01-01"); got != ProtocolVersion {
+		t.Errorf("Expected the default for an unknown version, got %s", got)
+	}
+}
+
+func TestToolsCallReturnsImageContent(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...))
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{Name: "prod-1", Description: "render — Draws things", Parameters: json.RawMessage(`{}`)},
+		},
+		purchaseResponse: &api.PurchaseResponse{Success: true, Output: `{"image": "data:image/png;base64,` + png + `"}`},
+	}
+	server := NewServer(mockClient, "1.0.0")
+	server.SetOutputDir(t.TempDir())
+	server.handleToolsList(1)
+
+	result := server.handleToolsCall(context.Background(), 2, map[string]interface{}{"name": "render"}).Result.(MCPToolCallResult)
+	if len(result.Content) != 2 || result.Content[1].Type != "image" || result.Content[1].MimeType != "image/png" {
+		t.Fatalf("Expected a text block and an image block, got %+v", result.Content)
+	}
+	if strings.Contains(result.Content[0].Text, png) {
+		t.Error("Expected the text block to leave out the image data")
+	}
+}
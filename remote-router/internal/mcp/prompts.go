@@ -0,0 +1,83 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+)
+
+// handlePromptsList returns the built-in, per-tool and team prompts
+func (s *Server) handlePromptsList(id interface{}) JSONRPCResponse {
+	return jsonOK(id, map[string]interface{}{"prompts": s.prompts.List(s.promptTools())})
+}
+
+// handlePromptsGet renders a prompt with the client's arguments
+func (s *Server) handlePromptsGet(id interface{}, params map[string]interface{}) JSONRPCResponse {
+	name, _ := params["name"].(string)
+	if name == "" {
+		return jsonErr(id, InvalidParams, "missing or invalid 'name' parameter")
+	}
+	args := make(map[string]string)
+	if raw, ok := params["arguments"].(map[string]interface{}); ok {
+		for key, value := range raw {
+			args[key] = fmt.Sprint(value)
+		}
+	}
+
+	result, err := s.prompts.Get(name, args, s.promptTools())
+	if errors.Is(err, prompt.ErrNotFound) {
+		return jsonErr(id, InvalidParams, fmt.Sprintf("unknown prompt: %s", name))
+	}
+	if err != nil {
+		return jsonErr(id, InvalidParams, fmt.Sprintf("invalid arguments: %v", err))
+	}
+	return jsonOK(id, result)
+}
+
+// promptTools describes the listed tools for the prompt library
+// The catalog is fetched first if needed; without it only the built-in prompts are served.
+func (s *Server) promptTools() []prompt.Tool {
+	if err := s.ensureCatalog(); err != nil {
+		log.Printf("Failed to fetch tools for prompts: %v", err)
+	}
+
+	s.nameMux.RLock()
+	defer s.nameMux.RUnlock()
+	return promptTools(s.tools)
+}
+
+// promptsChanged reports whether a catalog change altered prompts/list
+func (s *Server) promptsChanged(previous, current []MCPTool) bool {
+	before := s.prompts.List(promptTools(previous))
+	after := s.prompts.List(promptTools(current))
+	if len(before) != len(after) {
+		return true
+	}
+	for i := range before {
+		if before[i].Name != after[i].Name || before[i].Title != after[i].Title {
+			return true
+		}
+	}
+	return false
+}
+
+// promptTools converts listed tools for the prompt library
+func promptTools(tools []MCPTool) []prompt.Tool {
+	out := make([]prompt.Tool, 0, len(tools))
+	for _, tool := range tools {
+		pt := prompt.Tool{
+			Name:        tool.Name,
+			Title:       tool.Title,
+			Description: tool.Description,
+			Examples:    tool.examples,
+		}
+		if tool.price != nil {
+			amount := tool.price.Amount
+			pt.Price, pt.Currency, pt.Unit = &amount, tool.price.Currency, tool.price.Unit
+		}
+		out = append(out, pt)
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+)
+
+// QuoteToolName is the meta-tool that quotes a tool call without buying it
+// Clients that can set _meta can use {"_meta": {"dryRun": true}} on tools/call instead.
+const QuoteToolName = "quote_tool"
+
+// quoteTool is listed in tools/list after the catalog
+var quoteTool = MCPTool{
+	Name: QuoteToolName,
+	Description: "Quote Tool — Check what a tool call would cost and whether its arguments are valid, " +
+		"without calling the tool or being charged. Use it to plan multi-step work within a budget.",
+	InputSchema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"tool": {"type": "string", "description": "Name of the tool to quote"},
+			"arguments": {"type": "object", "description": "Arguments you would call the tool with"}
+		},
+		"required": ["tool"]
+	}`),
+}
+
+// withQuoteTool returns the catalog followed by the quote meta-tool
+func withQuoteTool(tools []MCPTool) []MCPTool {
+	listed := make([]MCPTool, 0, len(tools)+1)
+	listed = append(listed, tools...)
+	return append(listed, quoteTool)
+}
+
+// price is what a purchase is expected to cost
+type price struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency,omitempty"`
+	Unit     string  `json:"unit,omitempty"`
+	Source   string  `json:"source"` // "catalog", "override" or "last-charge"
+}
+
+// quote describes a tool call without making it
+type quote struct {
+	Tool             string              `json:"tool"`
+	ProductID        string              `json:"productId"`
+	Valid            bool                `json:"valid"`
+	Errors           []schema.FieldError `json:"errors,omitempty"`
+	Price            *price              `json:"price"` // null when unknown
+	Budget           []budgetImpact      `json:"budget,omitempty"`
+	WouldExceed      string              `json:"wouldExceed,omitempty"` // Cap the call would be refused by
+	ApprovalRequired bool                `json:"approvalRequired"`
+	Charged          bool                `json:"charged"` // Always false: quotes never buy
+}
+
+// budgetImpact is one local spend cap before and after the quoted purchase
+type budgetImpact struct {
+	ledger.CapStatus
+	RemainingAfter *float64 `json:"remainingAfter"` // null when the price is unknown
+}
+
+// findTool returns the catalog entry for an MCP tool name
+func (s *Server) findTool(name string) (MCPTool, bool) {
+	s.nameMux.RLock()
+	defer s.nameMux.RUnlock()
+
+	for _, tool := range s.tools {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return MCPTool{}, false
+}
+
+// canonicalName returns the listed name of the tool a name refers to
+// Former names (aliases) and product IDs of listed tools resolve to the
+// tool's current name so validation, caps and approval all see one name per tool.
+func (s *Server) canonicalName(name string) string {
+	s.nameMux.RLock()
+	defer s.nameMux.RUnlock()
+
+	productID, exists := s.nameToIDMap[name]
+	if !exists {
+		productID = name // Possibly a product ID
+	}
+	for _, tool := range s.tools {
+		if tool.Name == name {
+			return name
+		}
+	}
+	for _, tool := range s.tools {
+		if s.nameToIDMap[tool.Name] == productID {
+			return tool.Name
+		}
+	}
+	return name
+}
+
+// lookupPrice returns the expected price of tool: the catalog price (or its
+// local override) when known, otherwise the last amount the API charged for it
+func (s *Server) lookupPrice(tool string) *price {
+	if entry, ok := s.findTool(tool); ok && entry.price != nil {
+		return entry.price
+	}
+	if s.ledger != nil {
+		if amount, ok := s.ledger.LastCost(tool); ok {
+			return &price{Amount: amount, Source: "last-charge"}
+		}
+	}
+	return nil
+}
+
+// expectedCost is what a purchase of tool is held against the spend caps at:
+// its lookupPrice, or -1 when unknown (the ledger assumes its default cost)
+func (s *Server) expectedCost(tool string) float64 {
+	if p := s.lookupPrice(tool); p != nil {
+		return p.Amount
+	}
+	return -1
+}
+
+// quoteCall validates a call and works out its price and budget impact
+func (s *Server) quoteCall(tool string, args map[string]interface{}) (*quote, error) {
+	tool = s.canonicalName(tool)
+	s.nameMux.RLock()
+	productID, exists := s.nameToIDMap[tool]
+	s.nameMux.RUnlock()
+	if _, listed := s.findTool(tool); !exists || !listed {
+		return nil, fmt.Errorf("unknown tool %q", tool)
+	}
+
+	args = s.normalizeArguments(tool, args)
+
+	q := &quote{
+		Tool:      tool,
+		ProductID: productID,
+		Errors:    s.argumentErrors(tool, args),
+		Price:     s.lookupPrice(tool),
+	}
+	q.Valid = len(q.Errors) == 0
+
+	cost, costKnown := 0.0, q.Price != nil
+	if costKnown {
+		cost = q.Price.Amount
+	}
+	q.ApprovalRequired, _ = s.approvalRules.Required(tool, cost, costKnown)
+
+	if s.ledger != nil {
+		var capErr *ledger.CapError
+		if err := s.ledger.Check(stdioSession, tool, s.expectedCost(tool)); errors.As(err, &capErr) {
+			q.WouldExceed = capErr.Cap
+		}
+
+		for _, status := range s.ledger.Status(stdioSession, tool) {
+			impact := budgetImpact{CapStatus: status}
+			if costKnown {
+				after := status.Remaining() - cost
+				impact.RemainingAfter = &after
+				if after < 0 && q.WouldExceed == "" {
+					q.WouldExceed = status.Cap
+				}
+			}
+			q.Budget = append(q.Budget, impact)
+		}
+	}
+
+	return q, nil
+}
+
+// handleQuote answers a dry-run tools/call or a quote_tool call
+func (s *Server) handleQuote(id interface{}, tool string, args map[string]interface{}) JSONRPCResponse {
+	q, err := s.quoteCall(tool, args)
+	if err != nil {
+		return s.errorResult(id, fmt.Sprintf("quote failed: %v", err))
+	}
+
+	quoteJSON, _ := json.MarshalIndent(q, "", "  ")
+	return s.successResult(id, fmt.Sprintf("Quote (nothing was charged):\n%s", quoteJSON))
+}
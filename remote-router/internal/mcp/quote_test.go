@@ -0,0 +1,104 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
+)
+
+// newQuoteTestServer has one priced tool with a required argument and an API
+// client that fails if a purchase is attempted
+func newQuoteTestServer(t *testing.T) *Server {
+	t.Helper()
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{
+				Name:        "prod-1",
+				Description: "test-tool — A test",
+				Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+				Pricing:     &api.Pricing{Cost: 0.25, Currency: "USD"},
+			},
+		},
+		purchaseError: fmt.Errorf("must not be called"),
+	}
+	server := NewServer(mockClient, "1.0.0")
+	server.handleToolsList(1) // Load the catalog
+	return server
+}
+
+// decodeQuote extracts the quote from a tool result
+func decodeQuote(t *testing.T, resp JSONRPCResponse) quote {
+	t.Helper()
+	result := resp.Result.(MCPToolCallResult)
+	if result.IsError {
+		t.Fatalf("Expected a quote, got error %+v", result)
+	}
+	text := result.Content[0].Text
+
+	var q quote
+	if err := json.Unmarshal([]byte(text[strings.Index(text, "{"):]), &q); err != nil {
+		t.Fatalf("Invalid quote %q: %v", text, err)
+	}
+	return q
+}
+
+func TestDryRunQuotesWithoutBuying(t *testing.T) {
+	server := newQuoteTestServer(t)
+	spend, _ := ledger.New(ledger.Limits{Day: 1.00}, "")
+	spend.Record(ledger.Entry{Tool: "test-tool"}, 0.90)
+	server.SetLedger(spend)
+
+	resp := server.handleToolsCall(context.Background(), 2, map[string]interface{}{
+		"name":      "test-tool",
+		"arguments": map[string]interface{}{"city": "Oslo"},
+		"_meta":     map[string]interface{}{"dryRun": true},
+	})
+	q := decodeQuote(t, resp)
+
+	if !q.Valid || q.ProductID != "prod-1" || q.Charged {
+		t.Errorf("Unexpected quote: %+v", q)
+	}
+	if q.Price == nil || q.Price.Amount != 0.25 || q.Price.Source != "catalog" {
+		t.Errorf("Expected the catalog price, got %+v", q.Price)
+	}
+	if q.WouldExceed != "daily" {
+		t.Errorf("Expected the purchase to exceed the daily cap, got %q", q.WouldExceed)
+	}
+	if len(q.Budget) != 1 || q.Budget[0].RemainingAfter == nil {
+		t.Fatalf("Expected the daily cap impact, got %+v", q.Budget)
+	}
+}
+
+func TestQuoteToolReportsInvalidArguments(t *testing.T) {
+	server := newQuoteTestServer(t)
+
+	resp := server.handleToolsCall(context.Background(), 2, map[string]interface{}{
+		"name": QuoteToolName,
+		"arguments": map[string]interface{}{
+			"tool":      "test-tool",
+			"arguments": map[string]interface{}{},
+		},
+	})
+	q := decodeQuote(t, resp)
+
+	if q.Valid || len(q.Errors) != 1 || q.Errors[0].Field != "city" {
+		t.Errorf("Expected a missing city error, got %+v", q)
+	}
+}
+
+func TestQuoteUnknownTool(t *testing.T) {
+	server := newQuoteTestServer(t)
+
+	resp := server.handleToolsCall(context.Background(), 2, map[string]interface{}{
+		"name":      QuoteToolName,
+		"arguments": map[string]interface{}{"tool": "missing"},
+	})
+	if result := resp.Result.(MCPToolCallResult); !result.IsError {
+		t.Errorf("Expected an error for an unknown tool, got %+v", result)
+	}
+}
@@ -0,0 +1,226 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/cache"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
+)
+
+// toolsDiff describes how a refreshed catalog differs from the previous one
+type toolsDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Empty reports whether the catalogs are identical
+func (d toolsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String summarizes the diff for logs
+func (d toolsDiff) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// diffTools compares two catalogs by MCP tool name
+func diffTools(previous, current []MCPTool) toolsDiff {
+	old := make(map[string]MCPTool, len(previous))
+	for _, tool := range previous {
+		old[tool.Name] = tool
+	}
+
+	var diff toolsDiff
+	seen := make(map[string]bool, len(current))
+	for _, tool := range current {
+		seen[tool.Name] = true
+		before, exists := old[tool.Name]
+		switch {
+		case !exists:
+			diff.Added = append(diff.Added, tool.Name)
+		case before.Description != tool.Description || !bytes.Equal(before.InputSchema, tool.InputSchema) ||
+			!bytes.Equal(before.OutputSchema, tool.OutputSchema) || before.Title != tool.Title ||
+			!reflect.DeepEqual(before.Annotations, tool.Annotations) || !reflect.DeepEqual(before.Meta, tool.Meta):
+			diff.Changed = append(diff.Changed, tool.Name)
+		}
+	}
+	for name := range old {
+		if !seen[name] {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// RefreshTools re-fetches the catalog, swaps it in and sends
+// notifications/tools/list_changed if it differs from the previous one.
+// The first load only records a baseline; the client lists tools itself.
+func (s *Server) RefreshTools(ctx context.Context) (toolsDiff, error) {
+	s.refreshMux.Lock()
+	defer s.refreshMux.Unlock()
+
+	tools, err := s.apiClient.FetchTools(ctx)
+	if err != nil {
+		return toolsDiff{}, fmt.Errorf("failed to fetch tools: %w", err)
+	}
+
+	// An empty catalog is likelier an API or budget hiccup than every product
+	// being withdrawn at once: keep listing what the client already has
+	if len(tools) == 0 {
+		s.nameMux.RLock()
+		listed := len(s.tools)
+		s.nameMux.RUnlock()
+		if listed > 0 {
+			return toolsDiff{}, fmt.Errorf("the API returned an empty catalog; keeping the %d tools already listed", listed)
+		}
+	}
+
+	mcpTools, nameToID := s.buildTools(tools)
+	previous := s.setCatalog(mcpTools, nameToID)
+	s.saveCache(tools, nameToID)
+	if s.stale.Swap(false) {
+		log.Printf("Cached catalog replaced with a fresh fetch")
+	}
+	if previous == nil {
+		return toolsDiff{}, nil
+	}
+
+	diff := diffTools(previous, mcpTools)
+	if !diff.Empty() {
+		log.Printf("Tool catalog refreshed: %s", diff)
+		s.sendNotification("notifications/tools/list_changed", nil)
+		if s.promptsChanged(previous, mcpTools) {
+			s.sendNotification("notifications/prompts/list_changed", nil)
+		}
+	}
+	return diff, nil
+}
+
+// RefreshMethod re-fetches the catalog on request and answers with what changed
+// It works where SIGHUP can't be sent, such as Windows.
+const RefreshMethod = "tools/refresh"
+
+// handleToolsRefresh handles RefreshMethod
+func (s *Server) handleToolsRefresh(ctx context.Context, id interface{}) JSONRPCResponse {
+	log.Printf("Refreshing tool catalog on request...")
+	diff, err := s.RefreshTools(ctx)
+	if err != nil {
+		return jsonErr(id, InternalError, fmt.Sprintf("catalog refresh failed: %v", err))
+	}
+	return jsonOK(id, diff)
+}
+
+// TriggerRefresh asks the background refresher to re-fetch the catalog now
+func (s *Server) TriggerRefresh() {
+	select {
+	case s.refreshCh <- struct{}{}:
+	default:
+		// A refresh is already pending
+	}
+}
+
+// runRefresher refreshes the catalog every refreshInterval and on TriggerRefresh
+func (s *Server) runRefresher(ctx context.Context) {
+	var tick <-chan time.Time
+	if s.refreshInterval > 0 {
+		ticker := time.NewTicker(s.refreshInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+		log.Printf("Refreshing tool catalog every %s", s.refreshInterval)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-s.refreshCh:
+			log.Printf("Refreshing tool catalog on request...")
+		}
+
+		if _, err := s.RefreshTools(ctx); err != nil {
+			log.Printf("Warning: catalog refresh failed: %v", err)
+		}
+	}
+}
+
+// setNotifier sets the function used to send server-initiated messages
+func (s *Server) setNotifier(notify func(interface{}) error) {
+	s.notifyMux.Lock()
+	defer s.notifyMux.Unlock()
+	s.notify = notify
+}
+
+// sendNotification sends a JSON-RPC notification to the client, if connected
+func (s *Server) sendNotification(method string, params interface{}) {
+	s.notifyMux.RLock()
+	notify := s.notify
+	s.notifyMux.RUnlock()
+
+	if notify == nil {
+		return
+	}
+	if err := notify(JSONRPCNotification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		log.Printf("Failed to send %s: %v", method, err)
+	}
+}
+
+// SetCache enables the on-disk catalog cache at path for the given credentials fingerprint
+// Must be called before HandleStdioTransport
+func (s *Server) SetCache(path, fingerprint string) {
+	s.cachePath = path
+	s.fingerprint = fingerprint
+}
+
+// LoadCache installs the on-disk catalog so tools/list can answer without
+// fetching every page. The server stays stale until the first successful refresh.
+func (s *Server) LoadCache() error {
+	if s.cachePath == "" {
+		return fmt.Errorf("catalog cache disabled")
+	}
+
+	f, err := cache.Load(s.cachePath, s.fingerprint)
+	if err != nil {
+		return err
+	}
+
+	mcpTools, nameToID := s.buildTools(api.ToolDefinitions(f.Tools))
+	// Keep names that only exist in the cached map resolvable
+	for name, productID := range f.NameToID {
+		if _, exists := nameToID[name]; !exists {
+			nameToID[name] = productID
+		}
+	}
+	s.setCatalog(mcpTools, nameToID)
+	s.stale.Store(true)
+
+	log.Printf("Loaded %d tools from cache %s (saved %s)", len(mcpTools), s.cachePath, f.SavedAt.Format(time.RFC3339))
+	return nil
+}
+
+// saveCache writes the catalog as the last good one
+func (s *Server) saveCache(tools []api.ToolDefinition, nameToID map[string]string) {
+	if s.cachePath == "" {
+		return
+	}
+
+	err := cache.Save(s.cachePath, &cache.File{
+		Fingerprint: s.fingerprint,
+		Tools:       api.CatalogTools(tools),
+		NameToID:    nameToID,
+	})
+	if err != nil {
+		log.Printf("Warning: failed to write catalog cache: %v", err)
+	}
+}
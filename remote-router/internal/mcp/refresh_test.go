@@ -0,0 +1,233 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/naming"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
+)
+
+func TestRefreshToolsNotifiesOnChange(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{Name: "prod-1", Description: "Alpha — First tool", Parameters: json.RawMessage(`{}`)},
+		},
+	}
+	server := NewServer(mockClient, "1.0.0")
+
+	var sent []JSONRPCNotification
+	server.setNotifier(func(msg interface{}) error {
+		sent = append(sent, msg.(JSONRPCNotification))
+		return nil
+	})
+
+	// First load records a baseline without notifying
+	if _, err := server.RefreshTools(context.Background()); err != nil {
+		t.Fatalf("RefreshTools() failed: %v", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("Expected no notification for the initial load, got %d", len(sent))
+	}
+
+	// Unchanged catalog: still no notification
+	server.RefreshTools(context.Background())
+	if len(sent) != 0 {
+		t.Fatalf("Expected no notification for an unchanged catalog, got %d", len(sent))
+	}
+
+	mockClient.tools = append(mockClient.tools, api.ToolDefinition{
+		Name: "prod-2", Description: "Beta — Second tool", Parameters: json.RawMessage(`{}`),
+	})
+	diff, err := server.RefreshTools(context.Background())
+	if err != nil {
+		t.Fatalf("RefreshTools() failed: %v", err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "Beta" {
+		t.Errorf("Expected Beta to be added, got %+v", diff)
+	}
+	if len(sent) != 1 || sent[0].Method != "notifications/tools/list_changed" {
+		t.Errorf("Expected one list_changed notification, got %+v", sent)
+	}
+
+	server.nameMux.RLock()
+	productID := server.nameToIDMap["Beta"]
+	server.nameMux.RUnlock()
+	if productID != "prod-2" {
+		t.Errorf("Expected Beta to map to prod-2, got %q", productID)
+	}
+}
+
+func TestRefreshMethodKeepsToolsOnEmptyCatalog(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{Name: "prod-1", Description: "Alpha — First tool", Parameters: json.RawMessage(`{}`)},
+		},
+	}
+	server := NewServer(mockClient, "1.0.0")
+	server.RefreshTools(context.Background())
+
+	mockClient.tools = append(mockClient.tools, api.ToolDefinition{
+		Name: "prod-2", Description: "Beta — Second tool", Parameters: json.RawMessage(`{}`),
+	})
+	resp := server.handleRequest(context.Background(), JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: RefreshMethod})
+	if diff, ok := resp.Result.(toolsDiff); !ok || len(diff.Added) != 1 || diff.Added[0] != "Beta" {
+		t.Fatalf("Expected %s to report Beta added, got %+v", RefreshMethod, resp)
+	}
+
+	// An empty catalog is refused rather than emptying the tool list
+	mockClient.tools = nil
+	resp = server.handleRequest(context.Background(), JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: RefreshMethod})
+	if resp.Error == nil || resp.Error.Code != InternalError {
+		t.Fatalf("Expected an empty catalog to fail the refresh, got %+v", resp)
+	}
+	server.nameMux.RLock()
+	listed := len(server.tools)
+	server.nameMux.RUnlock()
+	if listed != 2 {
+		t.Errorf("Expected both tools to stay listed, got %d", listed)
+	}
+}
+
+func TestDiffToolsDetectsRemovedAndChanged(t *testing.T) {
+	previous := []MCPTool{
+		{Name: "a", Description: "A", InputSchema: json.RawMessage(`{}`)},
+		{Name: "b", Description: "B", InputSchema: json.RawMessage(`{}`)},
+	}
+	current := []MCPTool{
+		{Name: "a", Description: "A", InputSchema: json.RawMessage(`{"type":"object"}`)},
+	}
+
+	diff := diffTools(previous, current)
+	if len(diff.Changed) != 1 || diff.Changed[0] != "a" {
+		t.Errorf("Expected a to be changed, got %+v", diff)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0] != "b" {
+		t.Errorf("Expected b to be removed, got %+v", diff)
+	}
+}
+
+func TestToolsListServesCachedCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool-cache.json")
+
+	online := NewServer(&mockAPIClient{
+		tools: []api.ToolDefinition{
+			{Name: "prod-1", Description: "Alpha — First tool", Parameters: json.RawMessage(`{}`)},
+		},
+	}, "1.0.0")
+	online.SetCache(path, "fp")
+	if _, err := online.RefreshTools(context.Background()); err != nil {
+		t.Fatalf("RefreshTools() failed: %v", err)
+	}
+
+	// The API now returns nothing: tools/list must come from the cache
+	offline := NewServer(&mockAPIClient{}, "1.0.0")
+	offline.SetCache(path, "fp")
+	if err := offline.LoadCache(); err != nil {
+		t.Fatalf("LoadCache() failed: %v", err)
+	}
+	if !offline.stale.Load() {
+		t.Error("Expected server to be stale after loading the cache")
+	}
+
+	resp := offline.handleToolsList(1)
+	result := resp.Result.(map[string]interface{})
+	tools := result["tools"].([]MCPTool)
+	if len(tools) != 2 || tools[0].Name != "Alpha" || tools[1].Name != QuoteToolName {
+		t.Errorf("Expected cached tool Alpha followed by %s, got %+v", QuoteToolName, tools)
+	}
+
+	offline.nameMux.RLock()
+	productID := offline.nameToIDMap["Alpha"]
+	offline.nameMux.RUnlock()
+	if productID != "prod-1" {
+		t.Errorf("Expected Alpha to map to prod-1, got %q", productID)
+	}
+}
+
+func TestLoadCacheRejectsOtherCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tool-cache.json")
+
+	online := NewServer(&mockAPIClient{}, "1.0.0")
+	online.SetCache(path, "fp-a")
+	online.RefreshTools(context.Background())
+
+	other := NewServer(&mockAPIClient{}, "1.0.0")
+	other.SetCache(path, "fp-b")
+	if err := other.LoadCache(); err == nil {
+		t.Error("Expected error loading a cache written for other credentials")
+	}
+}
+
+func TestToolsListDisambiguatesCollidingNames(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{Name: "prod-2", Description: "weather — Forecasts", Parameters: json.RawMessage(`{"type":"object"}`)},
+			{Name: "prod-1", Description: "weather — Current conditions", Parameters: json.RawMessage(`{"type":"object"}`)},
+		},
+	}
+	server := NewServer(mockClient, "1.0.0")
+	if err := server.SetNameRegistry(filepath.Join(t.TempDir(), naming.FileName)); err != nil {
+		t.Fatalf("SetNameRegistry() error = %v", err)
+	}
+	server.handleToolsList(1)
+
+	if server.nameToIDMap["weather"] != "prod-1" || server.nameToIDMap["weather-prod-2"] != "prod-2" {
+		t.Errorf("Expected both tools to get distinct names, got %v", server.nameToIDMap)
+	}
+
+	// The description changes; the old name keeps working
+	mockClient.tools[1].Description = "conditions — Current conditions"
+	if _, err := server.RefreshTools(context.Background()); err != nil {
+		t.Fatalf("RefreshTools() error = %v", err)
+	}
+	if server.nameToIDMap["conditions"] != "prod-1" || server.nameToIDMap["weather"] != "prod-1" {
+		t.Errorf("Expected the new name and the old alias to map to prod-1, got %v", server.nameToIDMap)
+	}
+	if tool, ok := server.findTool("weather"); ok {
+		t.Errorf("Expected aliases to stay out of tools/list, found %+v", tool)
+	}
+	if name := server.canonicalName("weather"); name != "conditions" {
+		t.Errorf("Expected the alias to resolve to conditions, got %q", name)
+	}
+}
+
+func TestToolsListAnnotatesTools(t *testing.T) {
+	readOnly := true
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{
+				Name:        "prod-1",
+				Description: "Smart Math — Evaluates expressions",
+				Parameters:  json.RawMessage(`{"type":"object"}`),
+				Pricing:     &api.Pricing{Cost: 0.01, Currency: "USD", Unit: "call"},
+				Annotations: &annotation.Hints{ReadOnlyHint: &readOnly},
+			},
+			{Name: "prod-2", Description: "Weather Check — Looks up the weather", Parameters: json.RawMessage(`{"type":"object"}`)},
+		},
+	}
+	server := NewServer(mockClient, "1.0.0")
+	server.SetOverrides(annotation.Overrides{
+		"prod-2": {Annotations: &annotation.Hints{DestructiveHint: new(bool)}, Cost: &annotation.Cost{Price: 0.10, Unit: "lookup"}},
+	})
+	server.handleToolsList(1)
+
+	math, _ := server.findTool("Smart-Math")
+	if math.Title != "Smart Math" || math.Annotations == nil || !*math.Annotations.ReadOnlyHint {
+		t.Errorf("Expected the title and catalog annotations, got %+v", math)
+	}
+	if cost, _ := math.Meta[annotation.CostMetaKey].(*annotation.Cost); cost == nil || cost.Price != 0.01 || cost.Unit != "call" {
+		t.Errorf("Expected the catalog cost in _meta, got %+v", math.Meta)
+	}
+
+	weather, _ := server.findTool("Weather-Check")
+	if weather.Annotations == nil || weather.Annotations.DestructiveHint == nil || *weather.Annotations.DestructiveHint {
+		t.Errorf("Expected the overridden annotation, got %+v", weather.Annotations)
+	}
+	if weather.price == nil || weather.price.Amount != 0.10 || weather.price.Source != "override" {
+		t.Errorf("Expected the override price, got %+v", weather.price)
+	}
+}
@@ -0,0 +1,74 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/resource"
+)
+
+// ResourceNotFound is the MCP error code for resources/read of an unknown URI
+const ResourceNotFound = -32002
+
+// handleResourcesRead returns the contents of a budget, purchase or tool resource
+func (s *Server) handleResourcesRead(id interface{}, params map[string]interface{}) JSONRPCResponse {
+	uri, _ := params["uri"].(string)
+	if uri == "" {
+		return jsonErr(id, InvalidParams, "missing or invalid 'uri' parameter")
+	}
+
+	contents, err := s.resourceReader().Read(uri, stdioSession)
+	if errors.Is(err, resource.ErrNotFound) {
+		return jsonErr(id, ResourceNotFound, fmt.Sprintf("resource not found: %s", uri))
+	}
+	if err != nil {
+		return jsonErr(id, InternalError, err.Error())
+	}
+	return jsonOK(id, map[string]interface{}{"contents": []resource.Contents{contents}})
+}
+
+// handleResourcesSubscribe records (or drops) the client's interest in a resource
+func (s *Server) handleResourcesSubscribe(id interface{}, params map[string]interface{}, subscribe bool) JSONRPCResponse {
+	uri, _ := params["uri"].(string)
+	if uri == "" {
+		return jsonErr(id, InvalidParams, "missing or invalid 'uri' parameter")
+	}
+
+	if subscribe {
+		if _, err := s.resourceReader().Read(uri, stdioSession); errors.Is(err, resource.ErrNotFound) {
+			return jsonErr(id, ResourceNotFound, fmt.Sprintf("resource not found: %s", uri))
+		}
+		s.subscriptions.Subscribe(uri)
+	} else {
+		s.subscriptions.Unsubscribe(uri)
+	}
+	return jsonOK(id, map[string]interface{}{})
+}
+
+// resourceReader serves resources from the ledger and the tool list
+func (s *Server) resourceReader() resource.Reader {
+	return resource.Reader{Ledger: s.ledger, Tool: s.listedTool}
+}
+
+// listedTool returns the tools/list entry for a tool name, alias or product ID
+func (s *Server) listedTool(name string) (interface{}, bool) {
+	if err := s.ensureCatalog(); err != nil {
+		log.Printf("Failed to fetch tools: %v", err)
+		return nil, false
+	}
+	tool, ok := s.findTool(s.canonicalName(name))
+	if !ok {
+		return nil, false
+	}
+	return tool, true
+}
+
+// notifyPurchase tells the client, if subscribed, that the budget and purchase list changed
+func (s *Server) notifyPurchase() {
+	for _, uri := range resource.UpdatedByPurchase {
+		if s.subscriptions.Has(uri) {
+			s.sendNotification("notifications/resources/updated", map[string]interface{}{"uri": uri})
+		}
+	}
+}
@@ -9,36 +9,179 @@ import (
 	"log"
 	"os"
 	"strings"
-
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/content"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/inflight"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/naming"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/outgoing"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/resource"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/toolset"
 	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
 )
 
+// DefaultMaxConcurrency is the default number of requests handled at once
+const DefaultMaxConcurrency = 8
+
 // Server implements an MCP server over stdio
 type Server struct {
-	apiClient     api.ClientInterface
-	version       string
-	nameToIDMap   map[string]string // Maps readable name -> product ID
+	apiClient   api.ClientInterface
+	version     string
+	nameToIDMap map[string]string  // Maps readable name -> product ID
+	tools       []MCPTool          // Last catalog sent to or fetched for the client
+	nameMux     sync.RWMutex       // Guards nameToIDMap and tools
+	workers     chan struct{}      // Bounds concurrently executing requests
+	inflight    *inflight.Registry // Requests being handled, by JSON-RPC id
+
+	refreshInterval time.Duration
+	refreshCh       chan struct{}           // Requests an immediate catalog refresh
+	refreshMux      sync.Mutex              // Serializes catalog refreshes
+	notify          func(interface{}) error // Sends server-initiated messages while serving
+	notifyMux       sync.RWMutex
+
+	cachePath   string      // On-disk catalog cache ("" disables)
+	fingerprint string      // Identifies the credentials the cache belongs to
+	stale       atomic.Bool // Serving a cached catalog that hasn't been re-fetched yet
+
+	names  *naming.Registry // Keeps tool names unique and stable (nil = not persisted)
+	filter *toolset.Filter  // Which catalog tools are exposed (nil = all)
+
+	overrides annotation.Overrides // Local title, annotation and cost overrides
+	prompts   *prompt.Library      // Team prompt templates (nil = built-in prompts only)
+
+	subscriptions resource.Subscriptions // Resources the client wants notifications/resources/updated for
+
+	ledger     *ledger.Ledger // Local spend caps (nil = none)
+	clientName atomic.Value   // string: clientInfo.name from initialize
+
+	outgoing      *outgoing.Calls  // Server-initiated requests awaiting a response
+	elicitation   atomic.Bool      // Client declared the elicitation capability
+	approvalRules approval.Rules   // Which purchases need the user's approval
+	tokens        *approval.Tokens // Confirmation tokens for clients without elicitation
+
+	extractor     *content.Extractor // Turns images, audio and files in output into content blocks
+	normalizeArgs bool               // Coerce and default arguments to fit the input schema
 }
 
+// stdioSession identifies the router's only session in the spend ledger
+const stdioSession = "stdio"
+
 // NewServer creates a new MCP server
 func NewServer(apiClient api.ClientInterface, version string) *Server {
 	return &Server{
 		apiClient:   apiClient,
 		version:     version,
 		nameToIDMap: make(map[string]string),
+		workers:     make(chan struct{}, DefaultMaxConcurrency),
+		inflight:    inflight.NewRegistry(),
+		refreshCh:   make(chan struct{}, 1),
+		outgoing:    outgoing.New(),
+		tokens:      approval.NewTokens(approval.DefaultTokenTTL),
+		extractor:   content.NewExtractor(""),
 	}
 }
 
+// SetRefreshInterval sets how often the catalog is re-fetched in the background
+// A zero interval disables periodic refresh (TriggerRefresh still works).
+// Must be called before HandleStdioTransport
+func (s *Server) SetRefreshInterval(d time.Duration) {
+	s.refreshInterval = d
+}
+
+// SetOutputDir sets where outputs too large to send inline are written ("" = default)
+func (s *Server) SetOutputDir(dir string) {
+	s.extractor = content.NewExtractor(dir)
+}
+
+// SetNormalizeArguments turns schema-aware argument coercion and default filling on or off
+func (s *Server) SetNormalizeArguments(enabled bool) {
+	s.normalizeArgs = enabled
+}
+
+// SetNameRegistry keeps tool names stable across restarts using the registry at path
+// The registry starts empty if it can't be read; the error says why.
+func (s *Server) SetNameRegistry(path string) error {
+	names, err := naming.Open(path)
+	s.names = names
+	return err
+}
+
+// SetToolFilter sets which catalog tools are listed and callable (nil = all)
+// Must be called before the catalog is loaded
+func (s *Server) SetToolFilter(filter *toolset.Filter) {
+	s.filter = filter
+}
+
+// SetOverrides replaces catalog titles, annotations and costs for some tools
+// Must be called before the catalog is loaded
+func (s *Server) SetOverrides(overrides annotation.Overrides) {
+	s.overrides = overrides
+}
+
+// SetPrompts adds a team's own prompt templates to the built-in ones
+func (s *Server) SetPrompts(prompts *prompt.Library) {
+	s.prompts = prompts
+}
+
+// SetApproval sets which purchases the user must approve first
+func (s *Server) SetApproval(rules approval.Rules) {
+	s.approvalRules = rules
+}
+
+// SetLedger enables local spend caps; every purchase is checked against and recorded in l
+func (s *Server) SetLedger(l *ledger.Ledger) {
+	s.ledger = l
+}
+
+// SetMaxConcurrency sets how many requests may be handled concurrently
+// Must be called before HandleStdioTransport
+func (s *Server) SetMaxConcurrency(n int) {
+	if n <= 0 {
+		n = DefaultMaxConcurrency
+	}
+	s.workers = make(chan struct{}, n)
+}
+
 // HandleStdioTransport runs the stdio transport loop
 func (s *Server) HandleStdioTransport() error {
-	scanner := bufio.NewScanner(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+	return s.serve(os.Stdin, os.Stdout)
+}
+
+// serve reads newline-delimited JSON-RPC requests from r and writes responses to w
+// Requests are dispatched concurrently (bounded by the worker limit) so a slow
+// tools/call doesn't block tools/list or ping. Responses carry the request id
+// and may be written out of order.
+func (s *Server) serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	out := &frameWriter{encoder: json.NewEncoder(w)}
+
+	// Server-initiated notifications share the response writer
+	s.setNotifier(out.write)
+	defer s.setNotifier(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.runRefresher(ctx)
+
+	// A catalog loaded from disk is only a fast start; re-fetch it right away
+	if s.stale.Load() {
+		s.TriggerRefresh()
+	}
 
 	// Set maximum buffer size for large messages
 	const maxCapacity = 1024 * 1024 // 1MB
 	buf := make([]byte, maxCapacity)
 	scanner.Buffer(buf, maxCapacity)
 
+	var wg sync.WaitGroup
 	for scanner.Scan() {
 		line := scanner.Bytes()
 
@@ -48,31 +191,41 @@ func (s *Server) HandleStdioTransport() error {
 			continue // Skip malformed requests, keep connection alive
 		}
 
-		var response JSONRPCResponse
-
-		switch req.Method {
-		case "initialize":
-			response = s.handleInitialize(req.ID, req.Params)
-		case "tools/list":
-			response = s.handleToolsList(req.ID)
-		case "tools/call":
-			response = s.handleToolsCall(req.ID, req.Params)
-		case "resources/list":
-			// Not supported - return empty list
-			response = jsonOK(req.ID, map[string]interface{}{"resources": []interface{}{}})
-		case "notifications/initialized":
-			// Notification - no response needed
+		// Answers to our own requests (e.g. elicitation/create) go to the waiting call
+		if isResponse(req) {
+			if !s.outgoing.Deliver(req.ID, req.Result, req.Error) {
+				log.Printf("Ignoring response to unknown request %v", req.ID)
+			}
 			continue
-		default:
-			log.Printf("Unknown method: %s", req.Method)
-			response = jsonErr(req.ID, MethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
 		}
 
-		if err := encoder.Encode(response); err != nil {
-			log.Printf("Error encoding response: %v", err)
+		// Notifications get no response; handle them inline to keep their order
+		if strings.HasPrefix(req.Method, "notifications/") {
+			s.handleNotification(req)
+			continue
 		}
+
+		// Register the request before reading on, so a cancellation right behind it finds it
+		reqCtx, call, done := s.inflight.Start(ctx, req.ID, req.Method)
+
+		wg.Add(1)
+		go func(req JSONRPCRequest) {
+			defer wg.Done()
+			defer done()
+
+			response, ok := s.dispatch(reqCtx, call, req)
+			if !ok {
+				return
+			}
+			if err := out.write(response); err != nil {
+				log.Printf("Error encoding response: %v", err)
+			}
+		}(req)
 	}
 
+	// Wait for in-flight requests before closing the transport
+	wg.Wait()
+
 	if err := scanner.Err(); err != nil {
 		return fmt.Errorf("scanner error: %w", err)
 	}
@@ -80,16 +233,118 @@ func (s *Server) HandleStdioTransport() error {
 	return nil
 }
 
+// dispatch handles a request while holding a worker slot. The request is
+// already registered in s.inflight so notifications/cancelled can abort it.
+// Returns false when the request was cancelled, in which case no response
+// must be sent.
+func (s *Server) dispatch(ctx context.Context, call *inflight.Call, req JSONRPCRequest) (JSONRPCResponse, bool) {
+	select {
+	case s.workers <- struct{}{}:
+		defer func() { <-s.workers }()
+	case <-ctx.Done():
+		return JSONRPCResponse{}, false
+	}
+
+	// A free slot and a cancellation can be ready together; never start a cancelled request
+	if call.Cancelled() {
+		return JSONRPCResponse{}, false
+	}
+
+	response := s.handleRequest(ctx, req)
+	if call.Cancelled() {
+		return JSONRPCResponse{}, false
+	}
+	return response, true
+}
+
+// handleRequest dispatches a JSON-RPC request to its method handler
+func (s *Server) handleRequest(ctx context.Context, req JSONRPCRequest) JSONRPCResponse {
+	switch req.Method {
+	case "initialize":
+		return s.handleInitialize(req.ID, req.Params)
+	case "tools/list":
+		return s.handleToolsList(req.ID)
+	case "tools/call":
+		return s.handleToolsCall(ctx, req.ID, req.Params)
+	case RefreshMethod:
+		return s.handleToolsRefresh(ctx, req.ID)
+	case "ping":
+		return jsonOK(req.ID, map[string]interface{}{})
+	case "prompts/list":
+		return s.handlePromptsList(req.ID)
+	case "prompts/get":
+		return s.handlePromptsGet(req.ID, req.Params)
+	case "resources/list":
+		return jsonOK(req.ID, map[string]interface{}{"resources": resource.List()})
+	case "resources/templates/list":
+		return jsonOK(req.ID, map[string]interface{}{"resourceTemplates": resource.Templates()})
+	case "resources/read":
+		return s.handleResourcesRead(req.ID, req.Params)
+	case "resources/subscribe", "resources/unsubscribe":
+		return s.handleResourcesSubscribe(req.ID, req.Params, req.Method == "resources/subscribe")
+	default:
+		log.Printf("Unknown method: %s", req.Method)
+		return jsonErr(req.ID, MethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+	}
+}
+
+// handleNotification handles client notifications, which never get a response
+func (s *Server) handleNotification(req JSONRPCRequest) {
+	switch req.Method {
+	case "notifications/initialized":
+		// Nothing to do
+	case "notifications/cancelled":
+		s.handleCancelled(req.Params)
+	default:
+		log.Printf("Ignoring notification: %s", req.Method)
+	}
+}
+
+// isResponse reports whether a message answers a server-initiated request
+func isResponse(msg JSONRPCRequest) bool {
+	return msg.Method == "" && msg.ID != nil
+}
+
+// frameWriter serializes JSON-RPC frames so concurrent responses never interleave on stdout
+type frameWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// write encodes one message as a single line
+func (f *frameWriter) write(msg interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.encoder.Encode(msg)
+}
+
 // handleInitialize handles the initialize method
 func (s *Server) handleInitialize(id interface{}, params map[string]interface{}) JSONRPCResponse {
 	log.Printf("Initialize request from client")
 
+	if clientInfo, ok := params["clientInfo"].(map[string]interface{}); ok {
+		if name, ok := clientInfo["name"].(string); ok && name != "" {
+			s.clientName.Store(name)
+		}
+	}
+	capabilities, _ := params["capabilities"].(map[string]interface{})
+	_, elicitation := capabilities["elicitation"]
+	s.elicitation.Store(elicitation)
+	requested, _ := params["protocolVersion"].(string)
+
 	return jsonOK(id, map[string]interface{}{
-		"protocolVersion": ProtocolVersion,
+		"protocolVersion": negotiateVersion(requested),
 		"capabilities": map[string]interface{}{
 			"tools": map[string]interface{}{
 				"listChanged": true,
 			},
+			"prompts": map[string]interface{}{
+				"listChanged": true,
+			},
+			"resources": map[string]interface{}{
+				"subscribe":   true,
+				"listChanged": false,
+			},
 		},
 		"serverInfo": map[string]interface{}{
 			"name":    "agent-payment-router",
@@ -101,32 +356,9 @@ func (s *Server) handleInitialize(id interface{}, params map[string]interface{})
 // extractReadableName extracts a human-readable name from the description
 // and converts it to a valid MCP tool name (alphanumeric, hyphens, underscores only, max 64 chars)
 func extractReadableName(description string) string {
-	// Description format: "Smart Math Interpreter — A universal math engine..."
-	// Extract the part before "—" or similar delimiters
-	delimiters := []string{" — ", " - ", " – ", "|"}
-
-	var readablePart string
-	for _, delim := range delimiters {
-		if idx := strings.Index(description, delim); idx > 0 {
-			readablePart = strings.TrimSpace(description[:idx])
-			break
-		}
-	}
-
-	// Fallback: use first sentence or first 50 chars
-	if readablePart == "" {
-		if idx := strings.Index(description, "."); idx > 0 && idx < 100 {
-			readablePart = strings.TrimSpace(description[:idx])
-		} else if len(description) > 50 {
-			readablePart = strings.TrimSpace(description[:50])
-		} else {
-			readablePart = strings.TrimSpace(description)
-		}
-	}
-
 	// Convert to valid MCP tool name: alphanumeric, hyphens, underscores only
 	// Replace spaces with hyphens
-	name := strings.Join(strings.Fields(readablePart), "-")
+	name := strings.Join(strings.Fields(readablePart(description)), "-")
 
 	// Remove any characters that aren't alphanumeric, hyphen, or underscore
 	var validName strings.Builder
@@ -150,8 +382,45 @@ func extractReadableName(description string) string {
 	return result
 }
 
+// readablePart returns the tool's display name from its description
+// e.g. "Smart Math Interpreter" from "Smart Math Interpreter — A universal math engine..."
+func readablePart(description string) string {
+	// Description format: "Smart Math Interpreter — A universal math engine..."
+	// Extract the part before "—" or similar delimiters
+	delimiters := []string{" — ", " - ", " – ", "|"}
+
+	var part string
+	for _, delim := range delimiters {
+		if idx := strings.Index(description, delim); idx > 0 {
+			part = strings.TrimSpace(description[:idx])
+			break
+		}
+	}
+
+	// Fallback: use first sentence or first 50 chars
+	if part == "" {
+		if idx := strings.Index(description, "."); idx > 0 && idx < 100 {
+			part = strings.TrimSpace(description[:idx])
+		} else if len(description) > 50 {
+			part = strings.TrimSpace(description[:50])
+		} else {
+			part = strings.TrimSpace(description)
+		}
+	}
+
+	return part
+}
+
 // handleToolsList handles the tools/list method
 func (s *Server) handleToolsList(id interface{}) JSONRPCResponse {
+	// Serve the known catalog; the background refresher keeps it current
+	s.nameMux.RLock()
+	known := s.tools
+	s.nameMux.RUnlock()
+	if known != nil {
+		return jsonOK(id, map[string]interface{}{"tools": withQuoteTool(known)})
+	}
+
 	ctx := context.Background()
 
 	tools, err := s.apiClient.FetchTools(ctx)
@@ -163,49 +432,174 @@ func (s *Server) handleToolsList(id interface{}) JSONRPCResponse {
 	log.Printf("Fetched %d tools from API", len(tools))
 
 	// Convert to MCP format with readable names and build mapping
-	mcpTools := make([]MCPTool, len(tools))
-	for i, tool := range tools {
-		// Extract readable name from description
-		readableName := extractReadableName(tool.Description)
+	mcpTools, nameToID := s.buildTools(tools)
+	s.setCatalog(mcpTools, nameToID)
+	s.saveCache(tools, nameToID)
+
+	log.Printf("Mapped %d tools with readable names", len(nameToID))
+
+	return jsonOK(id, map[string]interface{}{"tools": withQuoteTool(mcpTools)})
+}
 
-		// Store mapping: readable name -> product ID
-		s.nameToIDMap[readableName] = tool.Name
+// buildTools converts API tools to MCP format and builds the readable name -> product ID map
+// Names come from the name registry so colliding descriptions get distinct
+// names and renamed tools stay callable under their former names.
+func (s *Server) buildTools(tools []api.ToolDefinition) ([]MCPTool, map[string]string) {
+	wanted := make([]naming.Tool, len(tools))
+	for i, tool := range tools {
+		wanted[i] = naming.Tool{ProductID: tool.Name, Name: extractReadableName(tool.Description)}
+	}
+	assignment := s.names.Assign(wanted)
+	if err := s.names.Save(); err != nil {
+		log.Printf("Warning: failed to save tool names: %v", err)
+	}
 
-		mcpTools[i] = MCPTool{
-			Name:        readableName,
-			Description: tool.Description,
-			InputSchema: tool.Parameters, // Raw pass-through!
+	mcpTools := make([]MCPTool, 0, len(tools))
+	nameToID := make(map[string]string, len(tools))
+	for name, productID := range assignment.Resolve() {
+		if s.filter.Allowed(productID, assignment.Names[productID]) {
+			nameToID[name] = productID
 		}
 	}
+	for _, tool := range tools {
+		readableName := assignment.Names[tool.Name]
+		if !s.filter.Allowed(tool.Name, readableName) {
+			continue
+		}
 
-	log.Printf("Mapped %d tools with readable names", len(s.nameToIDMap))
+		annotated := s.annotate(tool, readableName)
+		mcpTools = append(mcpTools, MCPTool{
+			Name:         readableName,
+			Description:  tool.Description,
+			InputSchema:  schema.Sanitize(tool.Parameters), // Listed and validated against
+			OutputSchema: schema.OutputSchema(tool.OutputSchema),
+			Title:        annotated.Title,
+			Annotations:  annotated.Hints,
+			Meta:         annotated.Meta(),
+			price:        annotatedPrice(annotated),
+			examples:     tool.Examples,
+		})
+	}
+	if filtered := len(tools) - len(mcpTools); filtered > 0 {
+		log.Printf("Filtered out %d tools (%s)", filtered, s.filter)
+	}
+	return mcpTools, nameToID
+}
 
-	return jsonOK(id, map[string]interface{}{"tools": mcpTools})
+// setCatalog atomically replaces the known tools and name mapping
+// Returns the tools it replaced (nil if the catalog was never loaded).
+func (s *Server) setCatalog(tools []MCPTool, nameToID map[string]string) []MCPTool {
+	s.nameMux.Lock()
+	defer s.nameMux.Unlock()
+
+	previous := s.tools
+	s.tools = tools
+	s.nameToIDMap = nameToID
+	return previous
+}
+
+// catalogLoaded reports whether a catalog has been fetched or loaded from cache
+func (s *Server) catalogLoaded() bool {
+	s.nameMux.RLock()
+	defer s.nameMux.RUnlock()
+	return s.tools != nil
+}
+
+// ensureCatalog fetches the catalog unless one is already loaded
+func (s *Server) ensureCatalog() error {
+	if s.catalogLoaded() {
+		return nil
+	}
+	_, err := s.RefreshTools(context.Background())
+	return err
 }
 
 // handleToolsCall handles the tools/call method
-func (s *Server) handleToolsCall(id interface{}, params map[string]interface{}) JSONRPCResponse {
+func (s *Server) handleToolsCall(ctx context.Context, id interface{}, params map[string]interface{}) JSONRPCResponse {
 	// Extract tool name (this will be the readable name from Claude)
 	readableName, ok := params["name"].(string)
 	if !ok {
 		return jsonErr(id, InvalidParams, "missing or invalid 'name' parameter")
 	}
 
+	// Extract arguments
+	args, ok := params["arguments"].(map[string]interface{})
+	if !ok {
+		args = make(map[string]interface{})
+	}
+
+	// Quotes validate and price a call without buying anything
+	if readableName == QuoteToolName {
+		tool, _ := args["tool"].(string)
+		toolArgs, _ := args["arguments"].(map[string]interface{})
+		return s.handleQuote(id, tool, toolArgs)
+	}
+	if meta, ok := params["_meta"].(map[string]interface{}); ok && meta["dryRun"] == true {
+		return s.handleQuote(id, readableName, args)
+	}
+
+	// The filter can only be checked against a loaded catalog
+	if s.filter != nil && !s.catalogLoaded() {
+		if _, err := s.RefreshTools(ctx); err != nil {
+			return jsonErr(id, InternalError, fmt.Sprintf("failed to fetch tools: %v", err))
+		}
+	}
+
+	if name := s.canonicalName(readableName); name != readableName {
+		log.Printf("Tool '%s' is now called '%s'", readableName, name)
+		readableName = name
+	}
+
 	// Map readable name back to product ID
+	s.nameMux.RLock()
 	productID, exists := s.nameToIDMap[readableName]
+	s.nameMux.RUnlock()
+	if !exists && s.filter != nil {
+		// Filtered tools stay unreachable, whatever name or product ID is guessed
+		log.Printf("Refusing %s: not in the tool list", readableName)
+		return jsonErr(id, InvalidParams, fmt.Sprintf("unknown tool: %s is not available on this router", readableName))
+	}
 	if !exists {
 		// Fallback: use the name as-is if not in map (shouldn't happen)
 		log.Printf("Warning: Tool '%s' not found in mapping, using as-is", readableName)
 		productID = readableName
 	}
 
-	// Extract arguments
-	args, ok := params["arguments"].(map[string]interface{})
-	if !ok {
-		args = make(map[string]interface{})
+	log.Printf("Tool call: %s (product ID: %s)", readableName, productID)
+
+	args = s.normalizeArguments(readableName, args)
+
+	// Bad arguments never cost a round trip, let alone a charge
+	if errs := s.argumentErrors(readableName, args); len(errs) > 0 {
+		return invalidArguments(id, readableName, errs)
 	}
 
-	log.Printf("Tool call: %s (product ID: %s)", readableName, productID)
+	// Local spend caps are enforced before anything is sent to the API: the
+	// call's expected price is held against them until it is settled, so
+	// concurrent calls can't overshoot a cap together
+	var reservation *ledger.Reservation
+	if s.ledger != nil {
+		var err error
+		reservation, err = s.ledger.Reserve(stdioSession, readableName, s.expectedCost(readableName))
+		if err != nil {
+			log.Printf("Refusing %s: %v", readableName, err)
+			return s.apiErrorResult(id, fmt.Sprintf("purchase refused: %v. The tool was not called and nothing was charged.", err),
+				&agentpmt.Error{Kind: agentpmt.ErrBudgetExceeded, Message: err.Error()})
+		}
+	}
+	client, _ := s.clientName.Load().(string)
+	entry := ledger.Entry{
+		Tool:      readableName,
+		ProductID: productID,
+		Client:    client,
+		Session:   stdioSession,
+	}
+
+	// Ask the user before paying, when the approval rules say so
+	if refusal := s.checkApproval(ctx, readableName, args); refusal != "" {
+		reservation.Release()
+		return s.errorResult(id, refusal)
+	}
 
 	// Check if streaming is requested
 	streaming := false
@@ -216,6 +610,7 @@ func (s *Server) handleToolsCall(id interface{}, params map[string]interface{})
 	// Marshal arguments to JSON
 	argsJSON, err := json.Marshal(args)
 	if err != nil {
+		reservation.Release()
 		return jsonErr(id, InternalError, fmt.Sprintf("failed to marshal arguments: %v", err))
 	}
 
@@ -224,36 +619,52 @@ func (s *Server) handleToolsCall(id interface{}, params map[string]interface{})
 		Parameters: json.RawMessage(argsJSON),
 	}
 
-	ctx := context.Background()
+	// The trace lets a cancellation tell whether the purchase reached the API
+	ctx = inflight.TracePurchase(ctx, readableName)
 
 	if streaming {
 		// Handle streaming
 		var chunks []string
 		err := s.apiClient.StreamPurchase(ctx, req, func(chunk string) {
+			// Output is only streamed once the purchase went through
+			inflight.MarkCommitted(ctx)
 			chunks = append(chunks, chunk)
 		})
 
 		if err != nil {
 			log.Printf("Streaming purchase failed: %v", err)
-			return s.errorResult(id, err.Error())
+			s.settleFailedPurchase(reservation, entry, err)
+			return s.apiErrorResult(id, err.Error(), err)
 		}
 
 		output := strings.Join(chunks, "")
 		log.Printf("Streaming purchase completed: %d chars", len(output))
 
-		return s.successResult(id, output)
+		// Streams don't report a cost
+		s.recordPurchase(reservation, entry, -1)
+
+		return s.purchaseResult(id, productID, output)
 	}
 
 	// Handle synchronous
 	resp, err := s.apiClient.Purchase(ctx, req)
 	if err != nil {
 		log.Printf("Purchase failed: %v", err)
-		return s.errorResult(id, err.Error())
+		s.settleFailedPurchase(reservation, entry, err)
+		return s.apiErrorResult(id, err.Error(), err)
 	}
+	inflight.MarkCommitted(ctx)
 
 	log.Printf("Purchase completed successfully")
 
-	return s.successResult(id, resp.Output)
+	amount := -1.0
+	if resp.Cost != nil {
+		amount = *resp.Cost
+	}
+	entry.Details = resp.PurchaseDetails
+	s.recordPurchase(reservation, entry, amount)
+
+	return s.purchaseResult(id, productID, resp.Output)
 }
 
 // successResult creates a successful tool call result
@@ -269,6 +680,29 @@ func (s *Server) successResult(id interface{}, output string) JSONRPCResponse {
 	})
 }
 
+// recordPurchase settles a purchase's reservation in the spend ledger (amount < 0 =
+// unknown, the expected cost is recorded) and tells a subscribed client that the
+// budget changed
+func (s *Server) recordPurchase(reservation *ledger.Reservation, entry ledger.Entry, amount float64) {
+	if s.ledger == nil {
+		return
+	}
+	reservation.Record(entry, amount)
+	s.notifyPurchase()
+}
+
+// settleFailedPurchase releases a failed purchase's reservation, unless the API
+// may have charged it: then it is counted against the caps as unconfirmed
+func (s *Server) settleFailedPurchase(reservation *ledger.Reservation, entry ledger.Entry, err error) {
+	if !agentpmt.MayHaveCharged(err) {
+		reservation.Release()
+		return
+	}
+	entry.Unconfirmed = true
+	entry.Result = err.Error()
+	s.recordPurchase(reservation, entry, -1)
+}
+
 // errorResult creates an error tool call result (keeps connection alive)
 func (s *Server) errorResult(id interface{}, message string) JSONRPCResponse {
 	return jsonOK(id, MCPToolCallResult{
@@ -282,6 +716,16 @@ func (s *Server) errorResult(id interface{}, message string) JSONRPCResponse {
 	})
 }
 
+// apiErrorResult creates an error tool call result with the error's code in
+// _meta, so agents can tell whether to retry, ask the user or give up
+func (s *Server) apiErrorResult(id interface{}, message string, err error) JSONRPCResponse {
+	resp := s.errorResult(id, message)
+	result := resp.Result.(MCPToolCallResult)
+	result.Meta = agentpmt.ErrorMeta(err)
+	resp.Result = result
+	return resp
+}
+
 // SetupLogging configures logging to stderr with redaction
 func SetupLogging(apiKey, budgetKey string) {
 	log.SetOutput(os.Stderr)
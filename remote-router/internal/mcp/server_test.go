@@ -5,17 +5,28 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/agentpmt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/ledger"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/resource"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/toolset"
 	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
 )
 
 // mockAPIClient implements a simple mock for testing
 type mockAPIClient struct {
-	tools []api.ToolDefinition
+	tools            []api.ToolDefinition
 	purchaseResponse *api.PurchaseResponse
-	purchaseError error
+	purchaseError    error
+	purchaseBlock    chan struct{} // If set, Purchase waits until it is closed
+	purchases        atomic.Int32  // Purchase and StreamPurchase calls
 }
 
 func (m *mockAPIClient) FetchTools(ctx context.Context) ([]api.ToolDefinition, error) {
@@ -23,6 +34,14 @@ func (m *mockAPIClient) FetchTools(ctx context.Context) ([]api.ToolDefinition, e
 }
 
 func (m *mockAPIClient) Purchase(ctx context.Context, req api.PurchaseRequest) (*api.PurchaseResponse, error) {
+	m.purchases.Add(1)
+	if m.purchaseBlock != nil {
+		select {
+		case <-m.purchaseBlock:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 	if m.purchaseError != nil {
 		return nil, m.purchaseError
 	}
@@ -30,6 +49,7 @@ func (m *mockAPIClient) Purchase(ctx context.Context, req api.PurchaseRequest) (
 }
 
 func (m *mockAPIClient) StreamPurchase(ctx context.Context, req api.PurchaseRequest, onChunk func(string)) error {
+	m.purchases.Add(1)
 	if m.purchaseError != nil {
 		return m.purchaseError
 	}
@@ -77,8 +97,8 @@ func TestHandleToolsList(t *testing.T) {
 	mockClient := &mockAPIClient{
 		tools: []api.ToolDefinition{
 			{
-				Name:        "test-tool",
-				Description: "A test tool",
+				Name:        "prod-123",
+				Description: "test-tool — A test tool",
 				Parameters:  json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}}}`),
 			},
 		},
@@ -101,8 +121,13 @@ func TestHandleToolsList(t *testing.T) {
 		t.Fatal("Expected tools to be []MCPTool")
 	}
 
-	if len(tools) != 1 {
-		t.Fatalf("Expected 1 tool, got %d", len(tools))
+	// The catalog is followed by the quote meta-tool
+	if len(tools) != 2 {
+		t.Fatalf("Expected 2 tools, got %d", len(tools))
+	}
+
+	if tools[1].Name != QuoteToolName {
+		t.Errorf("Expected last tool to be %s, got %s", QuoteToolName, tools[1].Name)
 	}
 
 	if tools[0].Name != "test-tool" {
@@ -136,7 +161,7 @@ func TestHandleToolsCallSuccess(t *testing.T) {
 		},
 	}
 
-	resp := server.handleToolsCall(3, params)
+	resp := server.handleToolsCall(context.Background(), 3, params)
 
 	if resp.Error != nil {
 		t.Fatalf("Unexpected error: %v", resp.Error)
@@ -170,7 +195,7 @@ func TestHandleToolsCallMissingName(t *testing.T) {
 		"arguments": map[string]interface{}{},
 	}
 
-	resp := server.handleToolsCall(4, params)
+	resp := server.handleToolsCall(context.Background(), 4, params)
 
 	if resp.Error == nil {
 		t.Error("Expected error when name is missing")
@@ -283,3 +308,295 @@ func TestStdioLoop(t *testing.T) {
 		t.Error("Expected tools/list response")
 	}
 }
+
+func TestServeDispatchesConcurrently(t *testing.T) {
+	mockClient := &mockAPIClient{
+		purchaseResponse: &api.PurchaseResponse{Success: true, Output: "done"},
+		purchaseBlock:    make(chan struct{}),
+	}
+	server := NewServer(mockClient, "1.0.0")
+
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.serve(stdinR, stdoutW)
+		stdoutW.Close()
+	}()
+
+	responses := make(chan JSONRPCResponse)
+	go func() {
+		scanner := bufio.NewScanner(stdoutR)
+		for scanner.Scan() {
+			var resp JSONRPCResponse
+			if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+				t.Errorf("Malformed frame %q: %v", scanner.Text(), err)
+			}
+			responses <- resp
+		}
+		close(responses)
+	}()
+
+	// A slow tools/call must not block the ping sent after it
+	io.WriteString(stdinW, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow","arguments":{}}}`+"\n")
+	io.WriteString(stdinW, `{"jsonrpc":"2.0","id":2,"method":"ping"}`+"\n")
+
+	first := <-responses
+	if first.ID != float64(2) {
+		t.Fatalf("Expected ping (id 2) to be answered first, got id %v", first.ID)
+	}
+
+	close(mockClient.purchaseBlock)
+	second := <-responses
+	if second.ID != float64(1) {
+		t.Errorf("Expected tools/call (id 1) response, got id %v", second.ID)
+	}
+
+	stdinW.Close()
+	if err := <-done; err != nil {
+		t.Errorf("serve() returned error: %v", err)
+	}
+}
+
+func TestCancelledNotificationAbortsToolCall(t *testing.T) {
+	mockClient := &mockAPIClient{
+		purchaseResponse: &api.PurchaseResponse{Success: true, Output: "done"},
+		purchaseBlock:    make(chan struct{}), // Never closed: only cancellation ends the call
+	}
+	server := NewServer(mockClient, "1.0.0")
+
+	stdin := bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow","arguments":{}}}
+`)
+	stdinR, stdinW := io.Pipe()
+	var stdout bytes.Buffer
+	done := make(chan error, 1)
+	go func() { done <- server.serve(stdinR, &stdout) }()
+
+	io.Copy(stdinW, stdin)
+
+	// Wait until the call is registered before cancelling it
+	for {
+		if server.inflight.Len() == 1 {
+			break
+		}
+		runtime.Gosched()
+	}
+
+	io.WriteString(stdinW, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1,"reason":"user"}}`+"\n")
+	stdinW.Close()
+
+	if err := <-done; err != nil {
+		t.Fatalf("serve() returned error: %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no response for a cancelled call, got %s", stdout.String())
+	}
+}
+
+func TestCancelRightBehindToolCall(t *testing.T) {
+	mockClient := &mockAPIClient{purchaseResponse: &api.PurchaseResponse{Success: true, Output: "done"}}
+	server := NewServer(mockClient, "1.0.0")
+
+	// The cancellation is read before the request's goroutine gets going
+	var stdin strings.Builder
+	for i := 1; i <= 50; i++ {
+		fmt.Fprintf(&stdin, `{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"slow","arguments":{}}}`+"\n", i)
+		fmt.Fprintf(&stdin, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":%d}}`+"\n", i)
+	}
+
+	var stdout bytes.Buffer
+	if err := server.serve(strings.NewReader(stdin.String()), &stdout); err != nil {
+		t.Fatalf("serve() returned error: %v", err)
+	}
+
+	if n := mockClient.purchases.Load(); n != 0 {
+		t.Errorf("Expected no purchases, got %d", n)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no responses to cancelled calls, got %s", stdout.String())
+	}
+}
+
+func TestToolsCallRefusedBySpendCap(t *testing.T) {
+	mockClient := &mockAPIClient{purchaseError: fmt.Errorf("must not be called")}
+	server := NewServer(mockClient, "1.0.0")
+
+	spend, _ := ledger.New(ledger.Limits{Day: 0.50}, "")
+	spend.Record(ledger.Entry{Tool: "test-tool"}, 0.50)
+	server.SetLedger(spend)
+
+	resp := server.handleToolsCall(context.Background(), 1, map[string]interface{}{"name": "test-tool"})
+	result := resp.Result.(MCPToolCallResult)
+	if !result.IsError {
+		t.Fatalf("Expected isError result, got %+v", result)
+	}
+	if !strings.Contains(result.Content[0].Text, "daily spend cap") {
+		t.Errorf("Expected the result to name the daily cap, got %q", result.Content[0].Text)
+	}
+	if result.Meta["errorCode"] != "budget_exceeded" {
+		t.Errorf("Expected a budget_exceeded error code, got %v", result.Meta)
+	}
+}
+
+func TestToolsCallReportsErrorCodes(t *testing.T) {
+	tests := []struct {
+		err       error
+		code      string
+		retryable bool
+	}{
+		{&agentpmt.Error{Kind: agentpmt.ErrRateLimited, Status: 429, Message: "slow down"}, "rate_limited", true},
+		{fmt.Errorf("purchase failed: %w", &agentpmt.Error{Kind: agentpmt.ErrBudgetExceeded, Status: 200}), "budget_exceeded", false},
+		{&agentpmt.Error{Kind: agentpmt.ErrUnauthorized, Status: 401}, "unauthorized", false},
+	}
+
+	for _, tt := range tests {
+		server := NewServer(&mockAPIClient{purchaseError: tt.err}, "1.0.0")
+		result := server.handleToolsCall(context.Background(), 1, map[string]interface{}{"name": "test-tool"}).Result.(MCPToolCallResult)
+		if !result.IsError || result.Meta["errorCode"] != tt.code || result.Meta["retryable"] != tt.retryable {
+			t.Errorf("Expected %s (retryable %v) for %v, got %+v", tt.code, tt.retryable, tt.err, result)
+		}
+	}
+}
+
+func TestToolsCallRecordsSpend(t *testing.T) {
+	cost := 0.20
+	mockClient := &mockAPIClient{purchaseResponse: &api.PurchaseResponse{Success: true, Output: "ok", Cost: &cost}}
+	server := NewServer(mockClient, "1.0.0")
+
+	spend, _ := ledger.New(ledger.Limits{Session: 0.30}, "")
+	server.SetLedger(spend)
+
+	params := map[string]interface{}{"name": "test-tool"}
+	if result := server.handleToolsCall(context.Background(), 1, params).Result.(MCPToolCallResult); result.IsError {
+		t.Fatalf("Expected first call to succeed, got %+v", result)
+	}
+	if spend.Spent() != 0.20 {
+		t.Errorf("Expected 0.20 recorded, got %.2f", spend.Spent())
+	}
+
+	// 0.20 of 0.30 spent: still allowed; after the second call the cap is exceeded
+	server.handleToolsCall(context.Background(), 2, params)
+	if result := server.handleToolsCall(context.Background(), 3, params).Result.(MCPToolCallResult); !result.IsError {
+		t.Error("Expected third call to be refused by the session cap")
+	}
+}
+
+func TestFilteredToolsAreHiddenAndRefused(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{Name: "prod-1", Description: "test-tool — A test", Parameters: json.RawMessage(`{"type":"object"}`)},
+			{Name: "prod-2", Description: "weather-check — Looks up the weather", Parameters: json.RawMessage(`{"type":"object"}`)},
+		},
+		purchaseError: fmt.Errorf("must not be called"),
+	}
+	server := NewServer(mockClient, "1.0.0")
+	filter, _ := toolset.New(toolset.Rules{Deny: []string{"weather-*"}}, nil, "")
+	server.SetToolFilter(filter)
+
+	// The catalog is loaded on demand so a guessed name can't slip through
+	for _, name := range []string{"weather-check", "prod-2"} {
+		resp := server.handleToolsCall(context.Background(), 1, map[string]interface{}{"name": name})
+		if resp.Error == nil || resp.Error.Code != InvalidParams {
+			t.Errorf("Expected %q to be refused with InvalidParams, got %+v", name, resp)
+		}
+	}
+
+	tools := server.handleToolsList(2).Result.(map[string]interface{})["tools"].([]MCPTool)
+	if len(tools) != 2 || tools[0].Name != "test-tool" || tools[1].Name != QuoteToolName {
+		t.Errorf("Expected only test-tool and quote_tool to be listed, got %+v", tools)
+	}
+	if got := server.canonicalName("prod-1"); got != "test-tool" {
+		t.Errorf("canonicalName(prod-1) = %q, want test-tool", got)
+	}
+}
+
+func TestPurchaseUpdatesResources(t *testing.T) {
+	cost := 0.20
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{Name: "prod-1", Description: "test-tool — A test", Parameters: json.RawMessage(`{"type":"object"}`)},
+		},
+		purchaseResponse: &api.PurchaseResponse{
+			Success: true, Output: "ok", Cost: &cost,
+			PurchaseDetails: json.RawMessage(`{"remaining_balance": 4.80}`),
+		},
+	}
+	server := NewServer(mockClient, "1.0.0")
+	spend, _ := ledger.New(ledger.Limits{}, "")
+	server.SetLedger(spend)
+
+	var sent []JSONRPCNotification
+	server.setNotifier(func(msg interface{}) error {
+		sent = append(sent, msg.(JSONRPCNotification))
+		return nil
+	})
+
+	request := func(method string, params map[string]interface{}) JSONRPCResponse {
+		return server.handleRequest(context.Background(), JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	}
+
+	templates := request("resources/templates/list", nil).Result.(map[string]interface{})["resourceTemplates"].([]resource.Template)
+	if len(templates) != 2 {
+		t.Errorf("Expected the purchase and tool templates, got %+v", templates)
+	}
+	if resp := request("resources/subscribe", map[string]interface{}{"uri": "agentpmt://nowhere"}); resp.Error == nil {
+		t.Error("Expected subscribing to an unknown resource to fail")
+	}
+	request("resources/subscribe", map[string]interface{}{"uri": resource.BudgetURI})
+
+	server.handleToolsCall(context.Background(), 2, map[string]interface{}{"name": "test-tool"})
+	if len(sent) != 1 || sent[0].Method != "notifications/resources/updated" {
+		t.Fatalf("Expected one budget update, got %+v", sent)
+	}
+
+	read := func(uri string) string {
+		resp := request("resources/read", map[string]interface{}{"uri": uri})
+		if resp.Error != nil {
+			t.Fatalf("resources/read %s failed: %+v", uri, resp.Error)
+		}
+		return resp.Result.(map[string]interface{})["contents"].([]resource.Contents)[0].Text
+	}
+	if budget := read(resource.BudgetURI); !strings.Contains(budget, `"amount": 4.8`) {
+		t.Errorf("Expected the reported balance, got %s", budget)
+	}
+	purchase := spend.Purchases(1)[0]
+	if receipt := read(resource.PurchaseURI(purchase.ID)); !strings.Contains(receipt, "remaining_balance") {
+		t.Errorf("Expected the purchase details in the receipt, got %s", receipt)
+	}
+	if doc := read(resource.ToolURI("prod-1")); !strings.Contains(doc, `"name": "test-tool"`) {
+		t.Errorf("Expected the tool listing by product ID, got %s", doc)
+	}
+}
+
+func TestPromptsListAndGet(t *testing.T) {
+	cost := 0.05
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{
+				Name: "prod-1", Description: "weather — Looks up the weather", Parameters: json.RawMessage(`{"type":"object"}`),
+				Pricing:  &api.Pricing{Cost: cost, Currency: "USD"},
+				Examples: []prompt.Example{{Arguments: json.RawMessage(`{"city":"Paris"}`)}},
+			},
+		},
+	}
+	server := NewServer(mockClient, "1.0.0")
+
+	request := func(method string, params map[string]interface{}) JSONRPCResponse {
+		return server.handleRequest(context.Background(), JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	}
+
+	// The catalog is fetched on demand to build the per-tool prompts
+	prompts := request("prompts/list", nil).Result.(map[string]interface{})["prompts"].([]prompt.Prompt)
+	if len(prompts) != 3 || prompts[2].Name != "use-weather" {
+		t.Errorf("Expected the built-in prompts and one for weather, got %+v", prompts)
+	}
+
+	resp := request("prompts/get", map[string]interface{}{"name": "cheapest-tool", "arguments": map[string]interface{}{"task": "rain?"}})
+	if text := resp.Result.(prompt.Result).Messages[0].Content.Text; !strings.Contains(text, "weather: 0.0500 USD") {
+		t.Errorf("Expected the catalog price in the prompt, got %s", text)
+	}
+	if resp := request("prompts/get", map[string]interface{}{"name": "plan-within-budget"}); resp.Error == nil {
+		t.Error("Expected missing arguments to be refused")
+	}
+}
@@ -1,6 +1,13 @@
 package mcp
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/annotation"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/content"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/outgoing"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/prompt"
+)
 
 // JSONRPCRequest represents an incoming JSON-RPC 2.0 request
 type JSONRPCRequest struct {
@@ -8,6 +15,10 @@ type JSONRPCRequest struct {
 	ID      interface{}            `json:"id,omitempty"` // Can be string, number, or null
 	Method  string                 `json:"method"`
 	Params  map[string]interface{} `json:"params,omitempty"`
+
+	// Set when the message is the client's response to a server-initiated request
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *outgoing.Error `json:"error,omitempty"`
 }
 
 // JSONRPCResponse represents an outgoing JSON-RPC 2.0 response
@@ -18,18 +29,39 @@ type JSONRPCResponse struct {
 	Error   *RPCError   `json:"error,omitempty"`
 }
 
+// JSONRPCNotification represents an outgoing JSON-RPC 2.0 notification (no id)
+type JSONRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
 // RPCError represents a JSON-RPC error
 type RPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
 
 // MCP Protocol constants
 const (
-	ProtocolVersion = "2025-03-26"
+	ProtocolVersion = "2025-03-26" // Answered when the client asks for a version we don't speak
 )
 
+// Protocol versions the router speaks, newest first
+// structuredContent, outputSchema and elicitation arrived in 2025-06-18.
+var supportedVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// negotiateVersion picks the protocol version to answer initialize with
+func negotiateVersion(requested string) string {
+	for _, version := range supportedVersions {
+		if version == requested {
+			return version
+		}
+	}
+	return ProtocolVersion
+}
+
 // Error codes
 const (
 	ParseError     = -32700
@@ -64,16 +96,31 @@ type MCPTool struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	InputSchema json.RawMessage `json:"inputSchema"`
+
+	// OutputSchema describes structuredContent, from the catalog
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"`
+
+	// Title, annotations and cost (in _meta) come from the catalog and the override file
+	Title       string                 `json:"title,omitempty"`
+	Annotations *annotation.Hints      `json:"annotations,omitempty"`
+	Meta        map[string]interface{} `json:"_meta,omitempty"`
+
+	price    *price           // Annotated price, used for quotes and approvals
+	examples []prompt.Example // Sample calls from the catalog, for usage prompts
 }
 
 // MCPToolCallResult represents the result of a tool call
 type MCPToolCallResult struct {
 	Content []MCPContent `json:"content"`
 	IsError bool         `json:"isError,omitempty"`
-}
 
-// MCPContent represents a content block in tool results
-type MCPContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	// StructuredContent is JSON output as an object, alongside its text in Content
+	StructuredContent map[string]interface{} `json:"structuredContent,omitempty"`
+
+	// Meta carries a failed purchase's errorCode and whether it is retryable
+	Meta map[string]interface{} `json:"_meta,omitempty"`
 }
+
+// MCPContent represents a content block in tool results: text, image, audio,
+// an embedded resource or a resource link
+type MCPContent = content.Block
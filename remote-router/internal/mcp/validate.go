@@ -0,0 +1,92 @@
+package mcp
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/approval"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+)
+
+// reservedArguments are consumed by the router, never checked against a tool's schema
+var reservedArguments = []string{approval.TokenArgument, "stream"}
+
+// withoutReserved returns a copy of args without the reserved arguments, and those it removed
+func withoutReserved(args map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	checked := make(map[string]interface{}, len(args))
+	for name, value := range args {
+		checked[name] = value
+	}
+	reserved := make(map[string]interface{})
+	for _, name := range reservedArguments {
+		if value, ok := checked[name]; ok {
+			reserved[name] = value
+			delete(checked, name)
+		}
+	}
+	return checked, reserved
+}
+
+// normalizeArguments coerces scalars, fills defaults and drops forbidden
+// properties to fit the tool's input schema, when normalization is enabled
+func (s *Server) normalizeArguments(tool string, args map[string]interface{}) map[string]interface{} {
+	if !s.normalizeArgs {
+		return args
+	}
+	entry, ok := s.findTool(tool)
+	if !ok {
+		return args
+	}
+
+	checked, reserved := withoutReserved(args)
+	normalized, changes := schema.Normalize(entry.InputSchema, checked)
+	if len(changes) == 0 {
+		return args
+	}
+
+	edits := make([]string, len(changes))
+	for i, c := range changes {
+		edits[i] = c.String()
+	}
+	log.Printf("Normalized arguments for %s: %s", tool, strings.Join(edits, "; "))
+
+	for name, value := range reserved {
+		normalized[name] = value
+	}
+	return normalized
+}
+
+// argumentErrors checks args against the tool's input schema
+// Tools missing from the catalog are not checked.
+func (s *Server) argumentErrors(tool string, args map[string]interface{}) []schema.FieldError {
+	entry, ok := s.findTool(tool)
+	if !ok {
+		return nil
+	}
+
+	checked, _ := withoutReserved(args)
+	return schema.Validate(entry.InputSchema, checked)
+}
+
+// invalidArguments returns the InvalidParams error for a call with bad arguments
+func invalidArguments(id interface{}, tool string, errs []schema.FieldError) JSONRPCResponse {
+	problems := make([]string, len(errs))
+	for i, e := range errs {
+		problems[i] = e.String()
+	}
+	log.Printf("Rejecting %s: invalid arguments: %s", tool, strings.Join(problems, "; "))
+
+	return JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &RPCError{
+			Code:    InvalidParams,
+			Message: fmt.Sprintf("invalid arguments for %s: %s", tool, strings.Join(problems, "; ")),
+			Data: map[string]interface{}{
+				"tool":   tool,
+				"errors": errs,
+			},
+		},
+	}
+}
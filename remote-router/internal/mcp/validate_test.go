@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Apoth3osis-ai/agent-payment-mcp/pkg/schema"
+	"github.com/Apoth3osis-ai/agent-payment-mcp/remote-router/internal/api"
+)
+
+func TestToolsCallRejectsInvalidArguments(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{
+				Name:        "prod-1",
+				Description: "weather — Forecasts",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"city": {"type": "string"},
+						"days": {"type": "integer", "minimum": 1}
+					},
+					"required": ["city"],
+					"additionalProperties": false
+				}`),
+			},
+		},
+		purchaseError: fmt.Errorf("must not be called"),
+	}
+	server := NewServer(mockClient, "1.0.0")
+	server.handleToolsList(1)
+
+	resp := server.handleToolsCall(context.Background(), 2, map[string]interface{}{
+		"name":      "weather",
+		"arguments": map[string]interface{}{"days": "three", "stream": false},
+	})
+	if resp.Error == nil || resp.Error.Code != InvalidParams {
+		t.Fatalf("Expected an InvalidParams error, got %+v", resp)
+	}
+	errs := resp.Error.Data.(map[string]interface{})["errors"].([]schema.FieldError)
+	if len(errs) != 2 || errs[0].Field != "city" || errs[1].Field != "days" {
+		t.Errorf("Expected errors for city and days only, got %+v", errs)
+	}
+}
+
+func TestToolsCallValidatesTheListedSchema(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{
+				Name:        "prod-1",
+				Description: "weather — Forecasts",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"city": {"type": "string", "required": true},
+						"days": {"type": "integer", "default": "3"}
+					}
+				}`),
+			},
+		},
+		purchaseError: fmt.Errorf("must not be called"),
+	}
+	server := NewServer(mockClient, "1.0.0")
+
+	// Agents see the sanitized schema: a required list and a typed default
+	listed := server.handleToolsList(1).Result.(map[string]interface{})["tools"].([]MCPTool)[0]
+	var inputSchema map[string]interface{}
+	if err := json.Unmarshal(listed.InputSchema, &inputSchema); err != nil {
+		t.Fatalf("Invalid listed schema: %v", err)
+	}
+	days := inputSchema["properties"].(map[string]interface{})["days"].(map[string]interface{})
+	if fmt.Sprint(inputSchema["required"]) != "[city]" || days["default"] != 3.0 {
+		t.Fatalf("Expected the sanitized schema to be listed, got %s", listed.InputSchema)
+	}
+
+	// and calls are checked against that same schema, unlisted arguments included
+	resp := server.handleToolsCall(context.Background(), 2, map[string]interface{}{
+		"name":      "weather",
+		"arguments": map[string]interface{}{"colour": "red", "stream": false},
+	})
+	if resp.Error == nil || resp.Error.Code != InvalidParams {
+		t.Fatalf("Expected an InvalidParams error, got %+v", resp)
+	}
+	errs := resp.Error.Data.(map[string]interface{})["errors"].([]schema.FieldError)
+	if len(errs) != 2 || errs[0].Field != "city" || errs[1].Field != "colour" {
+		t.Errorf("Expected errors for city and colour only, got %+v", errs)
+	}
+}
+
+func TestToolsCallNormalizesArgumentsWhenEnabled(t *testing.T) {
+	mockClient := &mockAPIClient{
+		tools: []api.ToolDefinition{
+			{
+				Name:        "prod-1",
+				Description: "weather — Forecasts",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"city": {"type": "string"},
+						"days": {"type": "integer", "default": 1}
+					},
+					"required": ["city", "days"],
+					"additionalProperties": false
+				}`),
+			},
+		},
+		purchaseResponse: &api.PurchaseResponse{Success: true, Output: `{"ok": true}`},
+	}
+	server := NewServer(mockClient, "1.0.0")
+	server.handleToolsList(1)
+
+	call := func() JSONRPCResponse {
+		return server.handleToolsCall(context.Background(), 2, map[string]interface{}{
+			"name":      "weather",
+			"arguments": map[string]interface{}{"city": 94107.0, "stream": false},
+		})
+	}
+	if resp := call(); resp.Error == nil || resp.Error.Code != InvalidParams {
+		t.Fatalf("Expected an InvalidParams error without normalization, got %+v", resp)
+	}
+
+	server.SetNormalizeArguments(true)
+	if resp := call(); resp.Error != nil {
+		t.Fatalf("Expected the normalized call to succeed, got %+v", resp.Error)
+	}
+
+	got := server.normalizeArguments("weather", map[string]interface{}{"city": 94107.0, "stream": true})
+	if got["city"] != "94107" || got["days"] != 1.0 || got["stream"] != true {
+		t.Errorf("Expected city coerced, days defaulted and stream kept, got %v", got)
+	}
+}
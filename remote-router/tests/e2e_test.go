@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -70,8 +71,14 @@ func TestResourcesList(t *testing.T) {
 		t.Fatal("Expected resources array")
 	}
 
-	if len(resources) != 0 {
-		t.Error("Expected empty resources array")
+	uris := make(map[string]bool)
+	for _, r := range resources {
+		if resource, ok := r.(map[string]interface{}); ok {
+			uris[fmt.Sprint(resource["uri"])] = true
+		}
+	}
+	if !uris["agentpmt://budget"] || !uris["agentpmt://purchases"] {
+		t.Errorf("Expected the budget and purchases resources, got %v", resources)
 	}
 }
 